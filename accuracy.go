@@ -0,0 +1,99 @@
+package bsplines
+
+import (
+	"math"
+)
+
+//go:generate stringer -type=Accuracy
+
+// Accuracy defines how the basis functions are combined with the control points during evaluation,
+// see BSpline.WithAccuracy.
+type Accuracy int
+
+const (
+	// AccuracyDefault uses a plain sum of the products of basis functions and control points. It's the fastest.
+	AccuracyDefault Accuracy = iota
+
+	// AccuracyCompensated uses compensated (Neumaier) summation, with the rounding errors of the products
+	// recovered with fused multiply-add (FMA): the result is as accurate as if computed in twice the working
+	// precision, and then rounded. It's slower, but it matters for high degrees with control points of widely
+	// varying magnitudes, where the terms cancel each other.
+	AccuracyCompensated
+)
+
+// compensatedDot returns `Σ basis[r] * control[r]` using compensated summation, and FMA to capture the errors of the
+// products. See AccuracyCompensated.
+func compensatedDot(basis, control []float64) float64 {
+	var sum, compensation float64
+	for r, weight := range basis {
+		product := weight * control[r]
+		compensation += math.FMA(weight, control[r], -product) // Exact rounding error of the product.
+		t := sum + product
+		if math.Abs(sum) >= math.Abs(product) {
+			compensation += (sum - t) + product
+		} else {
+			compensation += (product - t) + sum
+		}
+		sum = t
+	}
+	return sum + compensation
+}
+
+// SpanErrorBound is the estimate of the worst-case rounding error of the evaluation of a B-spline over one knot span,
+// see BSpline.RoundingErrorBounds.
+type SpanErrorBound struct {
+	// Span is the index of the span in the expanded knots (see BSpline.SpanIndex), and [Start, End) is its interval.
+	Span       int
+	Start, End float64
+
+	// MaxAbsControlPoint is the largest absolute value of the `degree+1` control points that affect the span.
+	MaxAbsControlPoint float64
+
+	// Bound on the absolute rounding error of the evaluation with AccuracyDefault, and CompensatedBound with
+	// AccuracyCompensated.
+	Bound, CompensatedBound float64
+}
+
+// RoundingErrorBounds estimates, for each non-empty knot span, the worst-case absolute rounding error of evaluating
+// the B-spline, with the default and with the compensated accuracy (see WithAccuracy). Outside the domain the
+// evaluation is the extrapolation, with negligible rounding errors.
+//
+// The bounds follow the standard forward error analysis: each of the `degree+1` basis functions is computed with
+// `O(5*degree)` floating point operations, and they are combined with `degree+1` products and sums, so with
+// `γ(n) = n*u/(1-n*u)` (u is the unit round-off) the error is bounded by
+// `(γ(5*degree) + γ(degree+1)) * MaxAbsControlPoint`. Compensated summation removes the error of the combination,
+// but not the one of the basis functions. Actual errors are usually much smaller.
+//
+// Large bounds relative to the values of the B-spline indicate poorly scaled control points (large values that
+// cancel each other), for which the digits of the result can't be trusted.
+func (b *BSpline) RoundingErrorBounds() []SpanErrorBound {
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.RoundingErrorBounds() require control points to be set using BSpline.WithControlPoints()")
+	}
+	gamma := func(n int) float64 {
+		const u = 0x1p-53
+		return float64(n) * u / (1 - float64(n)*u)
+	}
+	basisError := gamma(5 * b.degree)
+	sumError := gamma(b.degree + 1)
+	var bounds []SpanErrorBound
+	for span := b.degree; span < b.NumControlPoints(); span++ {
+		start, end := b.expandedKnots[span], b.expandedKnots[span+1]
+		if start == end {
+			continue
+		}
+		var maxAbs float64
+		for _, value := range b.controlPoints[span-b.degree : span+1] {
+			maxAbs = math.Max(maxAbs, math.Abs(value))
+		}
+		bounds = append(bounds, SpanErrorBound{
+			Span:               span,
+			Start:              start,
+			End:                end,
+			MaxAbsControlPoint: maxAbs,
+			Bound:              (basisError + sumError) * maxAbs,
+			CompensatedBound:   (basisError + 0x1p-53) * maxAbs,
+		})
+	}
+	return bounds
+}
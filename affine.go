@@ -0,0 +1,30 @@
+package bsplines
+
+// AffineY returns a new B-spline with the same knots, degree and extrapolation, and the values transformed to
+// `scale*y + offset`: e.g. for unit conversions, or to normalize a fitted curve. Since the basis functions sum to 1,
+// it's simply the same transform applied to the control points.
+//
+// With [ExtrapolateZero] the extrapolated values remain 0, they are not shifted by offset.
+//
+// The control points must be set.
+func (b *BSpline) AffineY(scale, offset float64) *BSpline {
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.AffineY() require control points to be set using BSpline.WithControlPoints()")
+	}
+	control := make([]float64, len(b.controlPoints))
+	for ii, value := range b.controlPoints {
+		control[ii] = scale*value + offset
+	}
+	return newFromExpandedKnots(b.degree, b.expandedKnots).WithExtrapolation(b.extrapolation).
+		WithClosedDomain(b.closedDomain).WithControlPoints(control)
+}
+
+// ScaleY returns a new B-spline with the values multiplied by s, see AffineY.
+func (b *BSpline) ScaleY(s float64) *BSpline {
+	return b.AffineY(s, 0)
+}
+
+// ShiftY returns a new B-spline with c added to the values, see AffineY.
+func (b *BSpline) ShiftY(c float64) *BSpline {
+	return b.AffineY(1, c)
+}
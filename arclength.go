@@ -0,0 +1,87 @@
+package bsplines
+
+import "math"
+
+const (
+	// arcLengthOrder is the number of Gauss-Legendre points used per interval by ArcLength. The error is estimated by
+	// comparing with the quadrature of half the order.
+	arcLengthOrder = 16
+
+	// arcLengthTolerance is the relative error estimate below which ArcLength stops bisecting an interval, and
+	// arcLengthMaxDepth the maximum number of bisections.
+	arcLengthTolerance = 1e-12
+	arcLengthMaxDepth  = 20
+)
+
+// ArcLength returns the length of the curve between the parameters t0 and t1 (with t0 <= t1), and an estimate of
+// the absolute error of the calculation, e.g. for path planning or to space markers along the curve.
+//
+// It integrates the norm of the Tangent with Gauss-Legendre quadrature on each knot span, since the curve is only
+// smooth within the spans, and bisects the intervals where the error estimate -- the difference to a lower order
+// quadrature -- is large. Outside the domain the curve is extrapolated.
+func (c *Curve) ArcLength(t0, t1 float64) (length, errorEstimate float64) {
+	return arcLength("Curve.ArcLength", c.spline.Knots(), t0, t1, func(t float64) float64 {
+		var sum float64
+		for _, v := range c.Tangent(t) {
+			sum += v * v
+		}
+		return math.Sqrt(sum)
+	})
+}
+
+// ArcLength returns the length of the graph of the B-spline, the curve `(x, f(x))`, between x0 and x1 (with
+// x0 <= x1), and an estimate of the absolute error of the calculation. See Curve.ArcLength.
+//
+// The control points must be set.
+func (b *BSpline) ArcLength(x0, x1 float64) (length, errorEstimate float64) {
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.ArcLength() require control points to be set using BSpline.WithControlPoints()")
+	}
+	return arcLength("BSpline.ArcLength", b.Knots(), x0, x1, func(x float64) float64 {
+		_, derivative := b.EvaluateWithGradient(x)
+		return math.Hypot(1, derivative)
+	})
+}
+
+// arcLength integrates speed from t0 to t1, splitting the interval at the knots. It returns the integral and
+// the estimate of its absolute error. The name is used in error messages.
+func arcLength(name string, knots []float64, t0, t1 float64, speed func(t float64) float64) (length, errorEstimate float64) {
+	if !(t0 <= t1) {
+		panicf(ErrInvalidArgument, "%s(%g, %g) requires t0 <= t1", name, t0, t1)
+	}
+	nodes, weights := gaussLegendre(arcLengthOrder)
+	lowNodes, lowWeights := gaussLegendre(arcLengthOrder / 2)
+	quadrature := func(nodes, weights []float64, start, end float64) float64 {
+		halfWidth, center := (end-start)/2, (end+start)/2
+		var sum float64
+		for ii, node := range nodes {
+			sum += weights[ii] * speed(center+halfWidth*node)
+		}
+		return sum * halfWidth
+	}
+	var integrate func(start, end float64, depth int)
+	integrate = func(start, end float64, depth int) {
+		value := quadrature(nodes, weights, start, end)
+		estimate := math.Abs(value - quadrature(lowNodes, lowWeights, start, end))
+		if estimate > arcLengthTolerance*value && depth < arcLengthMaxDepth {
+			middle := (start + end) / 2
+			integrate(start, middle, depth+1)
+			integrate(middle, end, depth+1)
+			return
+		}
+		length += value
+		errorEstimate += estimate
+	}
+
+	start := t0
+	for _, knot := range knots {
+		if knot > start && knot < t1 {
+			integrate(start, knot, 0)
+			start = knot
+		}
+	}
+	if t1 > start {
+		integrate(start, t1, 0)
+	}
+	return
+}
@@ -0,0 +1,175 @@
+package bsplines
+
+import (
+	"math"
+)
+
+// Add returns the B-spline that is exactly the sum `a + b`, including the extrapolated regions.
+//
+// The splines must have the same domain (see WithClosedDomain) and extrapolation, but they can have different knots and degrees: the
+// result has the larger of the two degrees, and its knots are the union of the knots of a and b. When the degrees
+// differ, the interior knots are repeated as needed to keep the (lower) smoothness of the lower degree spline there,
+// so the result is exact -- unlike resampling and refitting.
+//
+// Both splines must have their control points set.
+func Add(a, b *BSpline) *BSpline {
+	return linearCombination("bsplines.Add", a, b, 1, 1)
+}
+
+// Sub returns the B-spline that is exactly the difference `a - b`, see Add.
+func Sub(a, b *BSpline) *BSpline {
+	return linearCombination("bsplines.Sub", a, b, 1, -1)
+}
+
+// linearCombination returns the B-spline `wa*a + wb*b`, for Add and Sub.
+func linearCombination(name string, a, b *BSpline, wa, wb float64) *BSpline {
+	checkCombinable(name, a, b)
+	degree := max(a.degree, b.degree)
+	result := newFromExpandedKnots(degree, MergeKnots(a.elevatedKnots(degree), b.elevatedKnots(degree))).
+		WithExtrapolation(a.extrapolation).WithClosedDomain(a.closedDomain)
+
+	// The quasi-interpolant reproduces exactly the B-splines of its space, which includes the combination.
+	return result.WithControlPoints(result.QuasiInterpolate(func(x float64) float64 {
+		return wa*a.Evaluate(x) + wb*b.Evaluate(x)
+	}))
+}
+
+// Blend returns a B-spline equal to a on the left of the transition interval `[x0-width/2, x0+width/2]`, equal to b
+// on its right, and a C² smooth transition in between: `(1-w(x))*a(x) + w(x)*b(x)`, where the weight w goes from 0 to
+// 1 with zero first and second derivatives at both ends. It's handy for stitching calibrations fitted for
+// different regimes.
+//
+// The weight w is a cubic spline (the integral of a quadratic B-spline over the transition), and the result is
+// exact, so its degree is 3 more than the larger degree of a and b. The splines must have the same domain (see
+// WithClosedDomain) and extrapolation (with [ExtrapolateLinear] the left tail follows a and the right tail follows b), and the transition
+// interval must be within the domain.
+//
+// Both splines must have their control points set.
+func Blend(a, b *BSpline, x0, width float64) *BSpline {
+	domainMin, domainMax := checkCombinable("bsplines.Blend", a, b)
+	start, end := x0-width/2, x0+width/2
+	if !(width > 0) || start <= domainMin || end >= domainMax {
+		panicf(ErrOutOfDomain, "bsplines.Blend(x0=%g, width=%g) requires width > 0 and the transition [%g, %g] strictly inside the domain [%g, %g]",
+			x0, width, start, end, domainMin, domainMax)
+	}
+	degree := max(a.degree, b.degree) + blendDegree
+	// The weight is C² at its knots.
+	weightKnots := make([]float64, 0, 2*(degree+1)+(blendDegree+1)*(degree-2))
+	for range degree + 1 {
+		weightKnots = append(weightKnots, domainMin)
+	}
+	for ii := range blendDegree + 1 {
+		knot := start + width*float64(ii)/blendDegree
+		if ii == blendDegree {
+			knot = end
+		}
+		for range degree - 2 {
+			weightKnots = append(weightKnots, knot)
+		}
+	}
+	for range degree + 1 {
+		weightKnots = append(weightKnots, domainMax)
+	}
+	expandedKnots := MergeKnots(MergeKnots(a.elevatedKnots(degree), b.elevatedKnots(degree)), weightKnots)
+	result := newFromExpandedKnots(degree, expandedKnots).WithExtrapolation(a.extrapolation).WithClosedDomain(a.closedDomain)
+	return result.WithControlPoints(result.QuasiInterpolate(func(x float64) float64 {
+		w := blendWeight((x - start) / width)
+		return (1-w)*a.Evaluate(x) + w*b.Evaluate(x)
+	}))
+}
+
+// blendDegree is the degree of the weight used by Blend.
+const blendDegree = 3
+
+// blendWeight is the weight of Blend at t, the position relative to the transition: the integral of the uniform
+// quadratic B-spline over [0, 1], so it's 0 for t <= 0 and 1 for t >= 1.
+func blendWeight(t float64) float64 {
+	u := 3 * t
+	switch {
+	case u <= 0:
+		return 0
+	case u < 1:
+		return u * u * u / 6
+	case u < 2:
+		return (-2*u*u*u + 9*u*u - 9*u + 3) / 6
+	case u < 3:
+		return 1 - (3-u)*(3-u)*(3-u)/6
+	default:
+		return 1
+	}
+}
+
+// checkCombinable checks that a and b have their control points set, and the same domain (closed or not, see
+// WithClosedDomain) and extrapolation. It returns the domain.
+func checkCombinable(name string, a, b *BSpline) (domainMin, domainMax float64) {
+	for ii, s := range []*BSpline{a, b} {
+		if len(s.controlPoints) == 0 {
+			panicf(ErrControlPointsNotSet, "%s requires the control points of both splines to be set, spline #%d doesn't have them", name, ii)
+		}
+	}
+	aMin, aMax := a.Domain()
+	bMin, bMax := b.Domain()
+	if aMin != bMin || aMax != bMax || a.closedDomain != b.closedDomain || a.extrapolation != b.extrapolation {
+		panicf(ErrIncompatibleSplines, "%s requires splines with the same domain (closed or not) and extrapolation: "+
+			"got domain [%g, %g] (closed=%v) and %s, and domain [%g, %g] (closed=%v) and %s",
+			name, aMin, aMax, a.closedDomain, a.extrapolation, bMin, bMax, b.closedDomain, b.extrapolation)
+	}
+	return aMin, aMax
+}
+
+// elevatedKnots returns the expanded knots of the clamped B-spline of the given degree (at least the degree of b) over
+// the domain of b, whose space contains b: a knot of multiplicity m in a spline of degree d is C^(d-m) there, which
+// requires multiplicity `m+degree-d`. The spaces of several B-splines are combined with MergeKnots.
+func (b *BSpline) elevatedKnots(degree int) []float64 {
+	knots := b.Knots()
+	domainMin, domainMax := b.Domain()
+	expandedKnots := make([]float64, 0, 2*degree+len(knots)*(degree-b.degree+1))
+	for range degree {
+		expandedKnots = append(expandedKnots, domainMin)
+	}
+	for ii, knot := range knots {
+		expandedKnots = append(expandedKnots, knot)
+		if ii > 0 && ii < len(knots)-1 && knot != knots[ii+1] {
+			// Last repeat of an interior knot.
+			for range degree - b.degree {
+				expandedKnots = append(expandedKnots, knot)
+			}
+		}
+	}
+	for range degree {
+		expandedKnots = append(expandedKnots, domainMax)
+	}
+	return expandedKnots
+}
+
+// EquivalentCurves returns whether a and b represent the same curve, up to tol, even if they have different knots or
+// degrees: e.g. a B-spline and its refinement (see Refine) are equivalent, while their control points are not
+// comparable directly.
+//
+// Both are represented exactly in the smallest common space -- the larger degree and the union of the knots, as in
+// Add -- and their control points there are compared: they are equivalent if no control point differs by more than
+// tol. Since the basis functions are non-negative and sum to 1, this guarantees `|a(x) - b(x)| <= tol` over the
+// domain. Splines with different domains or extrapolations are never equivalent.
+//
+// Both splines must have their control points set.
+func EquivalentCurves(a, b *BSpline, tol float64) bool {
+	for ii, s := range []*BSpline{a, b} {
+		if len(s.controlPoints) == 0 {
+			panicf(ErrControlPointsNotSet, "bsplines.EquivalentCurves requires the control points of both splines to be set, spline #%d doesn't have them", ii)
+		}
+	}
+	aMin, aMax := a.Domain()
+	bMin, bMax := b.Domain()
+	if aMin != bMin || aMax != bMax || a.extrapolation != b.extrapolation {
+		return false
+	}
+	degree := max(a.degree, b.degree)
+	common := newFromExpandedKnots(degree, MergeKnots(a.elevatedKnots(degree), b.elevatedKnots(degree)))
+	aControl, bControl := common.QuasiInterpolate(a.Evaluate), common.QuasiInterpolate(b.Evaluate)
+	for ii, value := range aControl {
+		if math.Abs(value-bControl[ii]) > tol {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,239 @@
+package bsplines
+
+import (
+	"iter"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// EvaluateBatch evaluates the B-spline on each of the xs values, and returns a newly allocated slice with the results.
+// It is equivalent to calling Evaluate on each value, but faster: the scratch buffers are allocated only once, and
+// the knot span of the previous value is tried first, which makes it very cheap for sorted or clustered inputs.
+//
+// One must set the control points using WithControlPoints before calling this function.
+func (b *BSpline) EvaluateBatch(xs []float64) []float64 {
+	output := make([]float64, len(xs))
+	b.EvaluateBatchInto(xs, output)
+	return output
+}
+
+// EvaluateBatchInto is like EvaluateBatch, but writes the results into output, which must have the same length as
+// xs. It doesn't allocate any memory for B-splines of degree up to 7.
+func (b *BSpline) EvaluateBatchInto(xs, output []float64) {
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.EvaluateBatch() require control points to be set using BSpline.WithControlPoints()")
+	}
+	if len(output) != len(xs) {
+		panicf(ErrInvalidArgument, "BSpline.EvaluateBatchInto() requires len(output)=%d to be equal to len(xs)=%d", len(output), len(xs))
+	}
+	var scratch [8]float64
+	basis := scratch[:]
+	if b.degree+1 > len(scratch) {
+		basis = make([]float64, b.degree+1)
+	}
+	b.evaluateBatchInto(xs, output, basis)
+}
+
+// Map applies the B-spline element-wise to src, and returns the results in dst, reusing its storage if it has
+// enough capacity (otherwise a new slice is allocated), like data-frame pipelines apply a calibration to a column:
+//
+//	column = b.Map(column, column) // In-place.
+//	calibrated := b.Map(nil, column) // New slice.
+//
+// dst can be the same slice as src, but it must not otherwise overlap it. The returned slice has len(src) elements.
+// See EvaluateBatchInto.
+func (b *BSpline) Map(dst, src []float64) []float64 {
+	if cap(dst) < len(src) {
+		dst = make([]float64, len(src))
+	}
+	dst = dst[:len(src)]
+	b.EvaluateBatchInto(src, dst)
+	return dst
+}
+
+// evaluateBatchInto implements EvaluateBatchInto, using basis as scratch space -- it must have at least `degree+1`
+// elements.
+//
+// The validation and the configuration checks are done once, out of the loop over xs.
+func (b *BSpline) evaluateBatchInto(xs, output, basis []float64) {
+	domainMin, domainMax := b.Domain()
+	closedDomain := b.closedDomain
+	firstFastSpan, lastFastSpan := b.uniformCubicSpans()
+	basis = basis[:b.degree+1]
+	span := b.degree
+	for ii, x := range xs {
+		if !(x >= domainMin && (x < domainMax || (closedDomain && x == domainMax))) {
+			// Out of the domain or NaN.
+			output[ii] = b.evaluateOutside(x)
+			continue
+		}
+		span = b.spanIndexWithHint(x, span)
+		if span >= firstFastSpan && span <= lastFastSpan {
+			output[ii] = b.evaluateUniformCubic(span, x)
+			continue
+		}
+		b.localBasis(span, x, b.degree, basis)
+		output[ii] = b.combine(span, basis)
+	}
+}
+
+// spanIndexWithHint returns the same as SpanIndex, but first checks whether x is in the hint span or in
+// the next one.
+func (b *BSpline) spanIndexWithHint(x float64, hint int) int {
+	lastSpan := b.NumControlPoints() - 1
+	for span := hint; span <= min(hint+1, lastSpan); span++ {
+		if b.expandedKnots[span] <= x && (x < b.expandedKnots[span+1] || span == lastSpan) {
+			return span
+		}
+	}
+	return b.SpanIndex(x)
+}
+
+// EvaluateLinspace samples the B-spline on n evenly spaced values from start to end (inclusive, like NumPy's
+// linspace), and returns the sampled xs and the corresponding values ys.
+//
+// The samples are sorted, so the knot spans are walked only once, see EvaluateBatch. It panics if n is negative.
+func (b *BSpline) EvaluateLinspace(start, end float64, n int) (xs, ys []float64) {
+	if n < 0 {
+		panicf(ErrInvalidArgument, "BSpline.EvaluateLinspace(n=%d) requires n >= 0", n)
+	}
+	xs = make([]float64, n)
+	for ii := range xs {
+		if n == 1 {
+			xs[ii] = start
+			break
+		}
+		xs[ii] = start + (end-start)*float64(ii)/float64(n-1)
+	}
+	return xs, b.EvaluateBatch(xs)
+}
+
+// Samples returns an iterator over n evenly spaced values x from start to end (inclusive), and the B-spline value
+// at each of them. It's like EvaluateLinspace, but it streams the (x, y) pairs without allocating slices, e.g. to
+// pipe them into a plot or a CSV writer:
+//
+//	for x, y := range b.Samples(0, 1, 1000) {
+//		fmt.Printf("%g,%g\n", x, y)
+//	}
+func (b *BSpline) Samples(start, end float64, n int) iter.Seq2[float64, float64] {
+	return func(yield func(float64, float64) bool) {
+		if n <= 0 {
+			return
+		}
+		scratch := b.NewEvalScratch()
+		for ii := range n {
+			x := start
+			if n > 1 {
+				x = start + (end-start)*float64(ii)/float64(n-1)
+			}
+			if !yield(x, scratch.Evaluate(x)) {
+				return
+			}
+		}
+	}
+}
+
+// EvaluateBank evaluates at x a bank of B-splines that share the knots, degree and extrapolation of b, but each with
+// its own control points: bank[i] holds the control points of the i-th B-spline. The basis functions are computed
+// only once, so it's efficient to evaluate thousands of B-splines at the same x -- e.g. per-feature calibrations.
+//
+// It returns a newly allocated slice with one value per B-spline. The control points of b are not used.
+func (b *BSpline) EvaluateBank(bank [][]float64, x float64) []float64 {
+	output := make([]float64, len(bank))
+	b.EvaluateBankInto(bank, x, output)
+	return output
+}
+
+// EvaluateBankInto is like EvaluateBank, but writes the results into output, which must have the same length as bank.
+func (b *BSpline) EvaluateBankInto(bank [][]float64, x float64, output []float64) {
+	if len(output) != len(bank) {
+		panicf(ErrInvalidArgument, "BSpline.EvaluateBankInto() requires len(output)=%d to be equal to len(bank)=%d", len(output), len(bank))
+	}
+	numControlPoints := b.NumControlPoints()
+	for ii, control := range bank {
+		if len(control) != numControlPoints {
+			panicf(ErrControlPointCount, "BSpline.EvaluateBank() expected %d control points for each B-spline, but bank[%d] has %d", numControlPoints, ii, len(control))
+		}
+	}
+	if math.IsNaN(x) {
+		for ii := range output {
+			output[ii] = x
+		}
+		return
+	}
+	if !b.inDomain(x) {
+		for ii, control := range bank {
+			output[ii] = b.extrapolateControlPoints(control, x)
+		}
+		return
+	}
+	span := b.SpanIndex(x)
+	basis := make([]float64, b.degree+1)
+	b.localBasis(span, x, b.degree, basis)
+	for ii, control := range bank {
+		control = control[span-b.degree : span+1]
+		var result float64
+		for r, weight := range basis {
+			result += weight * control[r]
+		}
+		output[ii] = result
+	}
+}
+
+// minParallelChunk is the minimum number of inputs evaluated by each goroutine in EvaluateBatchParallelInto,
+// so the overhead of starting goroutines is never dominant.
+const minParallelChunk = 1024
+
+// EvaluateBatchParallel is like EvaluateBatch, but splits xs in contiguous chunks evaluated concurrently by up to
+// numWorkers goroutines. If numWorkers <= 0, it uses runtime.GOMAXPROCS(0) workers.
+//
+// It returns a newly allocated slice with the results. Notice that a TraceHook, if set, is called concurrently.
+func (b *BSpline) EvaluateBatchParallel(xs []float64, numWorkers int) []float64 {
+	output := make([]float64, len(xs))
+	b.EvaluateBatchParallelInto(xs, output, numWorkers)
+	return output
+}
+
+// EvaluateBatchParallelInto is like EvaluateBatchParallel, but writes the results into output, which must have the
+// same length as xs.
+//
+// Invalid arguments panic in the calling goroutine, and so does a panic in any of the workers (e.g. in a TraceHook),
+// after all of them finish, so they can be recovered as usual.
+func (b *BSpline) EvaluateBatchParallelInto(xs, output []float64, numWorkers int) {
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.EvaluateBatchParallelInto() require control points to be set using BSpline.WithControlPoints()")
+	}
+	if len(output) != len(xs) {
+		panicf(ErrInvalidArgument, "BSpline.EvaluateBatchParallelInto() requires len(output)=%d to be equal to len(xs)=%d", len(output), len(xs))
+	}
+	if numWorkers <= 0 {
+		numWorkers = runtime.GOMAXPROCS(0)
+	}
+	numWorkers = min(numWorkers, (len(xs)+minParallelChunk-1)/minParallelChunk)
+	if numWorkers <= 1 {
+		b.EvaluateBatchInto(xs, output)
+		return
+	}
+	chunkSize := (len(xs) + numWorkers - 1) / numWorkers
+	var wg sync.WaitGroup
+	var panicOnce sync.Once
+	var workerPanic any
+	for start := 0; start < len(xs); start += chunkSize {
+		end := min(start+chunkSize, len(xs))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					panicOnce.Do(func() { workerPanic = r })
+				}
+			}()
+			b.EvaluateBatchInto(xs[start:end], output[start:end])
+		}()
+	}
+	wg.Wait()
+	if workerPanic != nil {
+		panic(workerPanic)
+	}
+}
@@ -0,0 +1,111 @@
+// Package bench measures the performance of B-splines evaluation and fitting on the current machine, so users can
+// choose the degree and number of control points based on their serving budgets.
+//
+// Use Run with a Config, and inspect the returned Result.
+package bench
+
+import (
+	"github.com/gomlx/bsplines"
+	"github.com/gomlx/exceptions"
+	"math/rand/v2"
+	"time"
+)
+
+// Config of a benchmark.
+type Config struct {
+	// Degree of the B-spline.
+	Degree int
+
+	// NumControlPoints of the B-spline, evenly spaced.
+	NumControlPoints int
+
+	// BatchSize is the number of inputs evaluated in each batch call.
+	BatchSize int
+
+	// NumFitPoints is the number of data points used to measure the fitting time.
+	NumFitPoints int
+
+	// MinDuration is the minimum duration of each measurement.
+	MinDuration time.Duration
+}
+
+// DefaultConfig returns a Config for a cubic B-spline with 32 control points.
+func DefaultConfig() Config {
+	return Config{
+		Degree:           3,
+		NumControlPoints: 32,
+		BatchSize:        1024,
+		NumFitPoints:     10_000,
+		MinDuration:      100 * time.Millisecond,
+	}
+}
+
+// Result of a benchmark: the throughput of each evaluation method, in evaluations per second, and the time to fit
+// the control points with bsplines.BSpline.FitControlPoints.
+type Result struct {
+	Config Config
+
+	// Evaluate is the throughput of BSpline.Evaluate, called for each input.
+	Evaluate float64
+
+	// EvaluateBatch is the throughput of BSpline.EvaluateBatchInto, with random (unsorted) inputs.
+	EvaluateBatch float64
+
+	// Vectorized is the throughput of VectorizedEvaluator.EvaluateInto, with random (unsorted) inputs.
+	Vectorized float64
+
+	// Parallel is the throughput of BSpline.EvaluateBatchParallelInto, with all available cores.
+	Parallel float64
+
+	// FitTime is the time to fit the control points to Config.NumFitPoints data points.
+	FitTime time.Duration
+}
+
+// Run the benchmark with the given configuration, and returns the measured results.
+// It takes at least `4*config.MinDuration`, plus the fitting time.
+func Run(config Config) Result {
+	if config.BatchSize <= 0 || config.NumFitPoints <= 0 {
+		exceptions.Panicf("bench.Run() requires BatchSize and NumFitPoints > 0, got %+v", config)
+	}
+	rng := rand.New(rand.NewPCG(42, 42))
+	b := bsplines.RandomBSpline(rng, config.Degree, config.NumControlPoints)
+	xs := make([]float64, config.BatchSize)
+	for ii := range xs {
+		xs[ii] = rng.Float64()
+	}
+	output := make([]float64, len(xs))
+	result := Result{Config: config}
+	result.Evaluate = throughput(config, func() {
+		for ii, x := range xs {
+			output[ii] = b.Evaluate(x)
+		}
+	})
+	result.EvaluateBatch = throughput(config, func() { b.EvaluateBatchInto(xs, output) })
+	vectorized := b.Vectorized()
+	result.Vectorized = throughput(config, func() { vectorized.EvaluateInto(xs, output) })
+	result.Parallel = throughput(config, func() { b.EvaluateBatchParallelInto(xs, output, 0) })
+
+	fitXs, fitYs := make([]float64, config.NumFitPoints), make([]float64, config.NumFitPoints)
+	for ii := range fitXs {
+		fitXs[ii] = float64(ii) / float64(config.NumFitPoints)
+		fitYs[ii] = b.Evaluate(fitXs[ii]) + 0.01*rng.NormFloat64()
+	}
+	start := time.Now()
+	b.FitControlPoints(fitXs, fitYs)
+	result.FitTime = time.Since(start)
+	return result
+}
+
+// throughput calls fn, that evaluates config.BatchSize inputs, repeatedly for at least config.MinDuration, and
+// returns the number of evaluations per second.
+func throughput(config Config, fn func()) float64 {
+	var numCalls int
+	start := time.Now()
+	for {
+		fn()
+		numCalls++
+		if elapsed := time.Since(start); elapsed >= config.MinDuration {
+			return float64(numCalls*config.BatchSize) / elapsed.Seconds()
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package bench
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	config := DefaultConfig()
+	config.MinDuration = time.Millisecond
+	config.NumFitPoints = 1000
+	result := Run(config)
+	assert.Equal(t, config, result.Config)
+	assert.Greater(t, result.Evaluate, 0.0)
+	assert.Greater(t, result.EvaluateBatch, 0.0)
+	assert.Greater(t, result.Vectorized, 0.0)
+	assert.Greater(t, result.Parallel, 0.0)
+	assert.Greater(t, result.FitTime, time.Duration(0))
+}
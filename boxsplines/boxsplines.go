@@ -0,0 +1,143 @@
+// Package boxsplines is an EXPERIMENTAL implementation of bivariate box splines on regular grids.
+//
+// Box splines generalize the uniform B-splines to multiple dimensions without using tensor products: they are
+// defined by a set of directions, and for well chosen directions (e.g. the three-direction box splines) they provide
+// isotropic smooth basis functions in 2D.
+//
+// The API is experimental and may change.
+package boxsplines
+
+import (
+	"github.com/gomlx/exceptions"
+	"math"
+)
+
+// BoxSpline is a bivariate box spline defined by its directions, each a 2D integer vector.
+//
+// The box spline M(x) is a piecewise polynomial of degree `len(directions)-2`, supported on the "box"
+// `{Σ t_i * directions[i] : 0 <= t_i < 1}`, and its integer translates form a partition of unity.
+type BoxSpline struct {
+	directions [][2]float64
+}
+
+// New creates a box spline with the given directions. There must be at least 2 directions, and they must span
+// the 2D plane (at least two non-parallel directions).
+func New(directions [][2]int) *BoxSpline {
+	if len(directions) < 2 {
+		exceptions.Panicf("boxsplines.New requires at least 2 directions, got %d", len(directions))
+	}
+	b := &BoxSpline{directions: make([][2]float64, len(directions))}
+	for ii, d := range directions {
+		b.directions[ii] = [2]float64{float64(d[0]), float64(d[1])}
+	}
+	if !spans(b.directions) {
+		exceptions.Panicf("boxsplines.New requires directions that span the plane, got %v", directions)
+	}
+	return b
+}
+
+// ThreeDirection creates the three-direction box spline with directions (1,0), (0,1) and (1,1), each repeated
+// the given number of times.
+//
+// ThreeDirection(1, 1, 1) is the piecewise linear Courant element (a "hat" over a hexagon of triangles), and
+// ThreeDirection(2, 2, 2) is the C² quartic box spline.
+func ThreeDirection(r, s, t int) *BoxSpline {
+	var directions [][2]int
+	for range r {
+		directions = append(directions, [2]int{1, 0})
+	}
+	for range s {
+		directions = append(directions, [2]int{0, 1})
+	}
+	for range t {
+		directions = append(directions, [2]int{1, 1})
+	}
+	return New(directions)
+}
+
+// Degree of the polynomial pieces of the box spline.
+func (b *BoxSpline) Degree() int {
+	return len(b.directions) - 2
+}
+
+// Support returns the bounding box of the support of the box spline.
+func (b *BoxSpline) Support() (minX, minY, maxX, maxY float64) {
+	for _, d := range b.directions {
+		minX, maxX = minX+min(d[0], 0), maxX+max(d[0], 0)
+		minY, maxY = minY+min(d[1], 0), maxY+max(d[1], 0)
+	}
+	return
+}
+
+// Evaluate the box spline at (x, y).
+//
+// It uses the de Boor-Höllig recurrence, which is exponential on the number of directions: it's meant for box
+// splines of low degree.
+//
+// On the lines where the polynomial pieces meet, the recurrence is only consistent if all the lower order box
+// splines follow the same convention, so the value returned is the limit approaching (x, y) from a fixed generic
+// direction. For continuous box splines this is the same as the value at (x, y), up to rounding errors.
+func (b *BoxSpline) Evaluate(x, y float64) float64 {
+	const epsilon = 1e-12
+	scale := max(1, math.Abs(x), math.Abs(y))
+	return evaluate(b.directions, [2]float64{x + epsilon*scale, y + epsilon*scale*math.Pi/10})
+}
+
+// evaluate the box spline with the given directions at p, using the recurrence
+// `(n-2) M_Ξ(p) = Σ_ξ t_ξ M_{Ξ\ξ}(p) + (1-t_ξ) M_{Ξ\ξ}(p-ξ)`, for any t such that `Ξt = p`.
+func evaluate(directions [][2]float64, p [2]float64) float64 {
+	n := len(directions)
+	if n == 2 {
+		// Base case: normalized characteristic function of the parallelogram.
+		a, b, c, d := directions[0][0], directions[1][0], directions[0][1], directions[1][1]
+		det := a*d - b*c
+		u := (d*p[0] - b*p[1]) / det
+		v := (-c*p[0] + a*p[1]) / det
+		if u >= 0 && u < 1 && v >= 0 && v < 1 {
+			return 1 / math.Abs(det)
+		}
+		return 0
+	}
+
+	// t = Ξᵀ(ΞΞᵀ)⁻¹p, the least squares solution of Ξt = p.
+	var g00, g01, g11 float64
+	for _, d := range directions {
+		g00 += d[0] * d[0]
+		g01 += d[0] * d[1]
+		g11 += d[1] * d[1]
+	}
+	det := g00*g11 - g01*g01
+	w0 := (g11*p[0] - g01*p[1]) / det
+	w1 := (-g01*p[0] + g00*p[1]) / det
+
+	var sum float64
+	reduced := make([][2]float64, n-1)
+	for ii, d := range directions {
+		copy(reduced, directions[:ii])
+		copy(reduced[ii:], directions[ii+1:])
+		if !spans(reduced) {
+			// Degenerate box spline: it's zero almost everywhere.
+			continue
+		}
+		t := d[0]*w0 + d[1]*w1
+		if t != 0 {
+			sum += t * evaluate(reduced, p)
+		}
+		if t != 1 {
+			sum += (1 - t) * evaluate(reduced, [2]float64{p[0] - d[0], p[1] - d[1]})
+		}
+	}
+	return sum / float64(n-2)
+}
+
+// spans returns whether the directions span the plane.
+func spans(directions [][2]float64) bool {
+	for ii, a := range directions {
+		for _, b := range directions[ii+1:] {
+			if a[0]*b[1]-a[1]*b[0] != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package boxsplines
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPartitionOfUnity(t *testing.T) {
+	for _, box := range []*BoxSpline{ThreeDirection(1, 1, 1), ThreeDirection(2, 1, 1), ThreeDirection(2, 2, 2), New([][2]int{{1, 0}, {0, 1}, {1, 0}, {0, 1}})} {
+		// A grid of ones large enough to cover the sampled points with every translate.
+		grid := make([][]float64, 10)
+		for ii := range grid {
+			grid[ii] = make([]float64, 10)
+			for jj := range grid[ii] {
+				grid[ii][jj] = 1
+			}
+		}
+		surface := NewSurface(box, grid)
+		for _, p := range [][2]float64{{4.5, 4.5}, {4.1, 5.3}, {5.7, 4.2}, {4.33, 4.66}} {
+			assert.InDeltaf(t, 1.0, surface.Evaluate(p[0], p[1]), 1e-9, "directions=%v at %v", box.directions, p)
+		}
+	}
+}
+
+func TestCourantElement(t *testing.T) {
+	box := ThreeDirection(1, 1, 1)
+	assert.Equal(t, 1, box.Degree())
+	// The Courant element is the piecewise linear "hat" function with peak 1 at (1, 1).
+	assert.InDelta(t, 1.0, box.Evaluate(1, 1), 1e-9)
+	assert.InDelta(t, 0.5, box.Evaluate(1.5, 1), 1e-9)
+	assert.InDelta(t, 0.0, box.Evaluate(2.5, 0.5), 1e-9)
+}
+
+func TestQuarticThreeDirection(t *testing.T) {
+	box := ThreeDirection(2, 2, 2)
+	assert.Equal(t, 4, box.Degree())
+	// Known values at the lattice points: 1/2 at the center, 1/12 on the six neighbors.
+	assert.InDelta(t, 0.5, box.Evaluate(2, 2), 1e-9)
+	for _, p := range [][2]float64{{1, 1}, {3, 3}, {1, 2}, {2, 1}, {3, 2}, {2, 3}} {
+		assert.InDeltaf(t, 1.0/12.0, box.Evaluate(p[0], p[1]), 1e-9, "at %v", p)
+	}
+}
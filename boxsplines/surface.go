@@ -0,0 +1,49 @@
+package boxsplines
+
+import (
+	"github.com/gomlx/exceptions"
+	"math"
+)
+
+// Surface is a function defined over a regular grid by the integer translates of a box spline, each weighted
+// by a control point: `f(x, y) = Σ_{i,j} controlPoints[i][j] * M(x-i, y-j)`.
+//
+// The control point `[i][j]` is associated with the translate of the box spline to the grid point (i, j), and
+// the surface is zero where no box spline translate reaches.
+type Surface struct {
+	box           *BoxSpline
+	controlPoints [][]float64
+}
+
+// NewSurface creates a surface from the box spline and the control points, shaped `[numX][numY]`.
+func NewSurface(box *BoxSpline, controlPoints [][]float64) *Surface {
+	if len(controlPoints) == 0 || len(controlPoints[0]) == 0 {
+		exceptions.Panicf("boxsplines.NewSurface requires a non-empty grid of control points")
+	}
+	for ii, row := range controlPoints {
+		if len(row) != len(controlPoints[0]) {
+			exceptions.Panicf("boxsplines.NewSurface requires a rectangular grid of control points, row 0 has %d columns, row %d has %d",
+				len(controlPoints[0]), ii, len(row))
+		}
+	}
+	return &Surface{box: box, controlPoints: controlPoints}
+}
+
+// Evaluate the surface at (x, y).
+func (s *Surface) Evaluate(x, y float64) float64 {
+	minX, minY, maxX, maxY := s.box.Support()
+	numX, numY := len(s.controlPoints), len(s.controlPoints[0])
+
+	// Translates (i, j) whose support contains (x, y): i+minX <= x < i+maxX.
+	firstI, lastI := max(int(math.Floor(x-maxX)), 0), min(int(math.Ceil(x-minX)), numX-1)
+	firstJ, lastJ := max(int(math.Floor(y-maxY)), 0), min(int(math.Ceil(y-minY)), numY-1)
+	var sum float64
+	for ii := firstI; ii <= lastI; ii++ {
+		for jj := firstJ; jj <= lastJ; jj++ {
+			if c := s.controlPoints[ii][jj]; c != 0 {
+				sum += c * s.box.Evaluate(x-float64(ii), y-float64(jj))
+			}
+		}
+	}
+	return sum
+}
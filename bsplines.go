@@ -9,7 +9,7 @@
 package bsplines
 
 import (
-	"github.com/gomlx/exceptions"
+	"math"
 	"slices"
 )
 
@@ -47,56 +47,171 @@ type BSpline struct {
 	degree                       int
 	expandedKnots, controlPoints []float64
 	extrapolation                ExtrapolationType
+	accuracy                     Accuracy
+	closedDomain                 bool
 
 	// knot(x-coordinate) value for controlPoints[1] and controlPoints[-1], used for
 	// linear extrapolation.
 	knotValueForControlPoint1, knotValueForControlPointM2 float64
+
+	// uniform is set if the knots are evenly spaced, in which case invKnotDelta is the inverse of the spacing: it
+	// enables O(1) span lookup, and the closed-form evaluation of cubic B-splines.
+	uniform      bool
+	invKnotDelta float64
+
+	// invKnotDeltas[jj][i] holds `1/(knots[i+jj]-knots[i])` of the expanded knots, for jj from 1 to degree, or 0 if
+	// the knots are repeated. It saves the divisions (and zero checks) of the basis functions computation.
+	invKnotDeltas [][]float64
+
+	// traceHook, if set, is called with each row of the basis functions triangle, see WithTraceHook.
+	traceHook TraceHook
+
+	// controlPointsND holds the vector-valued control points, shaped [numControlPoints][dim], see
+	// WithControlPointsND.
+	controlPointsND [][]float64
 }
 
+// TraceHook is called during evaluation with each row of the triangle of basis functions computed for x:
+// first with degree 0, and then up to the degree of the B-spline.
+//
+// The span is the index of the expanded knots such that `knots[span] <= x < knots[span+1]`, and basis[r]
+// (with `r` from 0 to degree) holds the value of the basis function of the given degree for the control point
+// `span-degree+r`.
+//
+// The basis slice is only valid during the call, and must not be changed.
+type TraceHook func(x float64, span, degree int, basis []float64)
+
 // New create a new B-spline with the given [degree] (`order == degree+1`).
 // To use it for evaluation, the control points must be given with [WithControlPoints].
 //
-// The [knots] must be sorted and not be repeated.
+// The [knots] must be sorted. Interior knots can be repeated, up to degree+1 times: a knot repeated m times lowers
+// the smoothness of the B-spline there to C^(degree-m) -- e.g. a C⁰ corner for m=degree, or a jump for m=degree+1.
+// The first and last knots can't be repeated, since they are already clamped. Each repetition adds one control
+// point, see also NewWithMultiplicities.
+//
 // Internally, [degree] extra values are inserted on the start and end of the knots vector, to clamp the endings.
 func New(degree int, knots []float64) *BSpline {
 	if len(knots) < 2 {
-		exceptions.Panicf("bsplines.New requires at least 2 knots, got %d instead", len(knots))
+		panicf(ErrNotEnoughKnots, "bsplines.New requires at least 2 knots, got %d instead", len(knots))
 	}
-	if !slices.IsSortedFunc(knots, func(a, b float64) int {
-		if a < b {
-			return -1
-		} else {
-			// We want repeated numbers to fail.
-			return 1
+	if !slices.IsSorted(knots) {
+		panicf(ErrKnotsNotSorted, "bsplines.New requires knots to be sorted, got %v instead", knots)
+	}
+	if knots[0] == knots[1] || at(knots, -1) == at(knots, -2) {
+		panicf(ErrInvalidArgument, "bsplines.New requires the first and last knots not to be repeated (they are already clamped), got %v", knots)
+	}
+	for ii, repeats := 1, 1; ii < len(knots); ii++ {
+		if knots[ii] != knots[ii-1] {
+			repeats = 1
+			continue
+		}
+		repeats++
+		if repeats > degree+1 {
+			panicf(ErrInvalidArgument, "bsplines.New requires knots repeated at most degree+1=%d times, knot %g is repeated more", degree+1, knots[ii])
 		}
-	}) {
-		exceptions.Panicf("bsplines.New requires knots to be strictly increasing (no repeats), got %v instead", knots)
 	}
+	expandedKnots := make([]float64, len(knots)+2*degree)
+	for ii := range degree {
+		// Set clamping points.
+		expandedKnots[ii] = knots[0]
+		expandedKnots[len(expandedKnots)-ii-1] = at(knots, -1)
+	}
+	copy(expandedKnots[degree:len(expandedKnots)-degree], knots)
+	return newFromExpandedKnots(degree, expandedKnots)
+}
+
+// checkStrictlyIncreasing panics if there are less than 2 knots, or if they are not strictly increasing.
+func checkStrictlyIncreasing(name string, knots []float64) {
+	if len(knots) < 2 {
+		panicf(ErrNotEnoughKnots, "%s requires at least 2 knots, got %d instead", name, len(knots))
+	}
+	for ii := 1; ii < len(knots); ii++ {
+		if !(knots[ii] > knots[ii-1]) {
+			panicf(ErrKnotsNotSorted, "%s requires knots to be strictly increasing (no repeats), got %v instead", name, knots)
+		}
+	}
+}
+
+// newFromExpandedKnots creates the B-spline from the already expanded knots (see BSpline.ExpandedKnots), without
+// checking for repeated knots: used internally to build B-splines with interior knots of higher multiplicity.
+func newFromExpandedKnots(degree int, expandedKnots []float64) *BSpline {
 	b := &BSpline{
 		degree:        degree,
-		expandedKnots: make([]float64, len(knots)+2*degree),
+		expandedKnots: expandedKnots,
 		extrapolation: ExtrapolateConstant,
 	}
-	for ii := range degree {
-		// Set clamping points.
-		b.expandedKnots[ii] = knots[0]
-		b.expandedKnots[len(b.expandedKnots)-ii-1] = at(knots, -1)
-	}
-	copy(b.expandedKnots[degree:len(b.expandedKnots)-degree], knots)
 
 	// Find control points x-coordinate values:
+	// A single control point only happens for degree 0 with 2 knots (e.g. derivatives), in which case they are not used.
 	controlX := b.ControlPointsX()
-	b.knotValueForControlPoint1, b.knotValueForControlPointM2 = controlX[1], at(controlX, -2)
+	if len(controlX) >= 2 {
+		b.knotValueForControlPoint1, b.knotValueForControlPointM2 = controlX[1], at(controlX, -2)
+	}
+
+	domainMin, domainMax := b.Domain()
+	b.uniform = domainMax > domainMin && b.IsUniform(1e-12*(domainMax-domainMin))
+	if b.uniform {
+		b.invKnotDelta = float64(len(b.Knots())-1) / (domainMax - domainMin)
+	}
+	b.invKnotDeltas = reciprocalKnotDeltas(expandedKnots, degree)
 	return b
 }
 
+// reciprocalKnotDeltas returns the table of `1/(knots[i+jj]-knots[i])` for jj from 1 to maxDelta, indexed
+// `[jj][i]`, with 0 for repeated knots. The row for jj=0 is nil.
+func reciprocalKnotDeltas(knots []float64, maxDelta int) [][]float64 {
+	table := make([][]float64, maxDelta+1)
+	for jj := 1; jj <= maxDelta && jj < len(knots); jj++ {
+		row := make([]float64, len(knots)-jj)
+		for ii := range row {
+			if delta := knots[ii+jj] - knots[ii]; delta != 0 {
+				row[ii] = 1 / delta
+			}
+		}
+		table[jj] = row
+	}
+	return table
+}
+
+// NewWithMultiplicities creates a new B-spline like New, but with each interior knot repeated mult[i] times,
+// which lowers the smoothness of the B-spline at that knot to C^(degree-mult[i]). E.g. a multiplicity equal to
+// the degree makes the knot a kink (C^0): a hard break in the slope of an otherwise smooth calibration.
+//
+// The [knots] must be strictly increasing, and mult must have one multiplicity per knot: 1 for the first and
+// last knots (they are already clamped), and from 1 to degree for the interior knots. Each repetition adds one
+// control point, so there must be `sum(mult)+degree-1` control points.
+//
+// It's equivalent to calling New with the knots repeated, but it's handy when the multiplicities are given apart.
+func NewWithMultiplicities(degree int, knots []float64, mult []int) *BSpline {
+	if len(mult) != len(knots) {
+		panicf(ErrInvalidArgument, "bsplines.NewWithMultiplicities requires one multiplicity per knot, got %d multiplicities for %d knots", len(mult), len(knots))
+	}
+	checkStrictlyIncreasing("bsplines.NewWithMultiplicities", knots)
+	b := New(degree, knots)
+	if mult[0] != 1 || at(mult, -1) != 1 {
+		panicf(ErrInvalidArgument, "bsplines.NewWithMultiplicities requires multiplicity 1 for the first and last knots, got %v", mult)
+	}
+	expandedKnots := make([]float64, 0, len(b.expandedKnots))
+	expandedKnots = append(expandedKnots, b.expandedKnots[:degree]...)
+	for ii, knot := range knots {
+		if mult[ii] < 1 || mult[ii] > degree {
+			panicf(ErrInvalidArgument, "bsplines.NewWithMultiplicities requires multiplicities from 1 to degree (%d), got mult[%d]=%d", degree, ii, mult[ii])
+		}
+		for range mult[ii] {
+			expandedKnots = append(expandedKnots, knot)
+		}
+	}
+	expandedKnots = append(expandedKnots, b.expandedKnots[len(b.expandedKnots)-degree:]...)
+	return newFromExpandedKnots(degree, expandedKnots)
+}
+
 // NewRegular creates a new B-spline that is defined with enough knots for [numControlPoints].
 // The knots are created evenly spaced from 0.0 to 1.0.
 //
 // [numControlPoints] must be at least `degree + 1`.
 func NewRegular(degree, numControlPoints int) *BSpline {
 	if numControlPoints < degree+1 {
-		exceptions.Panicf("bsplines.NewRegular requires numControlPoints=%d >= 2", numControlPoints)
+		panicf(ErrControlPointCount, "bsplines.NewRegular requires numControlPoints=%d >= 2", numControlPoints)
 	}
 	numKnots := numControlPoints - degree + 1
 	knots := make([]float64, numKnots)
@@ -111,17 +226,45 @@ func NewRegular(degree, numControlPoints int) *BSpline {
 //
 // It must be set before evaluation. It can also be switched each time before an evaluation, it's a very cheap operation.
 // Notice the knots themselves cannot change -- create another B-spline if different knots are needed.
+// Since it changes the B-spline, use an Evaluator (see BSpline.Evaluator) to evaluate concurrently with different
+// control points.
 //
 // It returns itself so configuration calls can be cascaded.
 func (b *BSpline) WithControlPoints(controlPoints []float64) *BSpline {
 	numKnots := len(b.expandedKnots) - 2*b.degree
 	if len(controlPoints) != numKnots+b.degree-1 {
-		exceptions.Panicf("BSpline.WithControlPoints() with %d knots, expected %d control points (== `len(knots)+degree-1`), but got %d instead", numKnots, numKnots+b.degree-1, len(controlPoints))
+		panicf(ErrControlPointCount, "BSpline.WithControlPoints() with %d knots, expected %d control points (== `len(knots)+degree-1`), but got %d instead", numKnots, numKnots+b.degree-1, len(controlPoints))
 	}
 	b.controlPoints = controlPoints
 	return b
 }
 
+// WithEndDerivatives adjusts the second and the second-to-last control points, so that the B-spline attains the
+// given derivatives (slopes) at the start and at the end of its domain -- e.g. to blend smoothly into adjacent
+// analytic segments. The first and last control points (the values at the ends) are not changed.
+//
+// The control points must be set before, and they are copied before being changed. It requires degree >= 1 and at
+// least 4 control points, so the two ends can be adjusted independently.
+// With [ExtrapolateLinear], the slopes are also the slopes of the extrapolation.
+//
+// It returns itself so configuration calls can be cascaded.
+func (b *BSpline) WithEndDerivatives(leftSlope, rightSlope float64) *BSpline {
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.WithEndDerivatives() require control points to be set using BSpline.WithControlPoints()")
+	}
+	n := len(b.controlPoints)
+	if b.degree < 1 || n < 4 {
+		panicf(ErrControlPointCount, "BSpline.WithEndDerivatives() requires degree >= 1 and at least 4 control points, got degree %d and %d control points", b.degree, n)
+	}
+	// Derivative at the ends: q = p * (c_{i+1} - c_i) / (knot_{i+p+1} - knot_{i+1}), for i=0 and i=n-2.
+	control := slices.Clone(b.controlPoints)
+	p := float64(b.degree)
+	control[1] = control[0] + leftSlope*(b.expandedKnots[b.degree+1]-b.expandedKnots[1])/p
+	control[n-2] = control[n-1] - rightSlope*(b.expandedKnots[n-1+b.degree]-b.expandedKnots[n-1])/p
+	b.controlPoints = control
+	return b
+}
+
 // WithExtrapolation defines how the evaluation should extrapolate for values before the first knot or after the
 // last knot.
 //
@@ -133,6 +276,44 @@ func (b *BSpline) WithExtrapolation(e ExtrapolationType) *BSpline {
 	return b
 }
 
+// WithAccuracy defines how the basis functions are combined with the control points during evaluation, see
+// [Accuracy]. It's used by Evaluate, EvaluateWithGradient, EvaluateBatch and EvalScratch, but not by the
+// VectorizedEvaluator or the generic Evaluator.
+//
+// The default value is [AccuracyDefault].
+//
+// It returns itself so configuration calls can be cascaded.
+func (b *BSpline) WithAccuracy(accuracy Accuracy) *BSpline {
+	b.accuracy = accuracy
+	return b
+}
+
+// WithClosedDomain defines whether the domain of the B-spline includes its right end (the last knot).
+//
+// By default, the domain is the half-open interval `[first knot, last knot)`, and the last knot is already evaluated
+// by the extrapolation -- which makes a difference for [ExtrapolateZero], where it evaluates to 0. With
+// WithClosedDomain(true) the domain is `[first knot, last knot]`, and the last knot evaluates to the B-spline
+// (the value of the last control point for clamped B-splines), which is usually what is expected when sampling
+// the domain including its ends.
+//
+// Notice the GoMLX evaluator always uses the half-open domain.
+//
+// It returns itself so configuration calls can be cascaded.
+func (b *BSpline) WithClosedDomain(closed bool) *BSpline {
+	b.closedDomain = closed
+	return b
+}
+
+// WithTraceHook sets a hook that is called with the intermediary values of the basis functions computed during
+// evaluation (see [TraceHook]), useful for step-by-step visualizations or to debug numeric issues.
+// Set it to nil (the default) to disable it.
+//
+// It returns itself so configuration calls can be cascaded.
+func (b *BSpline) WithTraceHook(hook TraceHook) *BSpline {
+	b.traceHook = hook
+	return b
+}
+
 // Degree of the B-spline.
 func (b *BSpline) Degree() int { return b.degree }
 
@@ -141,16 +322,75 @@ func (b *BSpline) Knots() []float64 {
 	return b.expandedKnots[b.degree : len(b.expandedKnots)-b.degree]
 }
 
+// Domain returns the interval `[min, max]` where the B-spline is defined, outside of it the B-spline is
+// extrapolated (see [ExtrapolationType]). The max value itself is only included if configured with WithClosedDomain.
+//
+// Prefer this to indexing the first and last knots by hand.
+func (b *BSpline) Domain() (min, max float64) {
+	return b.expandedKnots[b.degree], b.expandedKnots[len(b.expandedKnots)-b.degree-1]
+}
+
+// inDomain returns whether x is in the domain of the B-spline, where it's not extrapolated. See WithClosedDomain.
+// It returns false for NaN.
+func (b *BSpline) inDomain(x float64) bool {
+	domainMin, domainMax := b.Domain()
+	return x >= domainMin && (x < domainMax || (b.closedDomain && x == domainMax))
+}
+
 // Extrapolation returns the extrapolation currently configured.
 func (b *BSpline) Extrapolation() ExtrapolationType {
 	return b.extrapolation
 }
 
 // ExpandedKnots return the knots with the clamps (degree repeated values from the beginning and end of the vector).
+//
+// This is the full knot vector used by the Cox-de Boor recursion, with `len(Knots())+2*degree` elements,
+// and it's the one expected by most B-spline literature and other libraries.
+// Values must not be changed -- if one needs to change the knots, create a new B-Spline.
 func (b *BSpline) ExpandedKnots() []float64 {
 	return b.expandedKnots
 }
 
+// IsClamped returns whether the expanded knots have `degree+1` repeated values at the start and at the end, which
+// makes the B-spline start at the first control point and end at the last one.
+func (b *BSpline) IsClamped() bool {
+	first, last := b.expandedKnots[0], at(b.expandedKnots, -1)
+	for ii := range b.degree + 1 {
+		if b.expandedKnots[ii] != first || at(b.expandedKnots, -ii-1) != last {
+			return false
+		}
+	}
+	return true
+}
+
+// IsUniform returns whether the knots (not the expanded knots) are evenly spaced, with the difference
+// between any two consecutive intervals less or equal to tol.
+func (b *BSpline) IsUniform(tol float64) bool {
+	knots := b.Knots()
+	delta := knots[1] - knots[0]
+	for ii := 2; ii < len(knots); ii++ {
+		if math.Abs((knots[ii]-knots[ii-1])-delta) > tol {
+			return false
+		}
+	}
+	return true
+}
+
+// KnotMultiplicities returns the distinct values of the expanded knots, and the number of times each one is repeated.
+//
+// For a clamped B-spline, the first and last values have multiplicity `degree+1`.
+func (b *BSpline) KnotMultiplicities() (values []float64, multiplicities []int) {
+	for _, knot := range b.expandedKnots {
+		if len(values) > 0 && at(values, -1) == knot {
+			multiplicities[len(multiplicities)-1]++
+			continue
+		}
+		values = append(values, knot)
+		multiplicities = append(multiplicities, 1)
+	}
+	return
+}
+
 // NumControlPoints returns the expected number of control points for the current knots.
 func (b *BSpline) NumControlPoints() int {
 	return len(b.Knots()) + b.degree - 1
@@ -184,43 +424,310 @@ func (b *BSpline) ControlPointsX() []float64 {
 }
 
 // Evaluate 1D B-spline on the value of x (some text call this the parameter value, also referred as `t`).
-// This function is the simplest version, but not very fast, and run on CPU.
+// It runs on CPU, using the iterative De Boor algorithm: it finds the knot span of x (see SpanIndex), and only
+// calculates the `degree+1` basis functions that are non-zero there.
 //
 // One must set the control points using WithControlPoints before calling this function.
 func (b *BSpline) Evaluate(x float64) float64 {
 	if len(b.controlPoints) == 0 {
-		exceptions.Panicf("BSpline.Evaluate() require control points to be set using BSpline.WithControlPoints()")
+		panicf(ErrControlPointsNotSet, "BSpline.Evaluate() require control points to be set using BSpline.WithControlPoints()")
+	}
+	if !b.inDomain(x) {
+		// Out of the domain or NaN.
+		return b.evaluateOutside(x)
+	}
+	return b.evaluateSpan(b.SpanIndex(x), x, make([]float64, b.degree+1))
+}
+
+// evaluateOutside returns the value of the B-spline for x outside the domain: the extrapolation, or NaN if x is NaN.
+func (b *BSpline) evaluateOutside(x float64) float64 {
+	if math.IsNaN(x) {
+		return x
 	}
-	if x < b.expandedKnots[0] || x >= b.expandedKnots[len(b.expandedKnots)-1] {
-		return b.extrapolate(x)
+	return b.extrapolate(x)
+}
+
+// uniformCubicSpans returns the range of spans that can be evaluated with evaluateUniformCubic, or an empty range
+// (first > last) if the B-spline is not a uniform cubic, or if it's configured with a TraceHook or AccuracyCompensated.
+func (b *BSpline) uniformCubicSpans() (first, last int) {
+	if !b.uniform || b.degree != 3 || b.traceHook != nil || b.accuracy != AccuracyDefault {
+		return 0, -1
+	}
+	return 2*b.degree - 1, b.NumControlPoints() - b.degree
+}
+
+// evaluateSpan evaluates the B-spline polynomial piece of the given knot span (see SpanIndex) at x.
+// The basis is used as scratch space, and must have at least `degree+1` elements.
+func (b *BSpline) evaluateSpan(span int, x float64, basis []float64) float64 {
+	if first, last := b.uniformCubicSpans(); span >= first && span <= last {
+		return b.evaluateUniformCubic(span, x)
+	}
+	b.localBasis(span, x, b.degree, basis)
+	return b.combine(span, basis[:b.degree+1])
+}
+
+// evaluateUniformCubic evaluates a cubic B-spline with evenly spaced knots, on a span whose basis functions don't
+// touch the clamped ends: the basis functions are then the same polynomials on every span, and the B-spline is
+// evaluated using the constant 4x4 cubic B-spline matrix and Horner's rule.
+func (b *BSpline) evaluateUniformCubic(span int, x float64) float64 {
+	u := (x - b.expandedKnots[span]) * b.invKnotDelta
+	c := b.controlPoints[span-3 : span+1]
+	a0 := (c[0] + 4*c[1] + c[2]) / 6
+	a1 := (c[2] - c[0]) / 2
+	a2 := (c[0] - 2*c[1] + c[2]) / 2
+	a3 := (-c[0] + 3*c[1] - 3*c[2] + c[3]) / 6
+	return a0 + u*(a1+u*(a2+u*a3))
+}
+
+// combine returns the sum of the control points of the span weighted by the basis functions, using the configured
+// accuracy.
+func (b *BSpline) combine(span int, basis []float64) float64 {
+	control := b.controlPoints[span-b.degree : span+1]
+	if b.accuracy == AccuracyCompensated {
+		return compensatedDot(basis, control)
 	}
 	var result float64
-	for controlPointIdx, controlPoint := range b.controlPoints {
-		basis := b.BasisFunction(controlPointIdx, b.degree, x)
-		result += controlPoint * basis
+	for r, weight := range basis {
+		result += weight * control[r]
 	}
 	return result
 }
 
+// EvaluateWithGradient evaluates the 1D B-spline at x, and its derivative with respect to x, in one pass.
+// It only computes the `degree+1` basis functions that are non-zero at x, and no derivative B-spline is created, so
+// it's handy for Newton iterations and physics integrators.
+//
+// Outside the knots the derivative is the one of the extrapolation: 0 for [ExtrapolateZero] and
+// [ExtrapolateConstant], and the slope of the linear tail for [ExtrapolateLinear].
+//
+// One must set the control points using WithControlPoints before calling this function.
+func (b *BSpline) EvaluateWithGradient(x float64) (value, dydx float64) {
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.EvaluateWithGradient() require control points to be set using BSpline.WithControlPoints()")
+	}
+	if !b.inDomain(x) {
+		if math.IsNaN(x) {
+			return x, x
+		}
+		return b.extrapolate(x), b.extrapolationSlope(x)
+	}
+	return b.evaluateSpanWithGradient(b.SpanIndex(x), x)
+}
+
+// EvaluateDerivative evaluates the derivative of the given order (0 for the value itself) of the B-spline at x,
+// without creating the derivative B-spline (see DerivativeN).
+//
+// Outside the domain it's consistent with the extrapolation: with [ExtrapolateLinear] the first derivative is the
+// actual slope of the linear tail (which is also the derivative at the ends of the domain), and higher orders are 0.
+// With [ExtrapolateZero] and [ExtrapolateConstant] all derivatives are 0.
+func (b *BSpline) EvaluateDerivative(x float64, order int) float64 {
+	return b.EvaluateDerivatives(x, order)[order]
+}
+
+// EvaluateDerivatives returns the value of the B-spline at x, and all its derivatives up to order k, in one pass
+// that shares the computation of the basis functions: the result has k+1 values, with the i-th derivative in
+// position i. Derivatives of order larger than the degree are 0.
+//
+// Outside the domain it follows the extrapolation, see EvaluateDerivative.
+func (b *BSpline) EvaluateDerivatives(x float64, k int) []float64 {
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.EvaluateDerivatives() require control points to be set using BSpline.WithControlPoints()")
+	}
+	if k < 0 {
+		panicf(ErrInvalidArgument, "BSpline.EvaluateDerivatives(k=%d) requires k >= 0", k)
+	}
+	results := make([]float64, k+1)
+	if math.IsNaN(x) {
+		for order := range results {
+			results[order] = x
+		}
+		return results
+	}
+	if !b.inDomain(x) {
+		results[0] = b.extrapolate(x)
+		if k >= 1 {
+			results[1] = b.extrapolationSlope(x)
+		}
+		return results
+	}
+	span := b.SpanIndex(x)
+	ders := newMatrix(k+1, b.degree+1)
+	b.basisDerivatives(span, x, k, ders, newBasisDerivativesScratch(b.degree))
+	for order, basis := range ders {
+		results[order] = b.combine(span, basis)
+	}
+	return results
+}
+
+// evaluateSpanWithGradient evaluates the B-spline polynomial piece of the given knot span (see SpanIndex) at x,
+// and its derivative. x doesn't need to be in the span.
+func (b *BSpline) evaluateSpanWithGradient(span int, x float64) (value, dydx float64) {
+	basis := make([]float64, b.degree+1)
+	b.localBasis(span, x, b.degree-1, basis)
+
+	// Derivative from the basis functions of degree-1, using the control points of the derivative:
+	// q_i = p * (c_{i+1} - c_i) / (knot_{i+p+1} - knot_{i+1})
+	if b.degree > 0 {
+		for r := range b.degree {
+			ii := span - b.degree + r
+			dydx += basis[r] * float64(b.degree) * (b.controlPoints[ii+1] - b.controlPoints[ii]) * b.invKnotDeltas[b.degree][ii+1]
+		}
+		b.localBasisStep(span, x, b.degree, basis)
+	}
+	value = b.combine(span, basis)
+	return
+}
+
+// SpanIndex returns the index k of the expanded knots (see ExpandedKnots) such that `knots[k] <= x < knots[k+1]`,
+// limited to the range `[degree, NumControlPoints()-1]`, where the B-spline is defined: values of x outside the
+// domain get the first or last span, and the last knot belongs to the last span.
+//
+// Only the control points `[k-degree, k]` affect the B-spline at x. For uniform knots the span is calculated
+// directly, in O(1), and otherwise it uses a binary search, so it's O(log(n)) on the number of knots.
+func (b *BSpline) SpanIndex(x float64) int {
+	low, high := b.degree, b.NumControlPoints()-1
+	if b.uniform {
+		// Outside the domain (also NaN, like in the binary search) before converting to int, which could overflow.
+		if !(x >= b.expandedKnots[low]) {
+			return low
+		}
+		if x >= b.expandedKnots[high] {
+			return high
+		}
+		// Direct calculation, adjusted for rounding errors.
+		span := min(max(b.degree+int((x-b.expandedKnots[b.degree])*b.invKnotDelta), low), high)
+		if span > low && x < b.expandedKnots[span] {
+			span--
+		} else if span < high && x >= b.expandedKnots[span+1] {
+			span++
+		}
+		return span
+	}
+	for low < high {
+		mid := (low + high + 1) / 2
+		if b.expandedKnots[mid] <= x {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	return low
+}
+
+// BasisFunctionsAt returns the `degree+1` basis functions that can be non-zero at x, and the index of the control
+// point affected by the first one: the B-spline at x is `Σ values[r] * controlPoints[firstIndex+r]`.
+// It's what is needed to build design matrices, or to compute the gradient of the B-spline with respect to the
+// control points.
+//
+// Outside the domain, the values are the weights of the extrapolation, which is also linear on the control
+// points: all zeros for [ExtrapolateZero], the first (or last) control point for [ExtrapolateConstant], and a
+// combination of the first two (or last two) for [ExtrapolateLinear].
+//
+// It doesn't require the control points to be set.
+func (b *BSpline) BasisFunctionsAt(x float64) (firstIndex int, values []float64) {
+	values = make([]float64, b.degree+1)
+	domainMin, domainMax := b.Domain()
+	if b.inDomain(x) {
+		span := b.SpanIndex(x)
+		b.localBasis(span, x, b.degree, values)
+		return span - b.degree, values
+	}
+	tooLow := x < domainMin
+	if !tooLow {
+		firstIndex = b.NumControlPoints() - b.degree - 1
+	}
+	switch b.extrapolation {
+	case ExtrapolateConstant:
+		if tooLow {
+			values[0] = 1
+		} else {
+			values[b.degree] = 1
+		}
+	case ExtrapolateLinear:
+		if b.degree == 0 {
+			values[0] = 1
+			break
+		}
+		low, high := b.LinearExtrapolationKnotRatios()
+		if tooLow {
+			w := (x - domainMin) * low
+			values[0], values[1] = 1-w, w
+		} else {
+			w := (x - domainMax) * high
+			values[b.degree-1], values[b.degree] = -w, 1+w
+		}
+	}
+	return
+}
+
+// localBasis calculates the `degree+1` basis functions of the given degree that are non-zero in the knot span
+// (see SpanIndex), and stores them in basis, which must have at least `degree+1` elements.
+//
+// basis[r] holds the value of the basis function for control point `span-degree+r`.
+func (b *BSpline) localBasis(span int, x float64, degree int, basis []float64) {
+	basis[0] = 1.0
+	if b.traceHook != nil {
+		b.traceHook(x, span, 0, basis[:1])
+	}
+	for jj := 1; jj <= degree; jj++ {
+		b.localBasisStep(span, x, jj, basis)
+	}
+}
+
+// localBasisStep takes the local basis functions of degree `jj-1` (see localBasis) and updates them in place to
+// degree `jj`.
+func (b *BSpline) localBasisStep(span int, x float64, jj int, basis []float64) {
+	saved := 0.0
+	invDeltas := b.invKnotDeltas[jj][span+1-jj:]
+	for r := range jj {
+		left := x - b.expandedKnots[span+1-jj+r]
+		right := b.expandedKnots[span+1+r] - x
+		temp := basis[r] * invDeltas[r]
+		basis[r] = saved + right*temp
+		saved = left * temp
+	}
+	basis[jj] = saved
+	if b.traceHook != nil {
+		b.traceHook(x, span, jj, basis[:jj+1])
+	}
+}
+
+// extrapolationSlope returns the derivative of the extrapolation at x -- x is expected to be outside the knots.
+func (b *BSpline) extrapolationSlope(x float64) float64 {
+	if b.extrapolation != ExtrapolateLinear {
+		return 0.0
+	}
+	low, high := b.LinearExtrapolationKnotRatios()
+	if x < b.expandedKnots[0] {
+		return (b.controlPoints[1] - b.controlPoints[0]) * low
+	}
+	return (at(b.controlPoints, -1) - at(b.controlPoints, -2)) * high
+}
+
 // extrapolate calculates the extrapolation of the b-spline for x -- x is expected to be outside the knots.
 func (b *BSpline) extrapolate(x float64) float64 {
+	return b.extrapolateControlPoints(b.controlPoints, x)
+}
+
+// extrapolateControlPoints calculates the extrapolation for x of the B-spline with the given control points.
+func (b *BSpline) extrapolateControlPoints(controlPoints []float64, x float64) float64 {
 	switch b.extrapolation {
 	case ExtrapolateZero:
 		return 0.0
 	case ExtrapolateConstant:
 		if x < b.expandedKnots[0] {
-			return b.controlPoints[0]
+			return controlPoints[0]
 		} else {
-			return b.controlPoints[len(b.controlPoints)-1]
+			return controlPoints[len(controlPoints)-1]
 		}
 	case ExtrapolateLinear:
 		low, high := b.LinearExtrapolationKnotRatios()
 		if x < b.expandedKnots[0] {
-			linearCoef := (b.controlPoints[1] - b.controlPoints[0]) * low
-			return b.controlPoints[0] + (x-b.expandedKnots[0])*linearCoef
+			linearCoef := (controlPoints[1] - controlPoints[0]) * low
+			return controlPoints[0] + (x-b.expandedKnots[0])*linearCoef
 		} else {
-			linearCoef := (at(b.controlPoints, -1) - at(b.controlPoints, -2)) * high
-			return at(b.controlPoints, -1) + (x-at(b.expandedKnots, -1))*linearCoef
+			linearCoef := (at(controlPoints, -1) - at(controlPoints, -2)) * high
+			return at(controlPoints, -1) + (x-at(b.expandedKnots, -1))*linearCoef
 		}
 	}
 	return 0.0
@@ -236,52 +743,137 @@ func (b *BSpline) LinearExtrapolationKnotRatios() (low, high float64) {
 
 // BasisFunction calculates the B-spline basis function arbitrary degree at parameter x.
 // This usually is not used directly, but can be interesting to plot to understand how it is calculated.
+//
+// It computes the triangle of the lower degree basis functions iteratively, in `O(degree²)` -- see the algorithm A2.4
+// in "The NURBS Book", by Piegl and Tiller. To get all the non-zero basis functions at x, BasisFunctionsAt is faster.
 func (b *BSpline) BasisFunction(controlPointIdx, degree int, x float64) float64 {
-	if degree == 0 {
-		// 1 if in the knot interval, 0 otherwise
-		if x >= b.expandedKnots[controlPointIdx] && x < b.expandedKnots[controlPointIdx+1] {
-			return 1.0
-		} else {
-			return 0.0
-		}
+	knots := b.expandedKnots[controlPointIdx : controlPointIdx+degree+2]
+	if x < knots[0] || x >= knots[degree+1] {
+		// Outside the support of the basis function.
+		return 0.0
 	}
-	left := 0.0
-	if b.expandedKnots[controlPointIdx+degree] != b.expandedKnots[controlPointIdx] {
-		left = (x - b.expandedKnots[controlPointIdx]) / (b.expandedKnots[controlPointIdx+degree] - b.expandedKnots[controlPointIdx]) * b.BasisFunction(controlPointIdx, degree-1, x)
+	// Basis functions of degree 0: 1 if in the knot interval, 0 otherwise
+	var scratch [8]float64
+	n := scratch[:]
+	if degree+1 > len(scratch) {
+		n = make([]float64, degree+1)
 	}
-
-	right := 0.0
-	if b.expandedKnots[controlPointIdx+degree+1] != b.expandedKnots[controlPointIdx+1] {
-		right = (b.expandedKnots[controlPointIdx+degree+1] - x) / (b.expandedKnots[controlPointIdx+degree+1] - b.expandedKnots[controlPointIdx+1]) * b.BasisFunction(controlPointIdx+1, degree-1, x)
+	for jj := range degree + 1 {
+		n[jj] = 0
+		if x >= knots[jj] && x < knots[jj+1] {
+			n[jj] = 1
+		}
 	}
-	return left + right
+	for k := 1; k <= degree; k++ {
+		saved := 0.0
+		if n[0] != 0 {
+			saved = (x - knots[0]) * n[0] / (knots[k] - knots[0])
+		}
+		for jj := range degree - k + 1 {
+			left, right := knots[jj+1], knots[jj+k+1]
+			if n[jj+1] == 0 {
+				n[jj] = saved
+				saved = 0
+				continue
+			}
+			temp := n[jj+1] / (right - left)
+			n[jj] = saved + (right-x)*temp
+			saved = (x - left) * temp
+		}
+	}
+	return n[0]
 }
 
 // Derivative creates the derivative BSpline of the given BSpline.
 // Notice the control points must have been set with WithControlPoints.
 //
 // The returned BSpline have the same knots, and the degree will be one less than the original.
-// The control points are updated.
+// The control points are updated, and the extrapolation is the derivative of the original one,
+// see [DerivativeExtrapolation]. For the n-th derivative in one call, see DerivativeN.
 func (b *BSpline) Derivative() *BSpline {
-	knots := b.Knots()
-	degree := b.degree - 1
-	control := b.controlPoints
-	newControl := make([]float64, b.NumControlPoints()-1)
-	for ii := range newControl {
-		// q_i = p * (c_{i+1} - c_i) / (knot_{i+p+1} - knot_{i+1})
-		newControl[ii] = float64(b.degree) *
-			(control[ii+1] - control[ii]) /
-			(b.expandedKnots[ii+1+b.degree] - b.expandedKnots[ii+1])
-	}
-	// Extrapolation of the derivative is zero, except if original extrapolation was linear, in which case it is
-	// constant.
-	var extrapolation ExtrapolationType
-	switch b.extrapolation {
-	case ExtrapolateZero, ExtrapolateConstant:
-		extrapolation = ExtrapolateZero
-	case ExtrapolateLinear:
-		extrapolation = ExtrapolateConstant
+	return b.DerivativeWithExtrapolation(1, DerivativeExtrapolation(b.extrapolation))
+}
+
+// DerivativeN creates the n-th derivative BSpline of the given BSpline, with degree `Degree()-n`.
+// DerivativeN(0) returns a copy of the B-spline.
+//
+// The extrapolation of each derivative is derived from the previous one with [DerivativeExtrapolation]: so the
+// first derivative of a B-spline with [ExtrapolateLinear] extrapolates with a constant (the slope of the linear
+// tails), and the second and higher derivatives extrapolate with zero.
+//
+// If n is larger than the degree, the derivative is 0 everywhere: it returns a B-spline of degree 0 with the same
+// knots and all control points set to 0.
+func (b *BSpline) DerivativeN(n int) *BSpline {
+	extrapolation := b.extrapolation
+	for range n {
+		extrapolation = DerivativeExtrapolation(extrapolation)
+	}
+	return b.DerivativeWithExtrapolation(n, extrapolation)
+}
+
+// DerivativeWithExtrapolation creates the n-th derivative BSpline of the given BSpline (see DerivativeN), but
+// using the given extrapolation instead of the one derived from the original B-spline.
+//
+// The vector-valued control points (see WithControlPointsND), if set, are also differentiated. At least one of the
+// scalar or vector-valued control points must be set.
+func (b *BSpline) DerivativeWithExtrapolation(n int, extrapolation ExtrapolationType) *BSpline {
+	if len(b.controlPoints) == 0 && len(b.controlPointsND) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.Derivative() require control points to be set using BSpline.WithControlPoints()")
+	}
+	if n < 0 {
+		panicf(ErrInvalidArgument, "BSpline.DerivativeN(%d) requires n >= 0", n)
+	}
+	var derivative *BSpline
+	if n > b.degree {
+		derivative = newFromExpandedKnots(0, b.expandedKnots[b.degree:len(b.expandedKnots)-b.degree])
+	} else {
+		derivative = newFromExpandedKnots(b.degree-n, b.expandedKnots[n:len(b.expandedKnots)-n])
+	}
+	derivative.WithExtrapolation(extrapolation).WithClosedDomain(b.closedDomain)
+	derive := func(control []float64) []float64 { return b.derivativeControlPoints(n, control) }
+	if len(b.controlPoints) > 0 {
+		derivative.WithControlPoints(derive(b.controlPoints))
+	}
+	if len(b.controlPointsND) > 0 {
+		derivative.WithControlPointsND(b.mapControlPointsND(derive))
+	}
+	return derivative
+}
+
+// derivativeControlPoints returns the control points of the n-th derivative, for the given control points of b.
+// If n is larger than the degree, they are the zero control points of a degree 0 B-spline.
+func (b *BSpline) derivativeControlPoints(n int, control []float64) []float64 {
+	if n > b.degree {
+		return make([]float64, len(b.Knots())-1)
+	}
+	degree := b.degree
+	for range n {
+		newControl := make([]float64, len(control)-1)
+		for ii := range newControl {
+			// q_i = p * (c_{i+1} - c_i) / (knot_{i+p+1} - knot_{i+1}), where knots are the expanded knots of degree p.
+			newControl[ii] = float64(degree) *
+				(control[ii+1] - control[ii]) *
+				b.invKnotDeltas[degree][ii+1+b.degree-degree]
+		}
+		control = newControl
+		degree--
+	}
+	if n == 0 {
+		control = slices.Clone(control)
+	}
+	return control
+}
+
+// DerivativeExtrapolation returns the extrapolation of the derivative of a B-spline with the given extrapolation:
+//
+//   - [ExtrapolateZero] and [ExtrapolateConstant]: the B-spline is constant outside the knots, so the derivative is
+//     [ExtrapolateZero].
+//   - [ExtrapolateLinear]: the derivative is the slope of the linear tail, so it's [ExtrapolateConstant]. The
+//     slope of the tail matches the derivative at the end of the knots, which is the value of the first
+//     (or last) control point of the derivative.
+func DerivativeExtrapolation(extrapolation ExtrapolationType) ExtrapolationType {
+	if extrapolation == ExtrapolateLinear {
+		return ExtrapolateConstant
 	}
-	//fmt.Printf("derivative(p=%d): new control points are %v\n", p, newControl)
-	return New(degree, knots).WithExtrapolation(extrapolation).WithControlPoints(newControl)
+	return ExtrapolateZero
 }
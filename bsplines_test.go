@@ -0,0 +1,2082 @@
+package bsplines
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/gomlx/exceptions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"math/big"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDomain(t *testing.T) {
+	b := New(3, []float64{-1.0, 0.0, 2.5})
+	min, max := b.Domain()
+	assert.Equal(t, -1.0, min)
+	assert.Equal(t, 2.5, max)
+
+	min, max = NewRegular(2, 5).Domain()
+	assert.Equal(t, 0.0, min)
+	assert.Equal(t, 1.0, max)
+}
+
+func TestKnotIntrospection(t *testing.T) {
+	b := New(2, []float64{0.0, 1.0, 3.0})
+	assert.True(t, b.IsClamped())
+	assert.False(t, b.IsUniform(1e-9))
+	values, multiplicities := b.KnotMultiplicities()
+	assert.Equal(t, []float64{0.0, 1.0, 3.0}, values)
+	assert.Equal(t, []int{3, 1, 3}, multiplicities)
+
+	b = NewRegular(3, 7)
+	assert.True(t, b.IsClamped())
+	assert.True(t, b.IsUniform(1e-9))
+}
+
+func TestEvaluateWithGradient(t *testing.T) {
+	controlPoints := []float64{1.0, 0.7, -0.7, -1.0, -0.7, 0.7, 1.0, 0.7}
+	for _, degree := range []int{0, 1, 2, 3} {
+		numKnots := len(controlPoints) - degree + 1
+		knots := append([]float64{0, 0.1, 0.3, 0.35, 0.6, 0.8, 0.9, 0.95}[:numKnots-1], 1.0)
+		b := New(degree, knots).WithControlPoints(controlPoints).WithExtrapolation(ExtrapolateLinear)
+		var derivative *BSpline
+		if degree > 0 {
+			derivative = b.Derivative()
+		}
+		for _, x := range []float64{-0.2, 0.0, 0.12, 0.33, 0.5, 0.95, 1.2} {
+			value, dydx := b.EvaluateWithGradient(x)
+			assert.InDeltaf(t, b.Evaluate(x), value, 1e-9, "degree=%d, x=%g", degree, x)
+			if x >= 0 && x < 1 {
+				if degree > 0 {
+					assert.InDeltaf(t, derivative.Evaluate(x), dydx, 1e-9, "degree=%d, x=%g", degree, x)
+				} else {
+					assert.Equal(t, 0.0, dydx)
+				}
+			} else {
+				const h = 1e-6
+				assert.InDeltaf(t, (b.Evaluate(x+h)-b.Evaluate(x-h))/(2*h), dydx, 1e-5, "degree=%d, x=%g", degree, x)
+			}
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	knots := []float64{0, 0.25, 0.5, 1.0}
+	controlPoints := []float64{1.0, -1.0, 0.5, 2.0, 0.0}
+	b := New(2, knots).WithControlPoints(controlPoints)
+	for _, x := range []float64{-1, 0, 0.3, 0.9, 2} {
+		assert.Equal(t, b.Evaluate(x), Eval(2, knots, controlPoints, x))
+	}
+}
+
+func TestTraceHook(t *testing.T) {
+	b := NewRegular(3, 8).WithControlPoints([]float64{1.0, 0.7, -0.7, -1.0, -0.7, 0.7, 1.0, 0.7})
+	want := b.Evaluate(0.42)
+	var degrees []int
+	b.WithTraceHook(func(x float64, span, degree int, basis []float64) {
+		assert.Equal(t, 0.42, x)
+		assert.Len(t, basis, degree+1)
+		sum := 0.0
+		for _, v := range basis {
+			sum += v
+		}
+		assert.InDelta(t, 1.0, sum, 1e-9) // Partition of unity at each degree.
+		degrees = append(degrees, degree)
+	})
+	assert.InDelta(t, want, b.Evaluate(0.42), 1e-9)
+	assert.Equal(t, []int{0, 1, 2, 3}, degrees)
+}
+
+func TestCheckInvariants(t *testing.T) {
+	for _, degree := range []int{0, 1, 2, 3, 5} {
+		assert.Empty(t, New(degree, []float64{0, 0.1, 0.15, 0.6, 0.8, 1.0}).CheckInvariants(200, 1e-9))
+	}
+
+	// Corrupt the knots to force a violation.
+	b := NewRegular(2, 6)
+	b.expandedKnots[0] = 0.5
+	violations := b.CheckInvariants(50, 1e-9)
+	require.NotEmpty(t, violations)
+	assert.Equal(t, InvariantViolation{Invariant: PartitionOfUnity, X: 0, ControlPointIdx: -1, Value: 0}, violations[0])
+	assert.Equal(t, "partition of unity violated at x=0: value=0", violations[0].String())
+}
+
+func TestGaussLegendre(t *testing.T) {
+	for n := 1; n <= 8; n++ {
+		nodes, weights := gaussLegendre(n)
+		// Integrate x^k over [-1, 1], exact for k <= 2n-1.
+		for k := range 2 * n {
+			var got float64
+			for ii, x := range nodes {
+				got += weights[ii] * math.Pow(x, float64(k))
+			}
+			want := 0.0
+			if k%2 == 0 {
+				want = 2 / float64(k+1)
+			}
+			assert.InDeltaf(t, want, got, 1e-12, "n=%d, k=%d", n, k)
+		}
+	}
+}
+
+func TestDecompose(t *testing.T) {
+	rng := rand.New(rand.NewPCG(42, 42))
+	b := NewRegular(3, 19).WithExtrapolation(ExtrapolateLinear)
+	control := make([]float64, b.NumControlPoints())
+	for ii := range control {
+		control[ii] = math.Sin(float64(ii)/3) + 0.01*rng.NormFloat64()
+	}
+	b.WithControlPoints(control)
+
+	d := b.Decompose(2)
+	require.Len(t, d.Details, 2)
+	assert.Len(t, d.Coarse.Knots(), 5)
+	reconstructed := d.Reconstruct()
+	assert.Equal(t, b.Knots(), reconstructed.Knots())
+	assert.InDeltaSlice(t, b.ControlPoints(), reconstructed.ControlPoints(), 1e-9)
+
+	// The coarse approximation of a linear function is exact, and its details are negligible.
+	poly := NewRegular(3, 19)
+	polyControl := make([]float64, poly.NumControlPoints())
+	for ii, x := range poly.ControlPointsX() {
+		polyControl[ii] = 2*x - 1 // Linear functions are reproduced by the control points at the Greville abscissae.
+	}
+	poly.WithControlPoints(polyControl)
+	d = poly.Decompose(2)
+	assert.Greater(t, d.Threshold(1e-9), 0)
+	for _, x := range []float64{0, 0.1, 0.5, 0.77} {
+		assert.InDelta(t, poly.Evaluate(x), d.Coarse.Evaluate(x), 1e-9)
+	}
+
+	// Repeated knots.
+	hermite := NewPCHIP([]float64{0, 1, 2, 3, 4}, []float64{0, 1, 0, 2, 1})
+	reconstructed = hermite.Decompose(1).Reconstruct()
+	assert.Equal(t, hermite.Knots(), reconstructed.Knots())
+	assert.InDeltaSlice(t, hermite.ControlPoints(), reconstructed.ControlPoints(), 1e-9)
+}
+
+func TestPCHIP(t *testing.T) {
+	x := []float64{0, 1, 2, 3.5, 4, 6}
+	y := []float64{0, 0.5, 0.5, 2, 4, 4.2}
+	b := NewPCHIP(x, y)
+	require.Len(t, b.ControlPoints(), 2*len(x))
+	for ii := range x[:len(x)-1] {
+		assert.InDelta(t, y[ii], b.Evaluate(x[ii]), 1e-12)
+	}
+
+	// Monotone data yields a monotone interpolant.
+	previous := b.Evaluate(0)
+	for xx := 0.01; xx < 6; xx += 0.01 {
+		value := b.Evaluate(xx)
+		require.GreaterOrEqual(t, value, previous-1e-12, "not monotone at x=%g", xx)
+		previous = value
+	}
+
+	// Round trip to Hermite form.
+	hx, hy, slopes := b.ToHermite()
+	assert.Equal(t, x, hx)
+	assert.InDeltaSlice(t, y, hy, 1e-12)
+	assert.Equal(t, 0.0, slopes[1]) // Local extreme of the secants.
+	b2 := NewHermite(hx, hy, slopes)
+	assert.InDeltaSlice(t, b.ControlPoints(), b2.ControlPoints(), 1e-12)
+
+	// Conversion of a regular cubic B-spline is exact.
+	c := NewRegular(3, 8).WithControlPoints([]float64{1, -1, 2, 0, 3, 1, 0, 2})
+	h := NewHermite(c.ToHermite())
+	for xx := 0.0; xx < 1; xx += 0.03 {
+		assert.InDelta(t, c.Evaluate(xx), h.Evaluate(xx), 1e-12)
+	}
+}
+
+func TestRandomBSpline(t *testing.T) {
+	b := RandomBSpline(rand.New(rand.NewPCG(1, 2)), 3, 12, RandomMonotone(), RandomPositive(), RandomKnots())
+	b2 := RandomBSpline(rand.New(rand.NewPCG(1, 2)), 3, 12, RandomMonotone(), RandomPositive(), RandomKnots())
+	assert.Equal(t, b.Knots(), b2.Knots())
+	assert.Equal(t, b.ControlPoints(), b2.ControlPoints())
+	assert.False(t, b.IsUniform(1e-6))
+	previous := b.Evaluate(0)
+	for x := 0.0; x < 1; x += 0.01 {
+		value := b.Evaluate(x)
+		require.GreaterOrEqual(t, value, 0.0)
+		require.GreaterOrEqual(t, value, previous-1e-12)
+		previous = value
+	}
+
+	b = RandomBSpline(rand.New(rand.NewPCG(1, 2)), 2, 20, RandomBounded(3, 4))
+	assert.True(t, b.IsUniform(1e-9))
+	for _, value := range b.ControlPoints() {
+		assert.True(t, value >= 3 && value <= 4)
+	}
+}
+
+func BenchmarkEvaluate(b *testing.B) {
+	rng := rand.New(rand.NewPCG(42, 42))
+	for _, degree := range []int{1, 3, 5} {
+		spline := RandomBSpline(rng, degree, 32)
+		b.Run(fmt.Sprintf("degree=%d", degree), func(b *testing.B) {
+			for ii := range b.N {
+				_ = spline.Evaluate(float64(ii%1000) / 1000)
+			}
+		})
+	}
+}
+
+func BenchmarkEvaluateNonUniform(b *testing.B) {
+	rng := rand.New(rand.NewPCG(42, 42))
+	xs := make([]float64, 1000)
+	for ii := range xs {
+		xs[ii] = float64(ii) / 1000
+	}
+	output := make([]float64, len(xs))
+	for _, degree := range []int{1, 3, 5} {
+		spline := RandomBSpline(rng, degree, 32, RandomKnots())
+		b.Run(fmt.Sprintf("degree=%d", degree), func(b *testing.B) {
+			for range b.N {
+				spline.EvaluateBatchInto(xs, output)
+			}
+		})
+	}
+}
+
+func TestReciprocalKnotDeltas(t *testing.T) {
+	table := reciprocalKnotDeltas([]float64{0, 0, 1, 3}, 2)
+	require.Len(t, table, 3)
+	assert.Nil(t, table[0])
+	assert.Equal(t, []float64{0, 1, 0.5}, table[1])
+	assert.Equal(t, []float64{1, 1.0 / 3}, table[2])
+}
+
+func TestDerivativeExtrapolation(t *testing.T) {
+	for _, extrapolation := range []ExtrapolationType{ExtrapolateZero, ExtrapolateConstant, ExtrapolateLinear} {
+		b := New(3, []float64{0, 0.3, 0.5, 1}).WithExtrapolation(extrapolation).
+			WithControlPoints([]float64{1, 3, -1, 2, 0, 1})
+		d1, d2 := b.DerivativeN(1), b.DerivativeN(2)
+		assert.Equal(t, b.Derivative().ControlPoints(), d1.ControlPoints())
+		assert.InDeltaSlice(t, d1.Derivative().ControlPoints(), d2.ControlPoints(), 1e-12)
+		for _, x := range []float64{-0.5, -0.01, 0.2, 0.7, 1.01, 1.5} {
+			_, dydx := b.EvaluateWithGradient(x)
+			assert.InDelta(t, dydx, d1.Evaluate(x), 1e-9, "%s: x=%g", extrapolation, x)
+			_, d2ydx2 := d1.EvaluateWithGradient(x)
+			assert.InDelta(t, d2ydx2, d2.Evaluate(x), 1e-9, "%s: x=%g", extrapolation, x)
+			if x < 0 || x > 1 {
+				assert.Equal(t, 0.0, d2.Evaluate(x))
+			}
+		}
+	}
+	b := NewRegular(2, 5).WithControlPoints([]float64{0, 1, 2, 3, 4})
+	assert.Equal(t, b.ControlPoints(), b.DerivativeN(0).ControlPoints())
+	assert.Equal(t, ExtrapolateLinear, b.DerivativeWithExtrapolation(1, ExtrapolateLinear).Extrapolation())
+	assert.Panics(t, func() { b.DerivativeN(-1) })
+	for _, n := range []int{3, 5} {
+		zero := b.DerivativeN(n)
+		assert.Equal(t, 0, zero.Degree())
+		assert.Equal(t, b.Knots(), zero.Knots())
+		for x := -0.5; x < 1.5; x += 0.1 {
+			assert.Equal(t, 0.0, zero.Evaluate(x))
+		}
+	}
+	short := New(1, []float64{0, 1}).WithControlPoints([]float64{0, 2})
+	assert.Equal(t, 0.0, short.DerivativeN(2).Evaluate(0.5))
+}
+
+func TestPenaltyMatrix(t *testing.T) {
+	// f(x) = x² on [0, 1], refined to more knots.
+	b := New(2, []float64{0, 1})
+	fineKnots := []float64{0, 0.2, 0.5, 0.6, 1}
+	fine := New(2, fineKnots).WithControlPoints(b.RefineControlPoints(fineKnots, []float64{0, 0, 1}))
+	assert.InDelta(t, 0.25, fine.Evaluate(0.5), 1e-12)
+
+	quadratic := func(m [][]float64, c []float64) float64 {
+		var sum float64
+		for ii := range m {
+			for jj := range m[ii] {
+				sum += c[ii] * m[ii][jj] * c[jj]
+			}
+		}
+		return sum
+	}
+	c := fine.ControlPoints()
+	assert.InDelta(t, 1.0/5.0, quadratic(fine.PenaltyMatrix(0), c), 1e-12) // ∫ x⁴
+	assert.InDelta(t, 4.0/3.0, quadratic(fine.PenaltyMatrix(1), c), 1e-12) // ∫ (2x)²
+	assert.InDelta(t, 4.0, quadratic(fine.PenaltyMatrix(2), c), 1e-12)     // ∫ 2²
+
+	// Banded and symmetric.
+	p := NewRegular(3, 10).PenaltyMatrix(2)
+	for ii := range p {
+		for jj := range p[ii] {
+			assert.InDelta(t, p[ii][jj], p[jj][ii], 1e-12)
+			if ii-jj > 3 || jj-ii > 3 {
+				assert.Equal(t, 0.0, p[ii][jj])
+			}
+		}
+	}
+	assert.Panics(t, func() { NewRegular(3, 10).PenaltyMatrix(4) })
+}
+
+func TestEvaluateMatchesBasisFunctions(t *testing.T) {
+	rng := rand.New(rand.NewPCG(7, 7))
+	for _, degree := range []int{0, 1, 2, 3, 5, 8} {
+		b := RandomBSpline(rng, degree, degree+12, RandomKnots())
+		for x := -0.1; x < 1.1; x += 0.013 {
+			want := b.Evaluate(x)
+			if x >= 0 && x < 1 {
+				want = 0
+				for ii, c := range b.ControlPoints() {
+					want += c * b.BasisFunction(ii, degree, x)
+				}
+			}
+			require.InDelta(t, want, b.Evaluate(x), 1e-12, "degree=%d, x=%g", degree, x)
+		}
+	}
+}
+
+func TestQuasiInterpolate(t *testing.T) {
+	cubic := func(x float64) float64 { return 2*x*x*x - x*x + 0.5*x - 3 }
+	b := New(3, []float64{0, 0.1, 0.35, 0.4, 0.8, 1})
+	b.WithControlPoints(b.QuasiInterpolate(cubic))
+	for x := 0.0; x < 1; x += 0.01 {
+		require.InDelta(t, cubic(x), b.Evaluate(x), 1e-9, "x=%g", x)
+	}
+
+	// Approximation of a smooth function converges quickly.
+	b = NewRegular(3, 20)
+	b.WithControlPoints(b.QuasiInterpolate(math.Sin))
+	for x := 0.0; x < 1; x += 0.01 {
+		require.InDelta(t, math.Sin(x), b.Evaluate(x), 1e-6, "x=%g", x)
+	}
+
+	// Schoenberg reproduces linear functions and preserves monotonicity.
+	linear := func(x float64) float64 { return 3*x - 1 }
+	b.WithControlPoints(b.SchoenbergControlPoints(linear))
+	for x := 0.0; x < 1; x += 0.01 {
+		require.InDelta(t, linear(x), b.Evaluate(x), 1e-12, "x=%g", x)
+	}
+}
+
+func TestEvaluateBatch(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 3))
+	b := RandomBSpline(rng, 3, 15, RandomKnots(), RandomExtrapolation(ExtrapolateLinear))
+	xs := make([]float64, 500)
+	for ii := range xs {
+		if ii < 250 {
+			xs[ii] = -0.2 + 1.4*float64(ii)/250 // Sorted.
+		} else {
+			xs[ii] = -0.2 + 1.4*rng.Float64() // Random order.
+		}
+	}
+	ys := b.EvaluateBatch(xs)
+	for ii, x := range xs {
+		require.Equal(t, b.Evaluate(x), ys[ii], "x=%g", x)
+	}
+	assert.Panics(t, func() { b.EvaluateBatchInto(xs, ys[:10]) })
+
+	high := RandomBSpline(rng, 9, 15)
+	ys = high.EvaluateBatch(xs[:10])
+	for ii, x := range xs[:10] {
+		require.Equal(t, high.Evaluate(x), ys[ii], "x=%g", x)
+	}
+}
+
+func BenchmarkEvaluateBatch(b *testing.B) {
+	rng := rand.New(rand.NewPCG(42, 42))
+	xs := make([]float64, 1000)
+	for ii := range xs {
+		xs[ii] = float64(ii) / 1000
+	}
+	output := make([]float64, len(xs))
+	for _, degree := range []int{1, 3, 5} {
+		spline := RandomBSpline(rng, degree, 32)
+		b.Run(fmt.Sprintf("degree=%d", degree), func(b *testing.B) {
+			for range b.N {
+				spline.EvaluateBatchInto(xs, output)
+			}
+		})
+	}
+}
+
+func TestRefine(t *testing.T) {
+	rng := rand.New(rand.NewPCG(5, 5))
+	b := RandomBSpline(rng, 3, 8, RandomKnots(), RandomExtrapolation(ExtrapolateLinear))
+	fineKnots := b.DoubledKnots()
+	require.Len(t, fineKnots, 2*len(b.Knots())-1)
+	fine := b.Refine(fineKnots)
+	for x := -0.3; x < 1.3; x += 0.01 {
+		require.InDelta(t, b.Evaluate(x), fine.Evaluate(x), 1e-12, "x=%g", x)
+	}
+
+	// The refinement matrix maps coarse control points to the fine ones.
+	p := b.RefinementMatrix(fineKnots)
+	require.Len(t, p, fine.NumControlPoints())
+	for ii, row := range p {
+		var value float64
+		for jj, weight := range row {
+			value += weight * b.ControlPoints()[jj]
+		}
+		assert.InDelta(t, fine.ControlPoints()[ii], value, 1e-12)
+	}
+
+	// Repeated knots: Hermite (every interior knot doubled) and a jump (multiplicity degree+1).
+	for _, b := range []*BSpline{
+		NewHermite([]float64{0, 1, 2}, []float64{1, -1, 2}, []float64{0, 3, -1}).WithExtrapolation(ExtrapolateLinear),
+		New(2, []float64{0, 0.3, 0.5, 0.5, 0.5, 1}).WithControlPoints([]float64{1, 2, 0, -1, 3, 2, 1}),
+	} {
+		fineKnots := b.DoubledKnots()
+		distinct := slices.Compact(slices.Clone(b.Knots()))
+		require.Len(t, fineKnots, len(b.Knots())+len(distinct)-1)
+		fine := b.Refine(fineKnots)
+		domainMin, domainMax := b.Domain()
+		for x := domainMin - 0.3; x < domainMax+0.3; x += 0.01 {
+			require.InDelta(t, b.Evaluate(x), fine.Evaluate(x), 1e-12, "x=%g", x)
+		}
+		fine = b.Refine(MergeKnots(b.Knots(), []float64{0.25, 0.5}))
+		for x := domainMin - 0.3; x < domainMax+0.3; x += 0.01 {
+			require.InDelta(t, b.Evaluate(x), fine.Evaluate(x), 1e-12, "x=%g", x)
+		}
+	}
+}
+
+func TestEvaluateBatchParallel(t *testing.T) {
+	rng := rand.New(rand.NewPCG(4, 4))
+	b := RandomBSpline(rng, 3, 20)
+	xs := make([]float64, 10_000)
+	for ii := range xs {
+		xs[ii] = -0.1 + 1.2*rng.Float64()
+	}
+	want := b.EvaluateBatch(xs)
+	for _, numWorkers := range []int{0, 1, 3, 100} {
+		require.Equal(t, want, b.EvaluateBatchParallel(xs, numWorkers), "numWorkers=%d", numWorkers)
+	}
+
+	// Panics are raised in the calling goroutine, so they can be recovered.
+	err := exceptions.TryCatch[error](func() { NewRegular(3, 20).EvaluateBatchParallel(xs, 4) })
+	assert.ErrorIs(t, err, ErrControlPointsNotSet)
+	b.WithTraceHook(func(x float64, span, degree int, basis []float64) {
+		if x > 0.9 {
+			panicf(ErrOutOfDomain, "trace hook rejected x=%g", x)
+		}
+	})
+	err = exceptions.TryCatch[error](func() { b.EvaluateBatchParallel(xs, 4) })
+	assert.ErrorIs(t, err, ErrOutOfDomain)
+}
+
+func TestAverage(t *testing.T) {
+	rng := rand.New(rand.NewPCG(6, 6))
+	splines := []*BSpline{
+		RandomBSpline(rng, 2, 6, RandomKnots(), RandomExtrapolation(ExtrapolateLinear)),
+		RandomBSpline(rng, 2, 9, RandomExtrapolation(ExtrapolateLinear)),
+		RandomBSpline(rng, 2, 7, RandomKnots(), RandomExtrapolation(ExtrapolateLinear)),
+	}
+	weights := []float64{1, 2, 5}
+	avg := Average(splines, weights)
+	for x := -0.5; x < 1.5; x += 0.01 {
+		var want float64
+		for ii, b := range splines {
+			want += weights[ii] * b.Evaluate(x) / 8
+		}
+		require.InDelta(t, want, avg.Evaluate(x), 1e-12, "x=%g", x)
+	}
+	assert.InDelta(t, (splines[0].Evaluate(0.3)+splines[1].Evaluate(0.3)+splines[2].Evaluate(0.3))/3,
+		Average(splines, nil).Evaluate(0.3), 1e-12)
+	assert.Panics(t, func() { Average([]*BSpline{splines[0], RandomBSpline(rng, 3, 6)}, nil) })
+}
+
+func TestSpanIndex(t *testing.T) {
+	rng := rand.New(rand.NewPCG(8, 8))
+	b := RandomBSpline(rng, 3, 600, RandomKnots())
+	knots := b.ExpandedKnots()
+	for range 1000 {
+		x := rng.Float64()
+		span := b.SpanIndex(x)
+		require.True(t, knots[span] <= x && x < knots[span+1], "x=%g, span=%d", x, span)
+	}
+	assert.Equal(t, 3, b.SpanIndex(-1))
+	assert.Equal(t, b.NumControlPoints()-1, b.SpanIndex(1))
+	assert.Equal(t, b.NumControlPoints()-1, b.SpanIndex(2))
+
+	// Uniform knots, with values far outside the domain.
+	for _, b := range []*BSpline{NewRegular(3, 10), RandomBSpline(rng, 3, 10, RandomKnots())} {
+		last := b.NumControlPoints() - 1
+		for _, x := range []float64{1e20, 1e300, math.Inf(1)} {
+			assert.Equal(t, last, b.SpanIndex(x), "x=%g", x)
+			assert.Equal(t, 3, b.SpanIndex(-x), "x=%g", -x)
+		}
+		assert.Equal(t, 3, b.SpanIndex(math.NaN()))
+	}
+}
+
+func TestQuadratureNodes(t *testing.T) {
+	b := New(2, []float64{0, 0.5, 2})
+	nodes, weights := b.QuadratureNodes(3, 2)
+	require.Len(t, nodes, 2)
+	for _, x := range nodes {
+		assert.True(t, x > 0.5 && x < 2)
+	}
+	var integral float64
+	for ii, x := range nodes {
+		integral += weights[ii] * x * x * x
+	}
+	assert.InDelta(t, (16-1.0/16)/4, integral, 1e-12) // ∫ x³ over [0.5, 2]
+	assert.Panics(t, func() { b.QuadratureNodes(1, 2) })
+
+	// Integral of the square of the B-spline.
+	b.WithControlPoints(b.ControlPointsX()) // f(x) = x, since linear functions are reproduced at the Greville abscissae.
+	assert.InDelta(t, 8.0/3.0, b.Integrate(func(x float64) float64 { return b.Evaluate(x) * b.Evaluate(x) }, 3), 1e-12)
+}
+
+func TestGridPlan(t *testing.T) {
+	rng := rand.New(rand.NewPCG(9, 9))
+	for _, degree := range []int{1, 2, 3, 5} {
+		b := RandomBSpline(rng, degree, 12, RandomKnots(), RandomExtrapolation(ExtrapolateLinear))
+		xs := make([]float64, 200)
+		for ii := range xs {
+			xs[ii] = -0.2 + 1.4*float64(ii)/200
+		}
+		plan := NewGridPlan(b, xs, degree+1)
+		results := plan.EvaluateAll()
+		for order := 0; order <= degree; order++ {
+			derivative := b.DerivativeN(order)
+			for ii, x := range xs {
+				require.InDelta(t, derivative.Evaluate(x), results[order][ii], 1e-8, "degree=%d, order=%d, x=%g", degree, order, x)
+			}
+		}
+		for _, value := range results[degree+1] {
+			require.Equal(t, 0.0, value)
+		}
+
+		// Changing the control points reuses the plan.
+		b.WithControlPoints(b.SchoenbergControlPoints(func(x float64) float64 { return 2 * x }))
+		for ii, value := range plan.Evaluate(1) {
+			if xs[ii] >= 0 && xs[ii] < 1 {
+				require.InDelta(t, 2.0, value, 1e-9)
+			}
+		}
+	}
+}
+
+func TestEvalScratch(t *testing.T) {
+	rng := rand.New(rand.NewPCG(10, 10))
+	b := RandomBSpline(rng, 9, 20, RandomExtrapolation(ExtrapolateLinear))
+	scratch := b.NewEvalScratch()
+	xs := make([]float64, 100)
+	for ii := range xs {
+		xs[ii] = -0.2 + 1.4*rng.Float64()
+		require.Equal(t, b.Evaluate(xs[ii]), scratch.Evaluate(xs[ii]))
+	}
+	output := make([]float64, len(xs))
+	allocs := testing.AllocsPerRun(10, func() {
+		for _, x := range xs {
+			_ = scratch.Evaluate(x)
+		}
+		scratch.EvaluateBatchInto(xs, output)
+	})
+	assert.Equal(t, 0.0, allocs)
+	assert.Equal(t, b.EvaluateBatch(xs), output)
+}
+
+func TestSmoothCoefficients(t *testing.T) {
+	kernel := []float64{0.25, 0.5, 0.25}
+	b := NewRegular(2, 10).WithControlPoints([]float64{3, 3, 3, 3, 3, 3, 3, 3, 3, 3})
+	assert.Equal(t, b.ControlPoints(), b.SmoothCoefficients(kernel).ControlPoints())
+
+	rng := rand.New(rand.NewPCG(11, 11))
+	noisy := RandomBSpline(rng, 3, 50)
+	roughness := func(b *BSpline) (sum float64) {
+		c := b.ControlPoints()
+		for ii := 1; ii < len(c); ii++ {
+			sum += (c[ii] - c[ii-1]) * (c[ii] - c[ii-1])
+		}
+		return
+	}
+	smooth := noisy.SmoothCoefficients(kernel)
+	assert.Less(t, roughness(smooth), roughness(noisy)/2)
+	assert.Equal(t, noisy.Knots(), smooth.Knots())
+	assert.Panics(t, func() { noisy.SmoothCoefficients([]float64{0.5, 0.5}) })
+}
+
+func TestEvaluator(t *testing.T) {
+	rng := rand.New(rand.NewPCG(12, 12))
+	for _, extrapolation := range []ExtrapolationType{ExtrapolateZero, ExtrapolateConstant, ExtrapolateLinear} {
+		b := RandomBSpline(rng, 3, 10, RandomKnots(), RandomExtrapolation(extrapolation))
+		e32 := NewEvaluator[float32](b)
+		e64 := NewEvaluator[float64](b)
+		xs := make([]float32, 50)
+		for ii := range xs {
+			xs[ii] = float32(-0.2 + 1.4*rng.Float64())
+			want := b.Evaluate(float64(xs[ii]))
+			require.InDelta(t, want, float64(e32.Evaluate(xs[ii])), 1e-5)
+			require.InDelta(t, want, e64.Evaluate(float64(xs[ii])), 1e-12)
+		}
+		output := make([]float32, len(xs))
+		e32.EvaluateBatchInto(xs, output)
+		for ii, x := range xs {
+			require.Equal(t, e32.Evaluate(x), output[ii])
+		}
+	}
+}
+
+func TestEvaluatorImmutable(t *testing.T) {
+	b := NewRegular(3, 8).WithControlPoints(make([]float64, 8))
+	e := b.Evaluator()
+	b.WithControlPoints([]float64{1, 1, 1, 1, 1, 1, 1, 1})
+	assert.Equal(t, 0.0, e.Evaluate(0.5))
+
+	var wg sync.WaitGroup
+	for ii := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			control := make([]float64, 8)
+			for jj := range control {
+				control[jj] = float64(ii)
+			}
+			eII := e.WithControlPoints(control)
+			for x := 0.0; x < 1; x += 0.01 {
+				assert.InDelta(t, float64(ii), eII.Evaluate(x), 1e-12)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 0.0, e.Evaluate(0.5))
+}
+
+func TestVectorized(t *testing.T) {
+	rng := rand.New(rand.NewPCG(13, 13))
+	for _, degree := range []int{0, 1, 3, 6} {
+		b := RandomBSpline(rng, degree, 40, RandomKnots(), RandomExtrapolation(ExtrapolateLinear))
+		xs := make([]float64, 61)
+		for ii := range xs {
+			xs[ii] = -0.2 + 1.4*rng.Float64()
+		}
+		xs[0], xs[1] = 0, 1
+		ys := b.Vectorized().Evaluate(xs)
+		for ii, x := range xs {
+			require.InDelta(t, b.Evaluate(x), ys[ii], 1e-12, "degree=%d, x=%g", degree, x)
+		}
+	}
+}
+
+func BenchmarkVectorized(b *testing.B) {
+	rng := rand.New(rand.NewPCG(42, 42))
+	xs := make([]float64, 1000)
+	for ii := range xs {
+		xs[ii] = rng.Float64()
+	}
+	output := make([]float64, len(xs))
+	for _, degree := range []int{1, 3, 5} {
+		spline := RandomBSpline(rng, degree, 32)
+		v := spline.Vectorized()
+		b.Run(fmt.Sprintf("degree=%d", degree), func(b *testing.B) {
+			for range b.N {
+				v.EvaluateInto(xs, output)
+			}
+		})
+	}
+}
+
+func TestInverse(t *testing.T) {
+	b := NewRegular(3, 10)
+	b.WithControlPoints(b.QuasiInterpolate(func(x float64) float64 { return math.Exp(2 * x) }))
+	inverse, err := b.Inverse(20)
+	require.NoError(t, err)
+	for x := 0.0; x < 1; x += 0.01 {
+		require.InDelta(t, x, inverse.Evaluate(b.Evaluate(x)), 1e-4, "x=%g", x)
+	}
+
+	_, err = NewRegular(2, 4).WithControlPoints([]float64{0, 1, 0, 1}).Inverse(10)
+	require.Error(t, err)
+}
+
+func TestAccuracyCompensated(t *testing.T) {
+	degree := 7
+	b := NewRegular(degree, 12)
+	control := make([]float64, b.NumControlPoints())
+	for ii := range control {
+		control[ii] = 1e8 * float64(1-2*(ii%2))
+		if ii%3 == 0 {
+			control[ii] += 0.123456789
+		}
+	}
+	b.WithControlPoints(control)
+	x := 0.4321
+	span := b.SpanIndex(x)
+	basis := make([]float64, degree+1)
+	b.localBasis(span, x, degree, basis)
+
+	// Exact sum of the (rounded) products, to measure only the summation errors.
+	exact := new(big.Float).SetPrec(1000)
+	for r, weight := range basis {
+		term := new(big.Float).SetPrec(1000).SetFloat64(weight)
+		term.Mul(term, new(big.Float).SetFloat64(control[span-degree+r]))
+		exact.Add(exact, term)
+	}
+	want, _ := exact.Float64()
+	plainErr := math.Abs(b.Evaluate(x) - want)
+	compensatedErr := math.Abs(b.WithAccuracy(AccuracyCompensated).Evaluate(x) - want)
+	assert.LessOrEqual(t, compensatedErr, math.Abs(want)*1e-15)
+	assert.LessOrEqual(t, compensatedErr, plainErr)
+	value, _ := b.EvaluateWithGradient(x)
+	assert.Equal(t, b.Evaluate(x), value)
+	assert.Equal(t, "AccuracyCompensated", AccuracyCompensated.String())
+}
+
+func TestEvaluateDerivativeTails(t *testing.T) {
+	rng := rand.New(rand.NewPCG(15, 15))
+	b := RandomBSpline(rng, 3, 9, RandomKnots(), RandomExtrapolation(ExtrapolateLinear))
+	const h = 1e-6
+	for _, x := range []float64{-0.7, -0.1, 0.3, 0.6, 1.2, 2} {
+		numeric := (b.Evaluate(x+h) - b.Evaluate(x-h)) / (2 * h)
+		assert.InDelta(t, numeric, b.EvaluateDerivative(x, 1), 1e-5, "x=%g", x)
+		assert.InDelta(t, b.DerivativeN(1).Evaluate(x), b.EvaluateDerivative(x, 1), 1e-9, "x=%g", x)
+		assert.InDelta(t, b.DerivativeN(2).Evaluate(x), b.EvaluateDerivative(x, 2), 1e-9, "x=%g", x)
+		assert.Equal(t, 0.0, b.EvaluateDerivative(x, 4))
+	}
+	// The tail slope matches the derivative at the ends of the domain.
+	assert.InDelta(t, b.EvaluateDerivative(0, 1), b.EvaluateDerivative(-1, 1), 1e-12)
+	assert.InDelta(t, b.EvaluateDerivative(1-1e-12, 1), b.EvaluateDerivative(2, 1), 1e-8)
+}
+
+func TestGridEvaluator(t *testing.T) {
+	rng := rand.New(rand.NewPCG(16, 16))
+	b := RandomBSpline(rng, 3, 12, RandomKnots())
+	xs := make([]float64, 100)
+	for ii := range xs {
+		xs[ii] = -0.1 + 1.2*float64(ii)/100
+	}
+	grid := b.GridEvaluator(xs)
+	output := make([]float64, len(xs))
+	for range 3 {
+		b.WithControlPoints(RandomBSpline(rng, 3, 12).ControlPoints())
+		grid.EvaluateInto(0, output)
+		assert.InDeltaSlice(t, b.EvaluateBatch(xs), output, 1e-12)
+	}
+	assert.Equal(t, 0.0, testing.AllocsPerRun(5, func() { grid.EvaluateInto(0, output) }))
+
+	m := grid.BasisMatrix(0)
+	for ii, x := range xs {
+		if x < 0 || x >= 1 {
+			continue
+		}
+		var sum float64
+		for jj, weight := range m[ii] {
+			sum += weight * b.ControlPoints()[jj]
+		}
+		require.InDelta(t, output[ii], sum, 1e-12)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	rng := rand.New(rand.NewPCG(17, 17))
+	b := RandomBSpline(rng, 2, 8, RandomExtrapolation(ExtrapolateLinear))
+	p := NewPipeline(b).WithStandardizedInput(10, 4).WithOutputTransform(100, -3)
+	assert.InDelta(t, 100*b.Evaluate(0.5)-3, p.Evaluate(12), 1e-12)
+
+	data, err := json.Marshal(p)
+	require.NoError(t, err)
+	var p2 Pipeline
+	require.NoError(t, json.Unmarshal(data, &p2))
+	for x := 5.0; x < 20; x += 0.5 {
+		require.Equal(t, p.Evaluate(x), p2.Evaluate(x))
+	}
+	assert.Equal(t, ExtrapolateLinear, p2.Spline.Extrapolation())
+
+	require.Error(t, json.Unmarshal([]byte(`{"spline": {"degree": 1, "expanded_knots": [0, 0, 1, 1], "control_points": [1], "extrapolation": "ExtrapolateZero"}}`), &p2))
+	require.Error(t, json.Unmarshal([]byte(`{"spline": {"degree": 1, "expanded_knots": [0, 0, 1, 1], "control_points": [1, 2], "extrapolation": "Unknown"}}`), &p2))
+	err = json.Unmarshal([]byte(`{"spline": {"degree": 1, "expanded_knots": [0, 0, 1, 1], "control_points": [1, 2], "extrapolation": "ExtrapolateZero"}, "input": {"scale": 0}}`), &p2)
+	require.ErrorIs(t, err, ErrInvalidArgument)
+	err = json.Unmarshal([]byte(`{"spline": {"degree": 1, "expanded_knots": [0, 1, 1, 2], "control_points": [1, 2], "extrapolation": "ExtrapolateZero"}, "input": {"scale": 1}}`), &p2)
+	require.ErrorIs(t, err, ErrOutOfDomain)
+
+	// Invalid expanded knots are rejected when decoding, unclamped ones are accepted.
+	var decoded BSpline
+	for knots, want := range map[string]error{
+		"[0, 0, 0, 1, 0.5, 1]":                   ErrKnotsNotSorted,
+		"[0, 0, 0, 0.5, 0.5, 0.5, 0.5, 1, 1, 1]": ErrInvalidArgument,
+		"[0, 0, 0.5, 1, 1, 1]":                   ErrInvalidArgument,
+		"[0, 0, 0, 0.5, 1, 1]":                   ErrInvalidArgument,
+	} {
+		err = json.Unmarshal([]byte(`{"degree": 2, "expanded_knots": `+knots+`, "extrapolation": "ExtrapolateZero"}`), &decoded)
+		require.ErrorIs(t, err, want, knots)
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"degree": 2, "expanded_knots": [-2, -1, 0, 0.5, 0.5, 0.5, 1, 2, 3], "extrapolation": "ExtrapolateZero"}`), &decoded))
+	require.NoError(t, json.Unmarshal([]byte(`{"degree": 2, "expanded_knots": [0, 0, 0, 0.5, 1, 2, 3], "extrapolation": "ExtrapolateZero"}`), &decoded))
+}
+
+func TestEvaluateLinspace(t *testing.T) {
+	b := RandomBSpline(rand.New(rand.NewPCG(18, 18)), 3, 10)
+	xs, ys := b.EvaluateLinspace(-0.5, 1.5, 21)
+	require.Len(t, xs, 21)
+	assert.Equal(t, -0.5, xs[0])
+	assert.Equal(t, 1.5, xs[20])
+	for ii, x := range xs {
+		assert.Equal(t, b.Evaluate(x), ys[ii])
+	}
+	xs, _ = b.EvaluateLinspace(0.3, 1, 1)
+	assert.Equal(t, []float64{0.3}, xs)
+	xs, ys = b.EvaluateLinspace(0, 1, 0)
+	assert.Empty(t, xs)
+	assert.Empty(t, ys)
+	err := exceptions.TryCatch[error](func() { b.EvaluateLinspace(0, 1, -1) })
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+}
+
+func TestFitControlPoints(t *testing.T) {
+	rng := rand.New(rand.NewPCG(19, 19))
+	want := RandomBSpline(rng, 3, 10, RandomKnots())
+	xs, ys := want.EvaluateLinspace(0, 1, 200)
+	b := New(3, want.Knots())
+	b.WithControlPoints(b.FitControlPoints(xs, ys))
+	assert.InDeltaSlice(t, want.ControlPoints(), b.ControlPoints(), 1e-9)
+
+	// NaN and out-of-domain xs are ignored.
+	xs = append(xs, math.NaN(), -1, 2)
+	ys = append(ys, 100, 100, 100)
+	assert.InDeltaSlice(t, want.ControlPoints(), b.FitControlPoints(xs, ys), 1e-9)
+}
+
+func TestSamples(t *testing.T) {
+	b := RandomBSpline(rand.New(rand.NewPCG(20, 20)), 2, 7)
+	wantXs, wantYs := b.EvaluateLinspace(-0.1, 1.1, 50)
+	var ii int
+	for x, y := range b.Samples(-0.1, 1.1, 50) {
+		require.Equal(t, wantXs[ii], x)
+		require.Equal(t, wantYs[ii], y)
+		ii++
+	}
+	assert.Equal(t, 50, ii)
+
+	// Early break.
+	ii = 0
+	for range b.Samples(0, 1, 50) {
+		ii++
+		if ii == 10 {
+			break
+		}
+	}
+	assert.Equal(t, 10, ii)
+}
+
+func TestEvaluateBank(t *testing.T) {
+	rng := rand.New(rand.NewPCG(21, 21))
+	b := RandomBSpline(rng, 3, 12, RandomKnots(), RandomExtrapolation(ExtrapolateLinear))
+	bank := make([][]float64, 100)
+	for ii := range bank {
+		bank[ii] = RandomBSpline(rng, 3, 12).ControlPoints()
+	}
+	for _, x := range []float64{-0.5, 0, 0.37, 0.99, 1, 1.5} {
+		ys := b.EvaluateBank(bank, x)
+		for ii, control := range bank {
+			want := New(3, b.Knots()).WithExtrapolation(ExtrapolateLinear).WithControlPoints(control).Evaluate(x)
+			require.InDelta(t, want, ys[ii], 1e-12, "x=%g, spline #%d", x, ii)
+		}
+	}
+	assert.Panics(t, func() { b.EvaluateBank([][]float64{{1, 2}}, 0.5) })
+}
+
+func TestUniformCubic(t *testing.T) {
+	rng := rand.New(rand.NewPCG(22, 22))
+	b := RandomBSpline(rng, 3, 15)
+	require.True(t, b.uniform)
+	basis := make([]float64, 4)
+	for x := 0.0; x < 1; x += 0.0037 {
+		span := b.SpanIndex(x)
+		b.localBasis(span, x, 3, basis)
+		want := b.combine(span, basis)
+		require.InDelta(t, want, b.Evaluate(x), 1e-12, "x=%g", x)
+	}
+	// Knots exactly at the spans boundaries.
+	for ii, knot := range b.Knots()[:len(b.Knots())-1] {
+		assert.Equal(t, 3+ii, b.SpanIndex(knot))
+	}
+}
+
+func BenchmarkUniformCubic(b *testing.B) {
+	rng := rand.New(rand.NewPCG(42, 42))
+	spline := RandomBSpline(rng, 3, 32)
+	xs := make([]float64, 1000)
+	for ii := range xs {
+		xs[ii] = rng.Float64()
+	}
+	output := make([]float64, len(xs))
+	for _, uniform := range []bool{true, false} {
+		b.Run(fmt.Sprintf("uniform=%v", uniform), func(b *testing.B) {
+			spline.uniform = uniform
+			for range b.N {
+				spline.EvaluateBatchInto(xs, output)
+			}
+		})
+	}
+	spline.uniform = true
+}
+
+func TestKnotArithmetic(t *testing.T) {
+	assert.Equal(t, []float64{0, 0, 1, 1, 2, 3}, MergeKnots([]float64{0, 0, 1, 2}, []float64{0, 1, 1, 3}))
+	assert.Equal(t, []float64{1, 2}, MergeKnots(nil, []float64{1, 2}))
+
+	a := New(2, []float64{0, 0.3, 1}).WithControlPoints([]float64{1, 2, 0, 1})
+	b := New(2, []float64{0, 0.5, 0.7, 1})
+	knots := CommonRefinement(a, b)
+	assert.Equal(t, []float64{0, 0.3, 0.5, 0.7, 1}, knots)
+	refined := New(2, knots).WithControlPoints(a.RefineControlPoints(knots, a.ControlPoints()))
+	for x := 0.0; x < 1; x += 0.01 {
+		require.InDelta(t, a.Evaluate(x), refined.Evaluate(x), 1e-12)
+	}
+	assert.Panics(t, func() { CommonRefinement(a, New(2, []float64{0, 2})) })
+}
+
+func TestWithEndDerivatives(t *testing.T) {
+	rng := rand.New(rand.NewPCG(23, 23))
+	for _, degree := range []int{1, 2, 3} {
+		b := RandomBSpline(rng, degree, 8, RandomKnots(), RandomExtrapolation(ExtrapolateLinear))
+		original := slices.Clone(b.ControlPoints())
+		b.WithEndDerivatives(2, -3)
+		assert.Equal(t, original[0], b.ControlPoints()[0])
+		assert.Equal(t, original[7], b.ControlPoints()[7])
+		_, left := b.EvaluateWithGradient(0)
+		_, right := b.EvaluateWithGradient(1 - 1e-12)
+		assert.InDelta(t, 2.0, left, 1e-9, "degree=%d", degree)
+		assert.InDelta(t, -3.0, right, 1e-6, "degree=%d", degree)
+		assert.InDelta(t, -3.0, b.EvaluateDerivative(1.5, 1), 1e-9, "degree=%d", degree)
+	}
+	assert.Panics(t, func() { NewRegular(2, 3).WithControlPoints([]float64{0, 1, 2}).WithEndDerivatives(1, 1) })
+}
+
+// recursiveBasisFunction is the reference Cox-de Boor recursion, used to test BasisFunction.
+func recursiveBasisFunction(knots []float64, ii, degree int, x float64) float64 {
+	if degree == 0 {
+		if x >= knots[ii] && x < knots[ii+1] {
+			return 1
+		}
+		return 0
+	}
+	var left, right float64
+	if knots[ii+degree] != knots[ii] {
+		left = (x - knots[ii]) / (knots[ii+degree] - knots[ii]) * recursiveBasisFunction(knots, ii, degree-1, x)
+	}
+	if knots[ii+degree+1] != knots[ii+1] {
+		right = (knots[ii+degree+1] - x) / (knots[ii+degree+1] - knots[ii+1]) * recursiveBasisFunction(knots, ii+1, degree-1, x)
+	}
+	return left + right
+}
+
+func TestBasisFunction(t *testing.T) {
+	rng := rand.New(rand.NewPCG(24, 24))
+	for _, degree := range []int{0, 1, 2, 5, 9} {
+		b := RandomBSpline(rng, degree, degree+6, RandomKnots())
+		for x := -0.1; x < 1.1; x += 0.0173 {
+			for ii := range b.NumControlPoints() {
+				for d := 0; d <= degree; d++ {
+					if ii+d+1 >= len(b.ExpandedKnots()) {
+						continue
+					}
+					require.InDelta(t, recursiveBasisFunction(b.ExpandedKnots(), ii, d, x), b.BasisFunction(ii, d, x), 1e-12,
+						"degree=%d, ii=%d, d=%d, x=%g", degree, ii, d, x)
+				}
+			}
+		}
+	}
+}
+
+func TestBasisFunctionsAt(t *testing.T) {
+	rng := rand.New(rand.NewPCG(25, 25))
+	for _, extrapolation := range []ExtrapolationType{ExtrapolateZero, ExtrapolateConstant, ExtrapolateLinear} {
+		for _, degree := range []int{1, 2, 4} {
+			b := RandomBSpline(rng, degree, 9, RandomKnots(), RandomExtrapolation(extrapolation))
+			for x := -0.5; x < 1.5; x += 0.031 {
+				first, values := b.BasisFunctionsAt(x)
+				require.Len(t, values, degree+1)
+				var y float64
+				for r, value := range values {
+					y += value * b.ControlPoints()[first+r]
+					if x >= 0 && x < 1 {
+						require.InDelta(t, b.BasisFunction(first+r, degree, x), value, 1e-12)
+					}
+				}
+				require.InDelta(t, b.Evaluate(x), y, 1e-12, "%s, degree=%d, x=%g", extrapolation, degree, x)
+			}
+		}
+	}
+}
+
+func TestRoundingErrorBounds(t *testing.T) {
+	rng := rand.New(rand.NewPCG(26, 26))
+	b := RandomBSpline(rng, 7, 15, RandomKnots(), RandomBounded(-1e6, 1e6))
+	bounds := b.RoundingErrorBounds()
+	require.Len(t, bounds, len(b.Knots())-1)
+	const prec = 300
+	bf := func(v float64) *big.Float { return new(big.Float).SetPrec(prec).SetFloat64(v) }
+	knots := b.ExpandedKnots()
+	for _, bound := range bounds {
+		require.Greater(t, bound.Bound, bound.CompensatedBound)
+		for x := bound.Start; x < bound.End; x += (bound.End - bound.Start) / 7 {
+			// Reference evaluation with the De Boor triangle in high precision.
+			span := bound.Span
+			basis := make([]*big.Float, 8)
+			basis[0] = bf(1)
+			for jj := 1; jj <= 7; jj++ {
+				saved := bf(0)
+				for r := range jj {
+					left := bf(0).Sub(bf(x), bf(knots[span+1-jj+r]))
+					right := bf(0).Sub(bf(knots[span+1+r]), bf(x))
+					temp := bf(0).Quo(basis[r], bf(0).Add(right, left))
+					basis[r] = bf(0).Add(saved, bf(0).Mul(right, temp))
+					saved = bf(0).Mul(left, temp)
+				}
+				basis[jj] = saved
+			}
+			sum := bf(0)
+			for r, weight := range basis {
+				sum.Add(sum, bf(0).Mul(weight, bf(b.ControlPoints()[span-7+r])))
+			}
+			want, _ := sum.Float64()
+			require.LessOrEqual(t, math.Abs(b.Evaluate(x)-want), bound.Bound, "x=%g", x)
+		}
+	}
+}
+
+func TestNonFinite(t *testing.T) {
+	for _, numControlPoints := range []int{5, 12} {
+		b := RandomBSpline(rand.New(rand.NewPCG(27, 27)), 3, numControlPoints, RandomExtrapolation(ExtrapolateLinear))
+		xs := []float64{math.NaN(), math.Inf(1), math.Inf(-1), 0.5}
+		ys := b.EvaluateBatch(xs)
+		assert.True(t, math.IsNaN(ys[0]))
+		assert.True(t, math.IsNaN(b.Evaluate(math.NaN())))
+		assert.True(t, math.IsNaN(b.NewEvalScratch().Evaluate(math.NaN())))
+		assert.True(t, math.IsNaN(b.Vectorized().Evaluate([]float64{math.NaN()})[0]))
+		bank := [][]float64{b.ControlPoints(), b.ControlPoints()}
+		for _, y := range b.EvaluateBank(bank, math.NaN()) {
+			assert.True(t, math.IsNaN(y))
+		}
+		assert.True(t, math.IsNaN(b.EvaluateTensor([][]float64{{math.NaN()}}, [][][]float64{bank})[0][0][0]))
+		for _, y := range b.EvaluateSparseBank([]*SparseControlPoints{NewSparseControlPoints(b.ControlPoints(), b.ControlPoints(), 0)}, math.NaN()) {
+			assert.True(t, math.IsNaN(y))
+		}
+		vectorized := b.Vectorized().Evaluate(xs)
+		assert.True(t, math.IsNaN(vectorized[0]))
+		assert.Equal(t, ys[1:3], vectorized[1:3])
+		assert.InDelta(t, ys[3], vectorized[3], 1e-12)
+		value, dydx := b.EvaluateWithGradient(math.NaN())
+		assert.True(t, math.IsNaN(value) && math.IsNaN(dydx))
+		for order, y := range b.EvaluateDerivatives(math.NaN(), 3) {
+			assert.True(t, math.IsNaN(y), "order=%d", order)
+		}
+		assert.True(t, math.IsNaN(b.EvaluateDerivative(math.NaN(), 1)))
+		for order, ys := range NewGridPlan(b, []float64{math.NaN()}, 2).EvaluateAll() {
+			assert.True(t, math.IsNaN(ys[0]), "order=%d", order)
+		}
+		assert.Equal(t, b.Evaluate(math.Inf(1)), ys[1])
+		assert.Equal(t, b.Evaluate(math.Inf(-1)), ys[2])
+		assert.Equal(t, b.Evaluate(0.5), ys[3])
+	}
+}
+
+func TestWithClosedDomain(t *testing.T) {
+	rng := rand.New(rand.NewPCG(28, 28))
+	b := RandomBSpline(rng, 3, 12, RandomExtrapolation(ExtrapolateZero))
+	last := b.ControlPoints()[11]
+	assert.Equal(t, 0.0, b.Evaluate(1))
+	b.WithClosedDomain(true)
+	assert.InDelta(t, last, b.Evaluate(1), 1e-12)
+	assert.InDelta(t, last, b.EvaluateBatch([]float64{1})[0], 1e-12)
+	assert.InDelta(t, last, b.NewEvalScratch().Evaluate(1), 1e-12)
+	assert.InDelta(t, last, b.Vectorized().Evaluate([]float64{1})[0], 1e-12)
+	assert.InDelta(t, last, NewEvaluator[float64](b).Evaluate(1), 1e-12)
+	value, _ := b.EvaluateWithGradient(1)
+	assert.InDelta(t, last, value, 1e-12)
+	_, ys := b.EvaluateLinspace(0, 1, 11)
+	assert.InDelta(t, last, ys[10], 1e-12)
+	assert.Equal(t, 0.0, b.Evaluate(1+1e-12))
+
+	// Derived B-splines keep the closed domain.
+	other := RandomBSpline(rng, 2, 7, RandomKnots(), RandomExtrapolation(ExtrapolateZero)).WithClosedDomain(true)
+	var decoded BSpline
+	data, err := json.Marshal(b)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	for name, derived := range map[string]*BSpline{
+		"Derivative":    b.Derivative(),
+		"DerivativeN":   b.DerivativeN(2),
+		"Refine":        b.Refine(b.DoubledKnots()),
+		"Subdivide":     b.Subdivide(1),
+		"FromPPoly":     FromPPoly(b.ToPPoly()),
+		"Add":           Add(b, other),
+		"Sub":           Sub(b, other),
+		"Blend":         Blend(b, other, 0.5, 0.2),
+		"AffineY":       b.AffineY(2, 1),
+		"Fair":          b.Fair(0.1),
+		"Average":       Average([]*BSpline{b, b.Refine(b.DoubledKnots())}, nil),
+		"RescaleDomain": b.RescaleDomain(0, 1),
+		"JSON":          &decoded,
+	} {
+		domainMin, domainMax := derived.Domain()
+		require.Equal(t, 1.0, domainMax, name)
+		// Continuous from the left, instead of extrapolated to 0.
+		assert.InDelta(t, derived.Evaluate(math.Nextafter(domainMax, domainMin)), derived.Evaluate(domainMax), 1e-9, name)
+		assert.NotEqual(t, 0.0, derived.Evaluate(domainMax), name)
+	}
+	assert.InDelta(t, last, b.ToPPoly().Evaluate(1), 1e-9)
+	assert.Panics(t, func() { Add(b, other.WithClosedDomain(false)) })
+}
+
+func TestEvaluateTensor(t *testing.T) {
+	rng := rand.New(rand.NewPCG(29, 29))
+	b := RandomBSpline(rng, 2, 8, RandomExtrapolation(ExtrapolateLinear))
+	const batchSize, numInputs, numOutputs = 5, 3, 2
+	inputs := make([][]float64, batchSize)
+	for ee := range inputs {
+		inputs[ee] = make([]float64, numInputs)
+		for ii := range inputs[ee] {
+			inputs[ee][ii] = -0.2 + 1.4*rng.Float64()
+		}
+	}
+	controlPoints := make([][][]float64, numInputs)
+	for ii := range controlPoints {
+		controlPoints[ii] = make([][]float64, numOutputs)
+		for oo := range controlPoints[ii] {
+			controlPoints[ii][oo] = RandomBSpline(rng, 2, 8).ControlPoints()
+		}
+	}
+	result := b.EvaluateTensor(inputs, controlPoints)
+	require.Len(t, result, batchSize)
+	for ee := range batchSize {
+		require.Len(t, result[ee], numOutputs)
+		for oo := range numOutputs {
+			require.Len(t, result[ee][oo], numInputs)
+			for ii := range numInputs {
+				want := New(2, b.Knots()).WithExtrapolation(ExtrapolateLinear).WithControlPoints(controlPoints[ii][oo]).Evaluate(inputs[ee][ii])
+				assert.InDelta(t, want, result[ee][oo][ii], 1e-12)
+			}
+		}
+	}
+	assert.Panics(t, func() { b.EvaluateTensor([][]float64{{0.5}}, controlPoints) })
+}
+
+func TestEvaluateDerivatives(t *testing.T) {
+	b := RandomBSpline(rand.New(rand.NewPCG(31, 31)), 4, 10, RandomKnots(), RandomExtrapolation(ExtrapolateLinear))
+	for x := -0.2; x < 1.2; x += 0.05 {
+		results := b.EvaluateDerivatives(x, 6)
+		require.Len(t, results, 7)
+		for order := range 5 {
+			assert.InDelta(t, b.DerivativeN(order).Evaluate(x), results[order], 1e-8, "x=%g, order=%d", x, order)
+		}
+		assert.Equal(t, 0.0, results[5])
+		assert.Equal(t, 0.0, results[6])
+	}
+}
+
+func TestErrors(t *testing.T) {
+	catch := func(fn func()) error { return exceptions.TryCatch[error](fn) }
+	assert.ErrorIs(t, catch(func() { New(2, []float64{0}) }), ErrNotEnoughKnots)
+	assert.ErrorIs(t, catch(func() { New(2, []float64{0, 1, 1}) }), ErrInvalidArgument)
+	assert.ErrorIs(t, catch(func() { NewRegular(2, 5).WithControlPoints([]float64{1, 2}) }), ErrControlPointCount)
+	assert.ErrorIs(t, catch(func() { NewRegular(2, 5).Evaluate(0.5) }), ErrControlPointsNotSet)
+	assert.ErrorIs(t, catch(func() { NewRegular(2, 5).QuadratureNodes(0, 2) }), ErrOutOfDomain)
+	assert.ErrorIs(t, catch(func() { CommonRefinement(NewRegular(2, 5), New(2, []float64{0, 2})) }), ErrIncompatibleSplines)
+	assert.ErrorIs(t, catch(func() { NewRegular(2, 5).RefineControlPoints([]float64{0, 0.5, 1}, make([]float64, 5)) }), ErrInvalidArgument)
+	assert.ErrorIs(t, catch(func() { New(2, []float64{0, 1}).RefineControlPoints([]float64{0, 1, 2}, []float64{0, 1, 2}) }), ErrInvalidArgument)
+	assert.ErrorIs(t, catch(func() { New(2, []float64{0, 1}).RefineControlPoints([]float64{-1, 0, 1}, []float64{0, 1, 2}) }), ErrInvalidArgument)
+	assert.ErrorIs(t, catch(func() { New(2, []float64{0, 1}).RefineControlPoints([]float64{0, 0.7, 0.3, 1}, []float64{0, 1, 2}) }), ErrInvalidArgument)
+	assert.ErrorIs(t, catch(func() { New(2, []float64{0, 1}).WithControlPoints([]float64{0, 1, 2}).Refine([]float64{0, 1, 2}) }), ErrInvalidArgument)
+	err := catch(func() { NewRegular(2, 5).Evaluate(0.5) })
+	assert.Contains(t, err.Error(), "BSpline.Evaluate()")
+
+	_, err = NewRegular(2, 5).Inverse(10)
+	assert.ErrorIs(t, err, ErrControlPointsNotSet)
+}
+
+func TestRegistry(t *testing.T) {
+	b := RandomBSpline(rand.New(rand.NewPCG(32, 32)), 3, 8)
+	data, err := json.Marshal(NewPipeline(b))
+	require.NoError(t, err)
+	r := NewRegistry()
+	r.Register("price", data)
+	r.Register("broken", []byte(`{}`))
+	r.Register("unusable", []byte(`{"spline": {"degree": 1, "expanded_knots": [0, 0, 1, 1], "control_points": [1, 2], "extrapolation": "ExtrapolateZero"}, "input": {"scale": 0}}`))
+	assert.Equal(t, []string{"broken", "price", "unusable"}, r.Names())
+
+	p, err := r.Get("price")
+	require.NoError(t, err)
+	assert.Equal(t, b.Evaluate(0.3), p.Evaluate(0.3))
+	p2, err := r.Get("price")
+	require.NoError(t, err)
+	assert.Same(t, p, p2)
+
+	_, err = r.Get("broken")
+	assert.Error(t, err)
+	_, err = r.Get("unusable")
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+	_, err = r.Get("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	r.Remove("price")
+	_, err = r.Get("price")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestStore(t *testing.T) {
+	b := RandomBSpline(rand.New(rand.NewPCG(33, 33)), 2, 6)
+	data, err := json.Marshal(NewPipeline(b))
+	require.NoError(t, err)
+	s := NewStore()
+	require.NoError(t, s.Update(map[string][]byte{"a": data}))
+	p, found := s.Get("a")
+	require.True(t, found)
+	assert.Equal(t, b.Evaluate(0.4), p.Evaluate(0.4))
+
+	// Invalid updates are rejected as a whole.
+	invalid := []byte(`{"spline": {"degree": 1, "expanded_knots": [0, 0, 1, 1], "control_points": [1, 2], "extrapolation": "ExtrapolateZero"}, "input": {"scale": 0}}`)
+	assert.ErrorIs(t, s.Update(map[string][]byte{"a": data, "b": invalid}), ErrInvalidArgument)
+	assert.Error(t, s.Update(map[string][]byte{"b": []byte(`{}`)}))
+	assert.Equal(t, []string{"a"}, s.Names())
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "x.json"), data, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0o644))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Watch(ctx, dir, time.Millisecond, func(err error) { t.Error(err) })
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return slices.Equal(s.Names(), []string{"x"}) }, time.Second, time.Millisecond)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "y.json"), data, 0o644))
+	require.Eventually(t, func() bool { return slices.Equal(s.Names(), []string{"x", "y"}) }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+	require.Panics(t, func() { s.Watch(context.Background(), dir, 0, nil) })
+}
+
+func TestCoverage(t *testing.T) {
+	b := New(2, []float64{0, 1, 3}).WithControlPoints([]float64{1, 2, 3, 4})
+	c := b.NewCoverage()
+	for _, x := range []float64{-1, 0, 0.5, 1, 2, 2.5, 3, 4} {
+		assert.Equal(t, b.Evaluate(x), c.Evaluate(x))
+	}
+	c.Record(math.NaN())
+	stats := c.Stats()
+	assert.Equal(t, CoverageStats{Total: 9, Below: 1, Above: 2, NaN: 1, SpanCounts: []int64{2, 3}}, stats)
+	assert.InDelta(t, 4.0/9, stats.ExtrapolatedFraction(), 1e-12)
+	assert.JSONEq(t, `{"total": 9, "below": 1, "above": 2, "nan": 1, "span_counts": [2, 3]}`, c.String())
+
+	// Unclamped B-splines: values below the domain, but above the first expanded knot, are below.
+	periodic := NewPeriodic(3, []float64{0, 1, 2, 3, 4}).WithControlPoints([]float64{1, 2, 3, 4}).BSpline().NewCoverage()
+	for _, x := range []float64{-1, -5, 0.5, 4, 4.5} {
+		periodic.Record(x)
+	}
+	periodicStats := periodic.Stats()
+	assert.Equal(t, int64(2), periodicStats.Below)
+	assert.Equal(t, int64(2), periodicStats.Above)
+	c.Reset()
+	assert.Equal(t, 0.0, c.Stats().ExtrapolatedFraction())
+}
+
+func TestSortedEvaluator(t *testing.T) {
+	b := RandomBSpline(rand.New(rand.NewPCG(34, 34)), 3, 12, RandomKnots(), RandomExtrapolation(ExtrapolateLinear)).WithClosedDomain(true)
+	s := b.NewSortedEvaluator()
+	for x := -0.3; x <= 1.3; x += 0.01 {
+		require.InDelta(t, b.Evaluate(x), s.Next(x), 1e-12, "x=%g", x)
+	}
+	assert.Equal(t, b.Evaluate(1.3), s.Next(1.3))
+	assert.Panics(t, func() { s.Next(0.5) })
+	s.Reset()
+	assert.Equal(t, b.Evaluate(0.5), s.Next(0.5))
+}
+
+func TestGolden(t *testing.T) {
+	b := RandomBSpline(rand.New(rand.NewPCG(35, 35)), 3, 8, RandomExtrapolation(ExtrapolateLinear))
+	samples := b.GoldenSamples(11)
+	assert.InDelta(t, -0.1, samples.Xs[0], 1e-12)
+	assert.InDelta(t, 1.1, samples.Xs[10], 1e-12)
+	path := filepath.Join(t.TempDir(), "golden.json")
+	require.NoError(t, samples.WriteFile(path))
+	require.NoError(t, b.CompareGolden(path, 1e-12))
+
+	control := slices.Clone(b.ControlPoints())
+	control[3] += 1e-3
+	err := NewRegular(3, 8).WithExtrapolation(ExtrapolateLinear).WithControlPoints(control).CompareGolden(path, 1e-6)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "mismatch")
+	assert.Error(t, b.CompareGolden(filepath.Join(t.TempDir(), "missing.json"), 1e-6))
+}
+
+func TestNewWithMultiplicities(t *testing.T) {
+	b := NewWithMultiplicities(3, []float64{-1, 0, 1}, []int{1, 3, 1})
+	require.Equal(t, []float64{-1, -1, -1, -1, 0, 0, 0, 1, 1, 1, 1}, b.ExpandedKnots())
+	require.Equal(t, 7, b.NumControlPoints())
+	b.WithControlPoints([]float64{1, 2 / 3.0, 1 / 3.0, 0, 1 / 3.0, 2 / 3.0, 1})
+
+	// The kink at 0: |x| is represented exactly, with different slopes on each side.
+	for x := -1.0; x < 1; x += 0.125 {
+		assert.InDelta(t, math.Abs(x), b.Evaluate(x), 1e-12, "x=%g", x)
+	}
+	_, left := b.EvaluateWithGradient(-1e-9)
+	_, right := b.EvaluateWithGradient(1e-9)
+	assert.InDelta(t, -1, left, 1e-6)
+	assert.InDelta(t, 1, right, 1e-6)
+
+	// Multiplicities of 1 are the same as New.
+	assert.Equal(t, New(2, []float64{0, 0.5, 1}).ExpandedKnots(), NewWithMultiplicities(2, []float64{0, 0.5, 1}, []int{1, 1, 1}).ExpandedKnots())
+	assert.Panics(t, func() { NewWithMultiplicities(2, []float64{0, 0.5, 1}, []int{1, 3, 1}) })
+	assert.Panics(t, func() { NewWithMultiplicities(2, []float64{0, 0.5, 1}, []int{2, 1, 1}) })
+	assert.Panics(t, func() { NewWithMultiplicities(2, []float64{0, 0.5, 1}, []int{1, 1}) })
+}
+
+func TestToPPoly(t *testing.T) {
+	rng := rand.New(rand.NewPCG(36, 36))
+	splines := []*BSpline{
+		RandomBSpline(rng, 3, 10, RandomKnots(), RandomExtrapolation(ExtrapolateLinear)),
+		RandomBSpline(rng, 2, 7, RandomExtrapolation(ExtrapolateConstant)),
+		RandomBSpline(rng, 5, 12, RandomKnots(), RandomExtrapolation(ExtrapolateZero)),
+		NewWithMultiplicities(3, []float64{0, 0.4, 1}, []int{1, 3, 1}).WithControlPoints([]float64{1, 0, 2, -1, 3, 0, 1}),
+	}
+	for _, b := range splines {
+		pp := b.ToPPoly()
+		assert.Equal(t, b.Degree(), pp.Degree())
+		breaks, _ := b.KnotMultiplicities()
+		assert.Equal(t, breaks, pp.Breaks)
+		for x := -0.3; x <= 1.3; x += 0.01 {
+			require.InDelta(t, b.Evaluate(x), pp.Evaluate(x), 1e-9, "degree=%d, x=%g", b.Degree(), x)
+		}
+		assert.InDelta(t, b.Evaluate(1), pp.Evaluate(1), 1e-9)
+		assert.True(t, math.IsNaN(pp.Evaluate(math.NaN())))
+	}
+}
+
+func TestFromPPoly(t *testing.T) {
+	rng := rand.New(rand.NewPCG(37, 37))
+	for _, b := range []*BSpline{
+		RandomBSpline(rng, 3, 10, RandomKnots(), RandomExtrapolation(ExtrapolateLinear)),
+		RandomBSpline(rng, 1, 5, RandomExtrapolation(ExtrapolateConstant)),
+		NewWithMultiplicities(3, []float64{0, 0.4, 1}, []int{1, 2, 1}).WithControlPoints([]float64{1, 0, 2, -1, 3, 1}),
+	} {
+		b2 := FromPPoly(b.ToPPoly())
+		assert.InDeltaSlice(t, b.ExpandedKnots(), b2.ExpandedKnots(), 1e-12)
+		assert.InDeltaSlice(t, b.ControlPoints(), b2.ControlPoints(), 1e-9)
+		assert.Equal(t, b.Extrapolation(), b2.Extrapolation())
+	}
+
+	// Discontinuous piecewise polynomial: the break becomes a knot with multiplicity degree+1.
+	pp := &PPoly{
+		Breaks:        []float64{0, 1, 2},
+		Coefficients:  [][]float64{{0, 1, 0}, {5, 0, 1}},
+		Extrapolation: ExtrapolateZero,
+	}
+	b := FromPPoly(pp)
+	assert.Equal(t, []float64{0, 0, 0, 1, 1, 1, 2, 2, 2}, b.ExpandedKnots())
+	for x := -0.5; x < 2.5; x += 0.05 {
+		assert.InDelta(t, pp.Evaluate(x), b.Evaluate(x), 1e-9, "x=%g", x)
+	}
+	assert.Panics(t, func() { FromPPoly(&PPoly{Breaks: []float64{0, 1}}) })
+}
+
+func TestAddSub(t *testing.T) {
+	rng := rand.New(rand.NewPCG(38, 38))
+	a := RandomBSpline(rng, 3, 8, RandomKnots(), RandomExtrapolation(ExtrapolateLinear))
+	for _, b := range []*BSpline{
+		RandomBSpline(rng, 3, 6, RandomKnots(), RandomExtrapolation(ExtrapolateLinear)),
+		RandomBSpline(rng, 1, 5, RandomKnots(), RandomExtrapolation(ExtrapolateLinear)),
+		RandomBSpline(rng, 2, 4, RandomExtrapolation(ExtrapolateLinear)),
+		New(2, []float64{0, 0.3, 0.3, 1}).WithExtrapolation(ExtrapolateLinear).WithControlPoints([]float64{1, -1, 2, 0, 1}),
+	} {
+		sum, diff := Add(a, b), Sub(a, b)
+		assert.Equal(t, 3, sum.Degree())
+		for x := -0.5; x < 1.5; x += 0.01 {
+			require.InDelta(t, a.Evaluate(x)+b.Evaluate(x), sum.Evaluate(x), 1e-9, "degree=%d, x=%g", b.Degree(), x)
+			require.InDelta(t, a.Evaluate(x)-b.Evaluate(x), diff.Evaluate(x), 1e-9, "degree=%d, x=%g", b.Degree(), x)
+		}
+	}
+
+	// A step function (degree 0) added to a smooth spline: the steps are kept with knots of multiplicity degree+1.
+	a.WithExtrapolation(ExtrapolateConstant)
+	step := RandomBSpline(rng, 0, 4, RandomKnots())
+	sum := Add(a, step)
+	for x := -0.5; x < 1.5; x += 0.01 {
+		require.InDelta(t, a.Evaluate(x)+step.Evaluate(x), sum.Evaluate(x), 1e-9, "x=%g", x)
+	}
+
+	assert.Panics(t, func() { Add(a, RandomBSpline(rng, 3, 6, RandomExtrapolation(ExtrapolateZero))) })
+	assert.Panics(t, func() { Sub(a, NewRegular(3, 6)) })
+}
+
+func TestAffineY(t *testing.T) {
+	rng := rand.New(rand.NewPCG(39, 39))
+	b := RandomBSpline(rng, 3, 8, RandomKnots(), RandomExtrapolation(ExtrapolateLinear))
+	scaled, shifted, affine := b.ScaleY(2.5), b.ShiftY(-1), b.AffineY(0.5, 3)
+	for x := -0.5; x < 1.5; x += 0.01 {
+		y := b.Evaluate(x)
+		require.InDelta(t, 2.5*y, scaled.Evaluate(x), 1e-12, "x=%g", x)
+		require.InDelta(t, y-1, shifted.Evaluate(x), 1e-12, "x=%g", x)
+		require.InDelta(t, 0.5*y+3, affine.Evaluate(x), 1e-12, "x=%g", x)
+	}
+	assert.Equal(t, b.ExpandedKnots(), affine.ExpandedKnots())
+	assert.Panics(t, func() { NewRegular(2, 5).ScaleY(2) })
+}
+
+func TestFair(t *testing.T) {
+	rng := rand.New(rand.NewPCG(40, 40))
+	b := RandomBSpline(rng, 3, 12, RandomKnots(), RandomExtrapolation(ExtrapolateLinear))
+	p := b.PenaltyMatrix(2)
+	energy := func(s *BSpline) float64 {
+		c := s.ControlPoints()
+		var sum float64
+		for ii, value := range matVecMul(p, c) {
+			sum += value * c[ii]
+		}
+		return sum
+	}
+	previous := energy(b)
+	for _, tolerance := range []float64{0.01, 0.1, 1} {
+		faired := b.Fair(tolerance)
+		require.Less(t, energy(faired), previous, "tolerance=%g", tolerance)
+		previous = energy(faired)
+		for x := 0.0; x < 1; x += 0.005 {
+			require.LessOrEqual(t, math.Abs(faired.Evaluate(x)-b.Evaluate(x)), tolerance+1e-12, "tolerance=%g, x=%g", tolerance, x)
+		}
+	}
+	assert.Equal(t, b.ControlPoints(), b.Fair(0).ControlPoints())
+
+	// A large tolerance allows for a straight line, with no bending energy.
+	assert.InDelta(t, 0, energy(b.Fair(1000)), 1e-6)
+	assert.Panics(t, func() { RandomBSpline(rng, 1, 5).Fair(0.1) })
+}
+
+func TestInterpolateCurve(t *testing.T) {
+	points := [][]float64{{0, 0}, {1, 1}, {2, 0}, {3, 1}, {4, 0}}
+	for _, tangents := range [][][]float64{
+		nil,
+		{{1, 0}, nil, {0, -1}, nil, {1, 0}},
+		{{1, 1}, {1, 0}, {1, -1}, {1, 0}, {0, -1}},
+	} {
+		c := InterpolateCurve(points, tangents)
+		require.Equal(t, 2, c.Dim())
+		for ii, param := range ChordLengthParams(points) {
+			require.InDeltaSlice(t, points[ii], c.Evaluate(param), 1e-9, "point #%d, tangents=%v", ii, tangents)
+			if tangents == nil || tangents[ii] == nil {
+				continue
+			}
+			// Tangent must be parallel, in the same direction.
+			tangent := c.Tangent(param)
+			cross := tangent[0]*tangents[ii][1] - tangent[1]*tangents[ii][0]
+			dot := tangent[0]*tangents[ii][0] + tangent[1]*tangents[ii][1]
+			require.InDelta(t, 0, cross, 1e-9, "point #%d", ii)
+			require.Greater(t, dot, 0.0, "point #%d", ii)
+		}
+	}
+
+	// Two points: a straight segment.
+	c := InterpolateCurve([][]float64{{0, 0, 0}, {1, 2, 3}}, nil)
+	assert.Equal(t, 1, c.BSpline().Degree())
+	assert.InDeltaSlice(t, []float64{0.5, 1, 1.5}, c.Evaluate(0.5), 1e-12)
+
+	assert.Panics(t, func() { InterpolateCurve([][]float64{{0, 0}, {0, 0}}, nil) })
+	assert.Panics(t, func() { InterpolateCurve(points, [][]float64{{1, 0}}) })
+}
+
+func TestRescaleDomain(t *testing.T) {
+	rng := rand.New(rand.NewPCG(41, 41))
+	b := RandomBSpline(rng, 3, 9, RandomKnots(), RandomExtrapolation(ExtrapolateLinear))
+	rescaled := b.RescaleDomain(-10, 30)
+	domainMin, domainMax := rescaled.Domain()
+	assert.Equal(t, -10.0, domainMin)
+	assert.Equal(t, 30.0, domainMax)
+	for x := -0.5; x < 1.5; x += 0.01 {
+		y, dy := b.EvaluateWithGradient(x)
+		y2, dy2 := rescaled.EvaluateWithGradient(-10 + 40*x)
+		require.InDelta(t, y, y2, 1e-9, "x=%g", x)
+		require.InDelta(t, dy/40, dy2, 1e-9, "x=%g", x)
+	}
+	assert.Panics(t, func() { b.RescaleDomain(1, 1) })
+}
+
+func TestCurveClearance(t *testing.T) {
+	// Unit circle (approximately), centered at the origin.
+	var points [][]float64
+	for ii := range 17 {
+		angle := 2 * math.Pi * float64(ii) / 16
+		points = append(points, []float64{math.Cos(angle), math.Sin(angle)})
+	}
+	c := InterpolateCurve(points, nil)
+
+	// Square around the circle, at distance 0.5.
+	square := PolygonSegments([][2]float64{{-1.5, -1.5}, {1.5, -1.5}, {1.5, 1.5}, {-1.5, 1.5}})
+	distance, param := c.Clearance(square, 100)
+	assert.InDelta(t, 0.5, distance, 1e-3)
+	point := c.Evaluate(param)
+	assert.InDelta(t, 1, max(math.Abs(point[0]), math.Abs(point[1])), 1e-3)
+
+	// An obstacle crossing the curve.
+	distance, param = c.Clearance([]Segment{{A: [2]float64{0, 0}, B: [2]float64{2, 0.1}}}, 100)
+	assert.InDelta(t, 0, distance, 1e-6)
+	point = c.Evaluate(param)
+	assert.InDelta(t, 0.05, point[1], 1e-2)
+
+	assert.Panics(t, func() { InterpolateCurve([][]float64{{0}, {1}}, nil).Clearance(square, 10) })
+}
+
+func TestSplitAt(t *testing.T) {
+	rng := rand.New(rand.NewPCG(42, 42))
+	for _, b := range []*BSpline{
+		RandomBSpline(rng, 3, 9, RandomKnots(), RandomExtrapolation(ExtrapolateLinear)),
+		RandomBSpline(rng, 2, 6, RandomExtrapolation(ExtrapolateLinear)),
+		RandomBSpline(rng, 0, 4),
+	} {
+		for _, split := range []float64{0.3, b.Knots()[1]} {
+			left, right := b.SplitAt(split)
+			leftMin, leftMax := left.Domain()
+			rightMin, rightMax := right.Domain()
+			domainMin, domainMax := b.Domain()
+			require.Equal(t, []float64{domainMin, split, split, domainMax}, []float64{leftMin, leftMax, rightMin, rightMax})
+			for x := -0.5; x < 1.5; x += 0.01 {
+				piece := left
+				if x >= split {
+					piece = right
+				}
+				require.InDelta(t, b.Evaluate(x), piece.Evaluate(x), 1e-9, "degree=%d, split=%g, x=%g", b.Degree(), split, x)
+			}
+		}
+	}
+	assert.Panics(t, func() { RandomBSpline(rng, 2, 6).SplitAt(1) })
+}
+
+func TestSubdivide(t *testing.T) {
+	rng := rand.New(rand.NewPCG(43, 43))
+	b := RandomBSpline(rng, 3, 8, RandomExtrapolation(ExtrapolateLinear))
+
+	// The control polygon converges to the curve.
+	previous := math.Inf(1)
+	for levels := range 5 {
+		subdivided := b.Subdivide(levels)
+		require.Equal(t, 5*(1<<levels)+3, subdivided.NumControlPoints())
+		var deviation float64
+		for ii, x := range subdivided.ControlPointsX() {
+			deviation = max(deviation, math.Abs(subdivided.ControlPoints()[ii]-b.Evaluate(math.Min(x, math.Nextafter(1, 0)))))
+		}
+		require.Less(t, deviation, previous, "levels=%d", levels)
+		previous = deviation
+		for x := -0.5; x < 1.5; x += 0.01 {
+			require.InDelta(t, b.Evaluate(x), subdivided.Evaluate(x), 1e-9, "levels=%d, x=%g", levels, x)
+		}
+	}
+
+	// Interior of a uniform spline: Lane–Riesenfeld, doubling and averaging degree times.
+	subdivided := b.Subdivide(1).ControlPoints()
+	doubled := make([]float64, 0, 2*b.NumControlPoints())
+	for _, value := range b.ControlPoints() {
+		doubled = append(doubled, value, value)
+	}
+	for range b.Degree() {
+		for ii := range len(doubled) - 1 {
+			doubled[ii] = (doubled[ii] + doubled[ii+1]) / 2
+		}
+		doubled = doubled[:len(doubled)-1]
+	}
+	// The first and last few control points are affected by the clamped ends.
+	require.Len(t, subdivided, len(doubled))
+	assert.InDeltaSlice(t, doubled[4:len(doubled)-4], subdivided[4:len(subdivided)-4], 1e-12)
+
+	// Repeated knots.
+	hermite := NewHermite([]float64{0, 1, 2}, []float64{1, -1, 2}, []float64{0, 3, -1})
+	for levels := range 3 {
+		subdivided := hermite.Subdivide(levels)
+		for x := -0.5; x < 2.5; x += 0.01 {
+			require.InDelta(t, hermite.Evaluate(x), subdivided.Evaluate(x), 1e-9, "levels=%d, x=%g", levels, x)
+		}
+	}
+}
+
+func TestBlend(t *testing.T) {
+	rng := rand.New(rand.NewPCG(44, 44))
+	a := RandomBSpline(rng, 3, 8, RandomKnots(), RandomExtrapolation(ExtrapolateLinear))
+	b := RandomBSpline(rng, 2, 6, RandomExtrapolation(ExtrapolateLinear))
+	blend := Blend(a, b, 0.5, 0.2)
+	require.Equal(t, 6, blend.Degree())
+	for x := -0.5; x < 1.5; x += 0.01 {
+		switch {
+		case x <= 0.4:
+			require.InDelta(t, a.Evaluate(x), blend.Evaluate(x), 1e-9, "x=%g", x)
+		case x >= 0.6:
+			require.InDelta(t, b.Evaluate(x), blend.Evaluate(x), 1e-9, "x=%g", x)
+		default:
+			w := blendWeight((x - 0.4) / 0.2)
+			require.InDelta(t, (1-w)*a.Evaluate(x)+w*b.Evaluate(x), blend.Evaluate(x), 1e-9, "x=%g", x)
+		}
+	}
+
+	// C² at the ends of the transition.
+	for _, x := range []float64{0.4, 0.6} {
+		before := blend.EvaluateDerivatives(x-1e-9, 2)
+		after := blend.EvaluateDerivatives(x+1e-9, 2)
+		assert.InDeltaSlice(t, before, after, 1e-4, "x=%g", x)
+	}
+	assert.Panics(t, func() { Blend(a, b, 0.05, 0.2) })
+}
+
+func TestPeriodic(t *testing.T) {
+	for _, degree := range []int{1, 2, 3} {
+		p := NewPeriodic(degree, []float64{0, 3, 7, 12, 15, 20, 24})
+		require.Equal(t, 6, p.NumControlPoints())
+		p.WithControlPoints([]float64{1, -1, 2, 0.5, 3, -2})
+		assert.Equal(t, 24.0, p.Period())
+
+		// Continuity across the seam.
+		for order, value := range p.BSpline().EvaluateDerivatives(0, degree-1) {
+			assert.InDelta(t, value, p.BSpline().EvaluateDerivatives(math.Nextafter(24, 0), degree-1)[order], 1e-9,
+				"degree=%d, order=%d", degree, order)
+		}
+		for x := -30.0; x < 30; x += 0.7 {
+			require.InDelta(t, p.Evaluate(x), p.Evaluate(x+24), 1e-9, "degree=%d, x=%g", degree, x)
+			require.InDelta(t, p.Evaluate(x), p.Evaluate(x-48), 1e-9, "degree=%d, x=%g", degree, x)
+		}
+	}
+
+	// Fit a daily seasonality from data spanning several days.
+	p := NewPeriodic(3, []float64{0, 2, 4, 6, 8, 10, 12, 14, 16, 18, 20, 22, 24})
+	var xs, ys []float64
+	for x := 0.0; x < 24*5; x += 0.25 {
+		xs = append(xs, x)
+		ys = append(ys, math.Sin(2*math.Pi*x/24))
+	}
+	p.WithControlPoints(p.FitControlPoints(xs, ys))
+	for x := -12.0; x < 36; x += 0.5 {
+		require.InDelta(t, math.Sin(2*math.Pi*x/24), p.Evaluate(x), 2e-3, "x=%g", x)
+	}
+	assert.Panics(t, func() { NewPeriodic(3, []float64{0, 1, 2}) })
+}
+
+func TestNewRepeatedKnots(t *testing.T) {
+	control := []float64{1, 0, 2, -1, 3, 1}
+	b := New(3, []float64{0, 0.4, 0.4, 1}).WithControlPoints(control)
+	b2 := NewWithMultiplicities(3, []float64{0, 0.4, 1}, []int{1, 2, 1}).WithControlPoints(control)
+	assert.Equal(t, b2.ExpandedKnots(), b.ExpandedKnots())
+	for x := -0.5; x < 1.5; x += 0.01 {
+		require.InDelta(t, b2.Evaluate(x), b.Evaluate(x), 1e-12, "x=%g", x)
+	}
+
+	// Multiplicity degree: a C⁰ corner, the slope jumps.
+	corner := New(2, []float64{0, 0.5, 0.5, 1}).WithControlPoints([]float64{0, 0.5, 1, 0.5, 0})
+	assert.InDelta(t, 1, corner.Evaluate(0.5), 1e-12)
+	_, slopeBefore := corner.EvaluateWithGradient(0.5 - 1e-9)
+	_, slopeAfter := corner.EvaluateWithGradient(0.5 + 1e-9)
+	assert.InDelta(t, 2, slopeBefore, 1e-6)
+	assert.InDelta(t, -2, slopeAfter, 1e-6)
+
+	// Multiplicity degree+1: a jump.
+	step := New(1, []float64{0, 0.5, 0.5, 1}).WithControlPoints([]float64{0, 0, 1, 1})
+	assert.InDelta(t, 0, step.Evaluate(0.5-1e-9), 1e-6)
+	assert.InDelta(t, 1, step.Evaluate(0.5), 1e-12)
+
+	catch := func(fn func()) error { return exceptions.TryCatch[error](fn) }
+	assert.ErrorIs(t, catch(func() { New(1, []float64{0, 0.5, 0.5, 0.5, 1}) }), ErrInvalidArgument)
+	assert.ErrorIs(t, catch(func() { New(2, []float64{0, 0, 1}) }), ErrInvalidArgument)
+	assert.ErrorIs(t, catch(func() { New(2, []float64{0, 1, 0.5}) }), ErrKnotsNotSorted)
+	assert.ErrorIs(t, catch(func() { NewHermite([]float64{0, 0.5, 0.5, 1}, make([]float64, 4), make([]float64, 4)) }), ErrKnotsNotSorted)
+}
+
+func TestSparseControlPoints(t *testing.T) {
+	rng := rand.New(rand.NewPCG(45, 45))
+	b := RandomBSpline(rng, 3, 50, RandomKnots(), RandomExtrapolation(ExtrapolateLinear))
+	baseline := b.ControlPointsX() // Identity calibration.
+	bank := make([]*SparseControlPoints, 10)
+	dense := make([][]float64, len(bank))
+	for ii := range bank {
+		dense[ii] = slices.Clone(baseline)
+		for range 3 {
+			dense[ii][rng.IntN(len(baseline))] += rng.Float64()
+		}
+		dense[ii][ii] += 1e-6 // Below the tolerance.
+		bank[ii] = NewSparseControlPoints(baseline, dense[ii], 1e-3)
+		require.LessOrEqual(t, bank[ii].NumExceptions(), 3)
+		dense[ii][ii] -= 1e-6
+		require.Equal(t, dense[ii], bank[ii].Dense())
+	}
+	for x := -0.5; x < 1.5; x += 0.01 {
+		got := b.EvaluateSparseBank(bank, x)
+		want := b.EvaluateBank(dense, x)
+		require.InDeltaSlice(t, want, got, 1e-12, "x=%g", x)
+		require.InDelta(t, want[0], b.EvaluateSparse(bank[0], x), 1e-12, "x=%g", x)
+	}
+	assert.True(t, math.IsNaN(b.EvaluateSparse(bank[0], math.NaN())))
+}
+
+func TestEvaluateInterval(t *testing.T) {
+	rng := rand.New(rand.NewPCG(46, 46))
+	for _, extrapolation := range []ExtrapolationType{ExtrapolateZero, ExtrapolateConstant, ExtrapolateLinear} {
+		b := RandomBSpline(rng, 3, 10, RandomKnots(), RandomExtrapolation(extrapolation))
+		for range 50 {
+			xlo := -0.3 + 1.6*rng.Float64()
+			xhi := xlo + 0.5*rng.Float64()
+			lo, hi := b.EvaluateInterval(xlo, xhi)
+			sampledLo, sampledHi := math.Inf(1), math.Inf(-1)
+			for ii := range 201 {
+				y := b.Evaluate(xlo + (xhi-xlo)*float64(ii)/200)
+				sampledLo, sampledHi = min(sampledLo, y), max(sampledHi, y)
+			}
+			require.LessOrEqual(t, lo, sampledLo+1e-12, "%s, [%g, %g]", extrapolation, xlo, xhi)
+			require.GreaterOrEqual(t, hi, sampledHi-1e-12, "%s, [%g, %g]", extrapolation, xlo, xhi)
+			// The bounds are reasonably tight.
+			require.Less(t, (hi-lo)-(sampledHi-sampledLo), 0.5, "%s, [%g, %g]", extrapolation, xlo, xhi)
+		}
+	}
+
+	// Repeated knots: Hermite and PCHIP.
+	for _, b := range []*BSpline{
+		NewHermite([]float64{0, 1, 2}, []float64{1, -1, 2}, []float64{0, 3, -1}),
+		NewPCHIP([]float64{0, 0.5, 1, 2}, []float64{0, 1, 0.5, 3}).WithExtrapolation(ExtrapolateLinear),
+	} {
+		for _, interval := range [][2]float64{{0.2, 1.7}, {1, 1.5}, {-0.5, 0.7}, {0.9, 2.5}} {
+			lo, hi := b.EvaluateInterval(interval[0], interval[1])
+			for ii := range 201 {
+				y := b.Evaluate(interval[0] + (interval[1]-interval[0])*float64(ii)/200)
+				require.LessOrEqual(t, lo, y+1e-12, "%v", interval)
+				require.GreaterOrEqual(t, hi, y-1e-12, "%v", interval)
+			}
+		}
+	}
+
+	// A single point.
+	b := RandomBSpline(rng, 2, 6)
+	lo, hi := b.EvaluateInterval(0.3, 0.3)
+	assert.Equal(t, b.Evaluate(0.3), lo)
+	assert.Equal(t, b.Evaluate(0.3), hi)
+}
+
+func TestEquivalentCurves(t *testing.T) {
+	rng := rand.New(rand.NewPCG(47, 47))
+	a := RandomBSpline(rng, 2, 7, RandomKnots(), RandomExtrapolation(ExtrapolateLinear))
+	refined := a.Refine(a.DoubledKnots())
+	assert.True(t, EquivalentCurves(a, refined, 1e-9))
+	assert.True(t, EquivalentCurves(refined, a, 1e-9))
+
+	// Degree elevation: same curve with a higher degree.
+	elevated := Add(a, NewRegular(3, 4).WithControlPoints(make([]float64, 4)).WithExtrapolation(ExtrapolateLinear))
+	require.Equal(t, 3, elevated.Degree())
+	assert.True(t, EquivalentCurves(a, elevated, 1e-9))
+
+	// Actual differences.
+	assert.False(t, EquivalentCurves(a, refined.ShiftY(1e-3), 1e-6))
+	assert.True(t, EquivalentCurves(a, refined.ShiftY(1e-3), 1e-2))
+	assert.False(t, EquivalentCurves(a, refined.AffineY(1, 0).WithExtrapolation(ExtrapolateConstant), 1e-9))
+}
+
+func TestCurveWeights(t *testing.T) {
+	// Exact unit circle: 4 quadratic arcs.
+	knots := []float64{0, 0.25, 0.25, 0.5, 0.5, 0.75, 0.75, 1}
+	s := math.Sqrt2 / 2
+	c := NewCurve(
+		New(2, knots).WithClosedDomain(true).WithControlPoints([]float64{1, 1, 0, -1, -1, -1, 0, 1, 1}),
+		New(2, knots).WithClosedDomain(true).WithControlPoints([]float64{0, 1, 1, 1, 0, -1, -1, -1, 0}),
+	).WithWeights([]float64{1, s, 1, s, 1, s, 1, s, 1})
+	for param := 0.0; param <= 1; param += 0.01 {
+		point, tangent := c.Evaluate(param), c.Tangent(param)
+		require.InDelta(t, 1, math.Hypot(point[0], point[1]), 1e-12, "t=%g", param)
+		// The tangent is perpendicular to the radius.
+		require.InDelta(t, 0, point[0]*tangent[0]+point[1]*tangent[1], 1e-9, "t=%g", param)
+	}
+	assert.InDeltaSlice(t, []float64{0, 1}, c.Evaluate(0.25), 1e-12)
+	assert.InDeltaSlice(t, []float64{1, 0}, c.Evaluate(1), 1e-12)
+
+	// Clearance from the origin-centered square of side 3.
+	distance, _ := c.Clearance(PolygonSegments([][2]float64{{-1.5, -1.5}, {1.5, -1.5}, {1.5, 1.5}, {-1.5, 1.5}}), 100)
+	assert.InDelta(t, 0.5, distance, 1e-6)
+
+	// Without weights it's a polynomial curve, not a circle.
+	c.WithWeights(nil)
+	point := c.Evaluate(0.125)
+	assert.Greater(t, math.Abs(math.Hypot(point[0], point[1])-1), 1e-3)
+	assert.Panics(t, func() { c.WithWeights([]float64{1, 0, 1, 1, 1, 1, 1, 1, 1}) })
+
+	// Extrapolation of the rational curve, with an open domain: from the value and tangent at the ends.
+	weights := []float64{1, s, 1, s, 1, s, 1, s, 1}
+	open := func(extrapolation ExtrapolationType) *Curve {
+		return NewCurve(
+			New(2, knots).WithExtrapolation(extrapolation).WithControlPoints([]float64{1, 1, 0, -1, -1, -1, 0, 1, 1}),
+			New(2, knots).WithExtrapolation(extrapolation).WithControlPoints([]float64{0, 1, 1, 1, 0, -1, -1, -1, 0}),
+		).WithWeights(weights)
+	}
+	c = open(ExtrapolateZero)
+	assert.Equal(t, []float64{0, 0}, c.Evaluate(1))
+	assert.Equal(t, []float64{0, 0}, c.Evaluate(-0.5))
+	assert.Equal(t, []float64{0, 0}, c.Tangent(1))
+	c = open(ExtrapolateConstant)
+	assert.InDeltaSlice(t, []float64{1, 0}, c.Evaluate(1), 1e-12)
+	assert.InDeltaSlice(t, []float64{1, 0}, c.Evaluate(-3), 1e-12)
+	assert.Equal(t, []float64{0, 0}, c.Tangent(2))
+	c = open(ExtrapolateLinear)
+	endTangent := c.Tangent(1)
+	assert.InDelta(t, 0, endTangent[0], 1e-9)
+	assert.Greater(t, endTangent[1], 0.0)
+	for _, param := range []float64{1, 1.5, 10} {
+		point := c.Evaluate(param)
+		assert.InDeltaSlice(t, []float64{1, (param - 1) * endTangent[1]}, point, 1e-9, "t=%g", param)
+		assert.InDeltaSlice(t, endTangent, c.Tangent(param), 1e-12, "t=%g", param)
+	}
+	startTangent := c.Tangent(0)
+	assert.InDeltaSlice(t, []float64{1, -2 * startTangent[1]}, c.Evaluate(-2), 1e-9)
+	assert.False(t, math.IsNaN(c.Evaluate(-100)[0]))
+}
+
+func TestLiteral(t *testing.T) {
+	rng := rand.New(rand.NewPCG(48, 48))
+	for _, b := range []*BSpline{
+		RandomBSpline(rng, 3, 8, RandomKnots(), RandomExtrapolation(ExtrapolateLinear)),
+		New(2, []float64{0, 0.5, 0.5, 1}).WithControlPoints([]float64{0, 1e-300, -3.25, 1e10, 0.1}),
+		New(1, []float64{-1, 1}),
+	} {
+		literal := b.FormatLiteral()
+		require.NotContains(t, literal, " ")
+		parsed, err := ParseLiteral(literal)
+		require.NoError(t, err, literal)
+		assert.Equal(t, b.ExpandedKnots(), parsed.ExpandedKnots())
+		assert.Equal(t, b.ControlPoints(), parsed.ControlPoints())
+		assert.Equal(t, b.Extrapolation(), parsed.Extrapolation())
+	}
+	assert.Equal(t, "2;0,0.5,1;0,1,0.5,2;ExtrapolateLinear",
+		NewRegular(2, 4).WithControlPoints([]float64{0, 1, 0.5, 2}).WithExtrapolation(ExtrapolateLinear).FormatLiteral())
+
+	// As a flag.
+	var b BSpline
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.TextVar(&b, "calibration", NewRegular(1, 2), "calibration curve")
+	require.NoError(t, flags.Parse([]string{"-calibration= 1 ; 0, 2 ; 1, 5 ; ExtrapolateConstant"}))
+	assert.Equal(t, 3.0, b.Evaluate(1))
+
+	for _, literal := range []string{"", "1;0,1;1,2", "x;0,1;;ExtrapolateZero", "1;0,a;;ExtrapolateZero", "1;0,1;1,2;Other", "1;1,0;;ExtrapolateZero", "1;0,1;1;ExtrapolateZero"} {
+		_, err := ParseLiteral(literal)
+		assert.Error(t, err, literal)
+	}
+	_, err := ParseLiteral("1;1,0;;ExtrapolateZero")
+	assert.ErrorIs(t, err, ErrKnotsNotSorted)
+
+	// Closed domain.
+	closed := NewRegular(1, 2).WithControlPoints([]float64{1, 3}).WithExtrapolation(ExtrapolateZero).WithClosedDomain(true)
+	literal := closed.FormatLiteral()
+	assert.Equal(t, "1;0,1;1,3;ExtrapolateZero;closed", literal)
+	parsed, err := ParseLiteral(literal)
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, parsed.Evaluate(1))
+	_, err = ParseLiteral("1;0,1;1,3;ExtrapolateZero;open")
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+
+	// Unclamped B-splines can't be encoded as literals, only as JSON, which is the same used by Pipeline.
+	periodic := NewPeriodic(3, []float64{0, 1, 2, 3, 4}).WithControlPoints([]float64{1, 3, 2, 5})
+	assert.Panics(t, func() { periodic.BSpline().FormatLiteral() })
+	_, err = periodic.BSpline().MarshalText()
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+	data, err := json.Marshal(periodic.BSpline())
+	require.NoError(t, err)
+	var decoded BSpline
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, periodic.BSpline().ExpandedKnots(), decoded.ExpandedKnots())
+	assert.Equal(t, periodic.Evaluate(0.5), decoded.Evaluate(0.5))
+	pipelineData, err := json.Marshal(NewPipeline(periodic.BSpline()))
+	require.NoError(t, err)
+	assert.Contains(t, string(pipelineData), string(data))
+}
+
+func TestControlPointsND(t *testing.T) {
+	rng := rand.New(rand.NewPCG(49, 49))
+	b := RandomBSpline(rng, 3, 7, RandomKnots(), RandomExtrapolation(ExtrapolateLinear))
+	points := make([][]float64, b.NumControlPoints())
+	coordinates := make([]*BSpline, 3)
+	for d := range coordinates {
+		coordinates[d] = New(3, b.Knots()).WithExtrapolation(ExtrapolateLinear).
+			WithControlPoints(RandomBSpline(rng, 3, 7).ControlPoints())
+	}
+	for ii := range points {
+		points[ii] = []float64{coordinates[0].ControlPoints()[ii], coordinates[1].ControlPoints()[ii], coordinates[2].ControlPoints()[ii]}
+	}
+	b.WithControlPointsND(points)
+	curve := b.Curve()
+	for x := -0.5; x < 1.5; x += 0.01 {
+		point := b.EvaluateND(x)
+		require.Len(t, point, 3)
+		for d, coordinate := range coordinates {
+			require.InDelta(t, coordinate.Evaluate(x), point[d], 1e-12, "x=%g, d=%d", x, d)
+		}
+		require.InDeltaSlice(t, point, curve.Evaluate(x), 1e-12, "x=%g", x)
+	}
+	assert.True(t, math.IsNaN(b.EvaluateND(math.NaN())[1]))
+
+	// The curve is backed by b, and derivatives and refinements carry the vector-valued control points along.
+	assert.Same(t, b, curve.BSpline())
+	derivative, refined := b.Derivative(), b.Refine(b.DoubledKnots())
+	for x := -0.5; x < 1.5; x += 0.01 {
+		point, tangent := b.EvaluateNDWithGradient(x)
+		require.InDeltaSlice(t, tangent, curve.Tangent(x), 1e-12, "x=%g", x)
+		require.InDeltaSlice(t, tangent, derivative.EvaluateND(x), 1e-9, "x=%g", x)
+		require.InDeltaSlice(t, point, refined.EvaluateND(x), 1e-9, "x=%g", x)
+		for d, coordinate := range coordinates {
+			_, slope := coordinate.EvaluateWithGradient(x)
+			require.InDelta(t, slope, tangent[d], 1e-9, "x=%g, d=%d", x, d)
+			require.InDelta(t, coordinate.Evaluate(x), curve.Coordinate(d).Evaluate(x), 1e-12, "x=%g, d=%d", x, d)
+		}
+	}
+	segment := NewRegular(1, 2).WithControlPointsND([][]float64{{0, 0}, {1, 2}}).Derivative()
+	assert.Nil(t, segment.ControlPoints())
+	assert.Equal(t, []float64{1, 2}, segment.EvaluateND(0.3))
+	assert.Panics(t, func() { b.WithControlPointsND(points[1:]) })
+	assert.Panics(t, func() { NewRegular(1, 2).WithControlPointsND([][]float64{{0, 1}, {2}}) })
+	assert.Panics(t, func() { NewRegular(1, 2).EvaluateND(0.5) })
+}
+
+func TestMap(t *testing.T) {
+	rng := rand.New(rand.NewPCG(50, 50))
+	b := RandomBSpline(rng, 3, 10, RandomKnots(), RandomExtrapolation(ExtrapolateLinear))
+	column := []float64{-0.5, 0.1, 0.7, 0.3, 1.2, math.NaN()}
+	want := b.EvaluateBatch(column)
+
+	calibrated := b.Map(nil, column)
+	assert.Equal(t, want[:5], calibrated[:5])
+	assert.True(t, math.IsNaN(calibrated[5]))
+
+	// Reuses dst.
+	dst := make([]float64, 2, 10)
+	got := b.Map(dst, column)
+	assert.Equal(t, &dst[:1][0], &got[0])
+	assert.Equal(t, want[:5], got[:5])
+
+	// In-place.
+	got = b.Map(column, column)
+	assert.Equal(t, &column[0], &got[0])
+	assert.Equal(t, want[:5], column[:5])
+}
+
+func TestSurface(t *testing.T) {
+	rng := rand.New(rand.NewPCG(51, 51))
+	bu := RandomBSpline(rng, 3, 6, RandomKnots(), RandomExtrapolation(ExtrapolateLinear))
+	bv := RandomBSpline(rng, 2, 5, RandomExtrapolation(ExtrapolateLinear))
+	grid := make([][]float64, bu.NumControlPoints())
+	for ii := range grid {
+		grid[ii] = RandomBSpline(rng, 2, 5).ControlPoints()
+	}
+	s := NewSurface(bu, bv, grid)
+	for u := -0.3; u < 1.3; u += 0.07 {
+		// The surface at fixed u is a B-spline in v, with control points given by the B-splines in u of each column.
+		column := make([]float64, len(grid))
+		rowControl := make([]float64, bv.NumControlPoints())
+		for jj := range rowControl {
+			for ii := range grid {
+				column[ii] = grid[ii][jj]
+			}
+			rowControl[jj] = bu.WithControlPoints(column).Evaluate(u)
+		}
+		atU := New(bv.Degree(), bv.Knots()).WithExtrapolation(ExtrapolateLinear).WithControlPoints(rowControl)
+		for v := -0.3; v < 1.3; v += 0.07 {
+			value, du, dv := s.EvaluateWithGradient(u, v)
+			require.InDelta(t, atU.Evaluate(v), value, 1e-12, "u=%g, v=%g", u, v)
+			_, wantDV := atU.EvaluateWithGradient(v)
+			require.InDelta(t, wantDV, dv, 1e-9, "u=%g, v=%g", u, v)
+			const eps = 1e-6
+			require.InDelta(t, (s.Evaluate(u+eps, v)-s.Evaluate(u-eps, v))/(2*eps), du, 1e-4, "u=%g, v=%g", u, v)
+		}
+	}
+	assert.Panics(t, func() { NewSurface(bu, bv, grid[1:]) })
+}
+
+func TestFitWithReport(t *testing.T) {
+	rng := rand.New(rand.NewPCG(52, 52))
+	b := NewRegular(3, 8)
+	var xs, ys []float64
+	for range 500 {
+		x := rng.Float64()
+		xs = append(xs, x)
+		ys = append(ys, math.Sin(6*x)+0.01*rng.NormFloat64())
+	}
+	fitted, report := b.FitWithReport(xs, ys)
+	assert.Nil(t, b.ControlPoints())
+	assert.InDeltaSlice(t, b.FitControlPoints(xs, ys), fitted.ControlPoints(), 1e-12)
+	assert.Equal(t, 500, report.NumPoints)
+	assert.InDelta(t, 8.0, report.EffectiveDegreesOfFreedom, 1e-9)
+	assert.InDelta(t, 0.01, report.RMSE, 5e-3)
+	assert.Greater(t, report.MaxError, report.RMSE)
+	require.Len(t, report.Spans, 5)
+	var numPoints int
+	for _, span := range report.Spans {
+		numPoints += span.NumPoints
+		assert.LessOrEqual(t, span.MaxError, report.MaxError)
+		assert.Greater(t, span.RMSE, 0.0)
+	}
+	assert.Equal(t, 500, numPoints)
+	assert.Contains(t, report.String(), "500 points")
+
+	// Points outside the domain (or NaN) are ignored by the fit, and reported separately, and the end of the domain
+	// is evaluated as in the fit, also with ExtrapolateZero.
+	exact := NewRegular(2, 4).WithExtrapolation(ExtrapolateZero)
+	exactXs := []float64{0, 0.2, 0.4, 0.6, 0.8, 1, -1, 2, math.NaN()}
+	exactYs := []float64{0, 0.04, 0.16, 0.36, 0.64, 1, 100, 100, 100}
+	_, exactReport := exact.FitWithReport(exactXs, exactYs)
+	assert.Equal(t, 6, exactReport.NumPoints)
+	assert.Equal(t, 3, exactReport.NumIgnored)
+	assert.Less(t, exactReport.MaxError, 1e-9)
+	assert.Less(t, exactReport.RMSE, 1e-9)
+
+	// Penalized fits: lambda=0 is the plain fit, and larger lambdas have fewer effective degrees of freedom, down to
+	// the null space of the penalty (a line, for order 2).
+	smooth, smoothReport := b.FitPenalizedWithReport(xs, ys, 2, 0)
+	assert.InDeltaSlice(t, fitted.ControlPoints(), smooth.ControlPoints(), 1e-9)
+	assert.InDelta(t, 8.0, smoothReport.EffectiveDegreesOfFreedom, 1e-9)
+	previous := smoothReport
+	for _, lambda := range []float64{1e-4, 1e-2, 1, 1e6} {
+		_, smoothReport = b.FitPenalizedWithReport(xs, ys, 2, lambda)
+		assert.Less(t, smoothReport.EffectiveDegreesOfFreedom, previous.EffectiveDegreesOfFreedom)
+		assert.GreaterOrEqual(t, smoothReport.RMSE, previous.RMSE)
+		previous = smoothReport
+	}
+	assert.InDelta(t, 2.0, previous.EffectiveDegreesOfFreedom, 1e-3)
+	require.Panics(t, func() { b.FitPenalizedWithReport(xs, ys, 2, -1) })
+	require.Panics(t, func() { b.FitPenalizedWithReport(xs, ys, 4, 1) })
+
+	// Periodic fits, with data over several periods.
+	p := NewPeriodic(3, []float64{0, 0.25, 0.5, 0.75, 1})
+	xs, ys = xs[:0], ys[:0]
+	for range 500 {
+		x := 3 * rng.Float64()
+		xs = append(xs, x)
+		ys = append(ys, math.Sin(2*math.Pi*x)+0.01*rng.NormFloat64())
+	}
+	periodic, periodicReport := p.FitWithReport(xs, ys)
+	assert.Nil(t, p.ControlPoints())
+	assert.InDeltaSlice(t, p.FitControlPoints(xs, ys), periodic.ControlPoints(), 1e-12)
+	assert.Equal(t, 500, periodicReport.NumPoints)
+	assert.InDelta(t, 4.0, periodicReport.EffectiveDegreesOfFreedom, 1e-9)
+	require.Len(t, periodicReport.Spans, 4)
+	numPoints = 0
+	for _, span := range periodicReport.Spans {
+		numPoints += span.NumPoints
+	}
+	assert.Equal(t, 500, numPoints)
+	assert.Equal(t, 0.0, periodicReport.Spans[0].Start)
+	assert.Equal(t, 1.0, periodicReport.Spans[3].End)
+}
+
+func TestArcLength(t *testing.T) {
+	// Graph of f(x) = x², with known length over [0, 1].
+	b := NewRegular(2, 5)
+	b = b.WithControlPoints(b.QuasiInterpolate(func(x float64) float64 { return x * x }))
+	length, errorEstimate := b.ArcLength(0, 1)
+	assert.InDelta(t, (2*math.Sqrt(5)+math.Asinh(2))/4, length, 1e-12)
+	assert.Less(t, errorEstimate, 1e-9)
+	length, _ = b.ArcLength(0.3, 0.3)
+	assert.Equal(t, 0.0, length)
+	require.Panics(t, func() { b.ArcLength(1, 0) })
+
+	// Quarter of the unit circle as a NURBS curve, and a straight line.
+	circle := NewCurve(
+		New(2, []float64{0, 1}).WithControlPoints([]float64{1, 1, 0}),
+		New(2, []float64{0, 1}).WithControlPoints([]float64{0, 1, 1}),
+	).WithWeights([]float64{1, math.Sqrt2 / 2, 1})
+	length, errorEstimate = circle.ArcLength(0, 1)
+	assert.InDelta(t, math.Pi/2, length, 1e-12)
+	assert.Less(t, errorEstimate, 1e-9)
+	line := InterpolateCurve([][]float64{{0, 0}, {3, 4}}, nil)
+	length, _ = line.ArcLength(0, 1)
+	assert.InDelta(t, 5.0, length, 1e-12)
+}
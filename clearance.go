@@ -0,0 +1,133 @@
+package bsplines
+
+import (
+	"math"
+)
+
+// Segment is a line segment in 2D, from A to B, used as an obstacle by Curve.Clearance.
+type Segment struct {
+	A, B [2]float64
+}
+
+// PolygonSegments returns the segments of the edges of the closed polygon with the given vertices.
+func PolygonSegments(vertices [][2]float64) []Segment {
+	segments := make([]Segment, len(vertices))
+	for ii, vertex := range vertices {
+		segments[ii] = Segment{A: vertex, B: vertices[(ii+1)%len(vertices)]}
+	}
+	return segments
+}
+
+// clearanceRefineIterations is the number of golden-section iterations used by Curve.Clearance to refine the
+// parameter of the closest point.
+const clearanceRefineIterations = 60
+
+// Clearance returns the minimum distance between the 2D curve, over its domain, and the obstacles (see
+// PolygonSegments to use polygons), and the parameter t of the closest point of the curve -- e.g. to validate
+// that a planned trajectory keeps a safety margin.
+//
+// The curve is approximated by a polyline of numSamples points, which selects the closest piece of the curve
+// (detecting crossings too), and the parameter is then refined with a golden-section search on the actual curve.
+// So numSamples must be large enough to resolve the features of the curve (it must be at least 2).
+//
+// It panics if the curve is not 2D or there are no obstacles.
+func (c *Curve) Clearance(obstacles []Segment, numSamples int) (distance, t float64) {
+	if c.Dim() != 2 {
+		panicf(ErrInvalidArgument, "Curve.Clearance() requires a 2D curve, got %d dimensions", c.Dim())
+	}
+	if len(obstacles) == 0 {
+		panicf(ErrInvalidArgument, "Curve.Clearance() requires at least one obstacle")
+	}
+	if numSamples < 2 {
+		panicf(ErrInvalidArgument, "Curve.Clearance(numSamples=%d) requires numSamples >= 2", numSamples)
+	}
+	domainMin, domainMax := c.Domain()
+	params := make([]float64, numSamples)
+	points := make([][2]float64, numSamples)
+	for ii := range params {
+		params[ii] = domainMin + (domainMax-domainMin)*float64(ii)/float64(numSamples-1)
+		if ii == numSamples-1 {
+			// The last knot itself is extrapolated (unless the domain is closed), take the value just before it.
+			params[ii] = math.Nextafter(domainMax, domainMin)
+		}
+		points[ii] = c.point2D(params[ii])
+	}
+
+	// Closest piece of the polyline.
+	closest, closestDistance := 0, math.Inf(1)
+	for ii := range numSamples - 1 {
+		piece := Segment{A: points[ii], B: points[ii+1]}
+		for _, obstacle := range obstacles {
+			if d := segmentsDistance(piece, obstacle); d < closestDistance {
+				closest, closestDistance = ii, d
+			}
+		}
+	}
+
+	// Refine on the neighbouring pieces, on the actual curve.
+	distanceAt := func(t float64) float64 {
+		point := c.point2D(t)
+		d := math.Inf(1)
+		for _, obstacle := range obstacles {
+			d = min(d, pointSegmentDistance(point, obstacle))
+		}
+		return d
+	}
+	low, high := params[max(closest-1, 0)], params[min(closest+2, numSamples-1)]
+	invPhi := (math.Sqrt(5) - 1) / 2
+	x1, x2 := high-invPhi*(high-low), low+invPhi*(high-low)
+	d1, d2 := distanceAt(x1), distanceAt(x2)
+	for range clearanceRefineIterations {
+		if d1 <= d2 {
+			high, x2, d2 = x2, x1, d1
+			x1 = high - invPhi*(high-low)
+			d1 = distanceAt(x1)
+		} else {
+			low, x1, d1 = x1, x2, d2
+			x2 = low + invPhi*(high-low)
+			d2 = distanceAt(x2)
+		}
+	}
+	distance, t = d1, x1
+	if d2 < d1 {
+		distance, t = d2, x2
+	}
+
+	// The sampled points are also candidates, in case the refinement converged to a local minimum.
+	for _, param := range params {
+		if d := distanceAt(param); d < distance {
+			distance, t = d, param
+		}
+	}
+	return
+}
+
+// point2D evaluates the 2D curve at t.
+func (c *Curve) point2D(t float64) [2]float64 {
+	point := c.Evaluate(t)
+	return [2]float64{point[0], point[1]}
+}
+
+// pointSegmentDistance returns the distance from the point p to the segment s.
+func pointSegmentDistance(p [2]float64, s Segment) float64 {
+	dx, dy := s.B[0]-s.A[0], s.B[1]-s.A[1]
+	var u float64
+	if lengthSquared := dx*dx + dy*dy; lengthSquared > 0 {
+		u = min(max(((p[0]-s.A[0])*dx+(p[1]-s.A[1])*dy)/lengthSquared, 0), 1)
+	}
+	return math.Hypot(p[0]-(s.A[0]+u*dx), p[1]-(s.A[1]+u*dy))
+}
+
+// segmentsDistance returns the distance between the segments s1 and s2, 0 if they intersect.
+func segmentsDistance(s1, s2 Segment) float64 {
+	orientation := func(a, b, c [2]float64) float64 {
+		return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+	}
+	o1, o2 := orientation(s1.A, s1.B, s2.A), orientation(s1.A, s1.B, s2.B)
+	o3, o4 := orientation(s2.A, s2.B, s1.A), orientation(s2.A, s2.B, s1.B)
+	if ((o1 > 0 && o2 < 0) || (o1 < 0 && o2 > 0)) && ((o3 > 0 && o4 < 0) || (o3 < 0 && o4 > 0)) {
+		return 0
+	}
+	return min(pointSegmentDistance(s1.A, s2), pointSegmentDistance(s1.B, s2),
+		pointSegmentDistance(s2.A, s1), pointSegmentDistance(s2.B, s1))
+}
@@ -0,0 +1,109 @@
+package bsplines
+
+import (
+	"encoding/json"
+	"math"
+	"sync/atomic"
+)
+
+// Coverage wraps a B-spline evaluation to record where the evaluated x values fall relative to its knots: the
+// count per knot span, and how many were extrapolated below or above the domain, or were NaN. It's meant to be
+// left on in production, to detect calibration drift -- when the inputs move away from the data the B-spline was
+// fitted on.
+//
+// It's safe for concurrent use, and it implements expvar.Var (see String), so it can be published directly with
+// expvar.Publish. For other monitoring systems (e.g. Prometheus), export the values returned by Stats.
+type Coverage struct {
+	bspline            *BSpline
+	below, above, nans atomic.Int64
+	spans              []atomic.Int64
+}
+
+// CoverageStats is a snapshot of the counts recorded by Coverage.
+type CoverageStats struct {
+	// Total number of recorded values.
+	Total int64 `json:"total"`
+
+	// Below and Above are the number of values extrapolated below and above the domain of the B-spline.
+	Below int64 `json:"below"`
+	Above int64 `json:"above"`
+
+	// NaN is the number of NaN values.
+	NaN int64 `json:"nan"`
+
+	// SpanCounts holds the number of values in each knot span: SpanCounts[i] is the number of values between
+	// `Knots()[i]` and `Knots()[i+1]`.
+	SpanCounts []int64 `json:"span_counts"`
+}
+
+// ExtrapolatedFraction returns the fraction of the recorded values that were outside the domain (including NaNs),
+// or 0 if there are no recorded values.
+func (s CoverageStats) ExtrapolatedFraction() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Below+s.Above+s.NaN) / float64(s.Total)
+}
+
+// NewCoverage creates a Coverage for the B-spline, with all counts set to 0.
+func (b *BSpline) NewCoverage() *Coverage {
+	return &Coverage{
+		bspline: b,
+		spans:   make([]atomic.Int64, b.NumControlPoints()-b.degree),
+	}
+}
+
+// Record x without evaluating the B-spline.
+func (c *Coverage) Record(x float64) {
+	b := c.bspline
+	domainMin, _ := b.Domain()
+	switch {
+	case math.IsNaN(x):
+		c.nans.Add(1)
+	case b.inDomain(x):
+		c.spans[b.SpanIndex(x)-b.degree].Add(1)
+	case x < domainMin:
+		c.below.Add(1)
+	default:
+		c.above.Add(1)
+	}
+}
+
+// Evaluate records x and returns the B-spline evaluated at x, see BSpline.Evaluate.
+func (c *Coverage) Evaluate(x float64) float64 {
+	c.Record(x)
+	return c.bspline.Evaluate(x)
+}
+
+// Stats returns a snapshot of the recorded counts.
+// The counts are read one at a time, so they may be slightly inconsistent with concurrent Record calls.
+func (c *Coverage) Stats() CoverageStats {
+	stats := CoverageStats{
+		Below:      c.below.Load(),
+		Above:      c.above.Load(),
+		NaN:        c.nans.Load(),
+		SpanCounts: make([]int64, len(c.spans)),
+	}
+	stats.Total = stats.Below + stats.Above + stats.NaN
+	for ii := range c.spans {
+		stats.SpanCounts[ii] = c.spans[ii].Load()
+		stats.Total += stats.SpanCounts[ii]
+	}
+	return stats
+}
+
+// Reset all counts to 0.
+func (c *Coverage) Reset() {
+	c.below.Store(0)
+	c.above.Store(0)
+	c.nans.Store(0)
+	for ii := range c.spans {
+		c.spans[ii].Store(0)
+	}
+}
+
+// String returns the Stats as JSON, implementing expvar.Var.
+func (c *Coverage) String() string {
+	encoded, _ := json.Marshal(c.Stats())
+	return string(encoded)
+}
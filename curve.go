@@ -0,0 +1,301 @@
+package bsplines
+
+import (
+	"math"
+	"slices"
+)
+
+// Curve is a parametric curve in any number of dimensions: a B-spline with vector-valued control points (see
+// BSpline.WithControlPointsND), with one value per coordinate, evaluated at the parameter t.
+//
+// Optionally, it can be a NURBS (rational B-spline) curve, with a weight per control point, see WithWeights.
+type Curve struct {
+	// spline holds the knots, degree, configuration and the vector-valued control points of the curve.
+	spline *BSpline
+
+	// weights of the control points, if set with WithWeights. Then homogeneous holds the B-spline with the control
+	// points multiplied by the weights, and weight the B-spline with the weights as control points: the curve is
+	// `homogeneous(t) / weight(t)` in the domain. Both have a closed domain, so they can be evaluated at its ends, and
+	// they are never extrapolated, see rationalExtrapolation.
+	weights     []float64
+	homogeneous *BSpline
+	weight      *BSpline
+}
+
+// NewCurve creates a Curve from the B-splines of each coordinate, which must have the same degree, expanded knots,
+// extrapolation and domain (see BSpline.WithClosedDomain), and their control points set. See also BSpline.Curve.
+func NewCurve(coordinates ...*BSpline) *Curve {
+	if len(coordinates) == 0 {
+		panicf(ErrInvalidArgument, "bsplines.NewCurve requires at least one coordinate")
+	}
+	first := coordinates[0]
+	for ii, b := range coordinates {
+		if len(b.controlPoints) == 0 {
+			panicf(ErrControlPointsNotSet, "bsplines.NewCurve requires the control points of all coordinates to be set, coordinate #%d doesn't have them", ii)
+		}
+		if b.degree != first.degree || !slices.Equal(b.expandedKnots, first.expandedKnots) ||
+			b.extrapolation != first.extrapolation || b.closedDomain != first.closedDomain {
+			panicf(ErrIncompatibleSplines, "bsplines.NewCurve requires coordinates with the same degree, knots, extrapolation and domain, coordinate #%d differs from coordinate #0", ii)
+		}
+	}
+	points := make([][]float64, first.NumControlPoints())
+	for ii := range points {
+		points[ii] = make([]float64, len(coordinates))
+		for d, b := range coordinates {
+			points[ii][d] = b.controlPoints[ii]
+		}
+	}
+	spline := newFromExpandedKnots(first.degree, first.expandedKnots).WithExtrapolation(first.extrapolation).
+		WithClosedDomain(first.closedDomain).WithControlPointsND(points)
+	return spline.Curve()
+}
+
+// BSpline returns the B-spline with the vector-valued control points of the curve (without the weights).
+// It must not be changed.
+func (c *Curve) BSpline() *BSpline {
+	return c.spline
+}
+
+// Coordinate returns a new B-spline with the values of the coordinate d of the curve (without the weights).
+func (c *Curve) Coordinate(d int) *BSpline {
+	if d < 0 || d >= c.Dim() {
+		panicf(ErrInvalidArgument, "Curve.Coordinate(%d) requires a coordinate from 0 to %d", d, c.Dim()-1)
+	}
+	b := c.spline
+	return newFromExpandedKnots(b.degree, b.expandedKnots).WithExtrapolation(b.extrapolation).
+		WithClosedDomain(b.closedDomain).WithControlPoints(b.coordinateControlPoints(d))
+}
+
+// Dim returns the number of dimensions (coordinates) of the curve.
+func (c *Curve) Dim() int {
+	return len(c.spline.controlPointsND[0])
+}
+
+// Domain returns the interval of the parameter t where the curve is defined, see BSpline.Domain.
+func (c *Curve) Domain() (min, max float64) {
+	return c.spline.Domain()
+}
+
+// WithWeights turns the curve into a NURBS (Non-Uniform Rational B-Spline) curve, with one positive weight per
+// control point: the curve is then `Σ w_i P_i B_i(t) / Σ w_i B_i(t)`, where P_i are the control points (one value
+// per coordinate) and B_i the basis functions. NURBS represent conics -- like circles -- exactly, and they are the
+// format of most CAD curves. Pass nil to remove the weights.
+//
+// Outside the domain, the rational curve is extrapolated from its value and tangent at the nearest end of the domain,
+// as configured in the B-spline: ExtrapolateZero returns the origin, ExtrapolateConstant the end point, and
+// ExtrapolateLinear continues along the end tangent. So the weights are never extrapolated, and they can't cross zero.
+//
+// The weights are not copied. The curve must not be changed after the weights are set.
+// It returns itself, so configuration calls can be cascaded.
+func (c *Curve) WithWeights(weights []float64) *Curve {
+	if weights == nil {
+		c.weights, c.homogeneous, c.weight = nil, nil, nil
+		return c
+	}
+	b := c.spline
+	if len(weights) != b.NumControlPoints() {
+		panicf(ErrControlPointCount, "Curve.WithWeights() requires one weight per control point, got %d weights for %d control points",
+			len(weights), b.NumControlPoints())
+	}
+	for ii, w := range weights {
+		if !(w > 0) {
+			panicf(ErrInvalidArgument, "Curve.WithWeights() requires positive weights, got weights[%d]=%g", ii, w)
+		}
+	}
+	c.weights = weights
+	c.weight = newFromExpandedKnots(b.degree, b.expandedKnots).WithClosedDomain(true).WithControlPoints(weights)
+	homogeneous := make([][]float64, len(weights))
+	for ii, w := range weights {
+		homogeneous[ii] = make([]float64, c.Dim())
+		for d, value := range b.controlPointsND[ii] {
+			homogeneous[ii][d] = w * value
+		}
+	}
+	c.homogeneous = newFromExpandedKnots(b.degree, b.expandedKnots).WithClosedDomain(true).WithControlPointsND(homogeneous)
+	return c
+}
+
+// Weights returns the weights set with WithWeights, or nil if the curve is not rational.
+func (c *Curve) Weights() []float64 {
+	return c.weights
+}
+
+// Evaluate returns the point of the curve at the parameter t.
+func (c *Curve) Evaluate(t float64) []float64 {
+	if c.weights == nil {
+		return c.spline.EvaluateND(t)
+	}
+	if !math.IsNaN(t) && !c.spline.inDomain(t) {
+		point, _ := c.rationalExtrapolation(t)
+		return point
+	}
+	return c.rationalPoint(t)
+}
+
+// Tangent returns the derivative of the curve with respect to the parameter t: its direction is the direction of
+// the curve at t, and its norm is the speed of the parametrization.
+func (c *Curve) Tangent(t float64) []float64 {
+	if c.weights == nil {
+		_, tangent := c.spline.EvaluateNDWithGradient(t)
+		return tangent
+	}
+	if !math.IsNaN(t) && !c.spline.inDomain(t) {
+		_, tangent := c.rationalExtrapolation(t)
+		return tangent
+	}
+	return c.rationalTangent(t)
+}
+
+// rationalPoint returns `homogeneous(t) / weight(t)`.
+func (c *Curve) rationalPoint(t float64) []float64 {
+	point := c.homogeneous.EvaluateND(t)
+	w := c.weight.Evaluate(t)
+	for d := range point {
+		point[d] /= w
+	}
+	return point
+}
+
+// rationalTangent returns the derivative of `homogeneous(t) / weight(t)`, with the quotient rule.
+func (c *Curve) rationalTangent(t float64) []float64 {
+	value, tangent := c.homogeneous.EvaluateNDWithGradient(t)
+	w, dw := c.weight.EvaluateWithGradient(t)
+	for d := range tangent {
+		tangent[d] = (tangent[d]*w - value[d]*dw) / (w * w)
+	}
+	return tangent
+}
+
+// rationalExtrapolation returns the point and tangent of a rational curve at t outside the domain, extrapolated from
+// the nearest end of the domain as configured in the B-spline (see WithWeights).
+func (c *Curve) rationalExtrapolation(t float64) (point, tangent []float64) {
+	end, domainMax := c.spline.Domain()
+	if t > end {
+		end = domainMax
+	}
+	switch c.spline.extrapolation {
+	case ExtrapolateConstant:
+		return c.rationalPoint(end), make([]float64, c.Dim())
+	case ExtrapolateLinear:
+		point, tangent = c.rationalPoint(end), c.rationalTangent(end)
+		for d := range point {
+			point[d] += (t - end) * tangent[d]
+		}
+		return point, tangent
+	}
+	return make([]float64, c.Dim()), make([]float64, c.Dim())
+}
+
+// ChordLengthParams returns the parameter for each point proportional to the accumulated length of the polyline
+// through the points, normalized to the interval [0, 1]. It's the parametrization used by InterpolateCurve.
+//
+// Consecutive points must be distinct.
+func ChordLengthParams(points [][]float64) []float64 {
+	if len(points) < 2 {
+		panicf(ErrInvalidArgument, "bsplines.ChordLengthParams requires at least 2 points, got %d", len(points))
+	}
+	params := make([]float64, len(points))
+	for ii := 1; ii < len(points); ii++ {
+		distance := euclideanDistance(points[ii-1], points[ii])
+		if distance == 0 {
+			panicf(ErrInvalidArgument, "bsplines.ChordLengthParams requires distinct consecutive points, points #%d and #%d are equal", ii-1, ii)
+		}
+		params[ii] = params[ii-1] + distance
+	}
+	total := at(params, -1)
+	for ii := range params {
+		params[ii] /= total
+	}
+	params[len(params)-1] = 1
+	return params
+}
+
+// euclideanDistance between points a and b, which must have the same dimension.
+func euclideanDistance(a, b []float64) float64 {
+	if len(a) != len(b) {
+		panicf(ErrInvalidArgument, "bsplines: points must have the same dimension, got %d and %d", len(a), len(b))
+	}
+	var sum float64
+	for ii := range a {
+		sum += (a[ii] - b[ii]) * (a[ii] - b[ii])
+	}
+	return math.Sqrt(sum)
+}
+
+// InterpolateCurve returns the cubic (or lower degree, if there are fewer than 4 constraints) Curve that passes
+// through the points, at the parameters given by ChordLengthParams, e.g. to build a smooth path through waypoints.
+//
+// Optionally, tangent directions can be prescribed: tangents can be nil, or have one entry per point, with nil for
+// the points without a prescribed tangent. Only the direction of the tangents is used: they are normalized and
+// scaled by the total chord length, so the speed of the curve is roughly uniform.
+//
+// The knots are selected automatically, by averaging the parameters of the constraints (with the parameter of a
+// point counted twice if it has a tangent), as in "The NURBS Book", by Piegl and Tiller, which guarantees a
+// well-posed system. The domain of the curve is [0, 1], closed (see BSpline.WithClosedDomain) so the last point is
+// reached.
+func InterpolateCurve(points, tangents [][]float64) *Curve {
+	if tangents != nil && len(tangents) != len(points) {
+		panicf(ErrInvalidArgument, "bsplines.InterpolateCurve requires one tangent (or nil) per point, got %d tangents for %d points", len(tangents), len(points))
+	}
+	params := ChordLengthParams(points)
+	dim := len(points[0])
+	var totalLength float64
+	for ii := 1; ii < len(points); ii++ {
+		totalLength += euclideanDistance(points[ii-1], points[ii])
+	}
+
+	// Parameters of the constraints (with repeats for the tangents), and the right-hand side for each coordinate.
+	var constraintParams []float64
+	isTangent := make([]bool, 0, 2*len(points))
+	rhs := make([][]float64, dim)
+	for ii, point := range points {
+		constraintParams = append(constraintParams, params[ii])
+		isTangent = append(isTangent, false)
+		for d := range dim {
+			rhs[d] = append(rhs[d], point[d])
+		}
+		if tangents == nil || tangents[ii] == nil {
+			continue
+		}
+		norm := euclideanDistance(tangents[ii], make([]float64, dim))
+		if norm == 0 {
+			panicf(ErrInvalidArgument, "bsplines.InterpolateCurve requires non-zero tangents, tangent #%d is zero", ii)
+		}
+		constraintParams = append(constraintParams, params[ii])
+		isTangent = append(isTangent, true)
+		for d := range dim {
+			rhs[d] = append(rhs[d], tangents[ii][d]/norm*totalLength)
+		}
+	}
+
+	// Knots by averaging.
+	n := len(constraintParams)
+	degree := min(3, n-1)
+	knots := make([]float64, 0, n-degree+1)
+	knots = append(knots, 0)
+	for jj := 1; jj < n-degree; jj++ {
+		var sum float64
+		for _, t := range constraintParams[jj : jj+degree] {
+			sum += t
+		}
+		knots = append(knots, sum/float64(degree))
+	}
+	knots = append(knots, 1)
+	b := New(degree, knots).WithClosedDomain(true)
+
+	plan := NewGridPlan(b, constraintParams, 1)
+	values, derivatives := plan.BasisMatrix(0), plan.BasisMatrix(1)
+	a := make([][]float64, n)
+	for ii := range a {
+		a[ii] = values[ii]
+		if isTangent[ii] {
+			a[ii] = derivatives[ii]
+		}
+	}
+	coordinates := make([]*BSpline, dim)
+	for d := range dim {
+		coordinates[d] = newFromExpandedKnots(degree, b.expandedKnots).WithClosedDomain(true).
+			WithControlPoints(solveLinearSystem(a, rhs[d]))
+	}
+	return NewCurve(coordinates...)
+}
@@ -0,0 +1,38 @@
+package bsplines
+
+// RescaleDomain returns a new B-spline over the domain [newMin, newMax], equivalent to b through the affine map of
+// x from the old domain to the new one: `new(x') = b(x)`, with `x' = newMin + (x - min) * (newMax - newMin) / (max - min)`.
+// E.g. KAN layers usually normalize their inputs to [0, 1], and this maps the learned curve back to the original
+// units of the feature.
+//
+// The degree, control points and extrapolation are kept, and the knots are mapped affinely -- the slope of
+// [ExtrapolateLinear] is rescaled accordingly. newMax must be larger than newMin.
+//
+// The control points must be set.
+func (b *BSpline) RescaleDomain(newMin, newMax float64) *BSpline {
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.RescaleDomain() require control points to be set using BSpline.WithControlPoints()")
+	}
+	if !(newMax > newMin) {
+		panicf(ErrInvalidArgument, "BSpline.RescaleDomain(newMin=%g, newMax=%g) requires newMax > newMin", newMin, newMax)
+	}
+	domainMin, domainMax := b.Domain()
+	scale := (newMax - newMin) / (domainMax - domainMin)
+	expandedKnots := make([]float64, len(b.expandedKnots))
+	for ii, knot := range b.expandedKnots {
+		expandedKnots[ii] = newMin + (knot-domainMin)*scale
+	}
+	// Avoid rounding errors at the ends of the domain.
+	for ii := range b.degree + 1 {
+		if b.expandedKnots[ii] == domainMin {
+			expandedKnots[ii] = newMin
+		}
+		if at(b.expandedKnots, -ii-1) == domainMax {
+			expandedKnots[len(expandedKnots)-ii-1] = newMax
+		}
+	}
+	control := make([]float64, len(b.controlPoints))
+	copy(control, b.controlPoints)
+	return newFromExpandedKnots(b.degree, expandedKnots).WithExtrapolation(b.extrapolation).
+		WithClosedDomain(b.closedDomain).WithControlPoints(control)
+}
@@ -0,0 +1,57 @@
+package bsplines
+
+// Average returns the weighted mean of the splines, e.g. to combine bootstrap replicates or cross-validated fits
+// into a single curve. If weights is nil, all splines have the same weight. Otherwise, there must be one weight per
+// spline, and they are normalized to sum 1.
+//
+// The splines must have the same degree, domain (see WithClosedDomain) and extrapolation, but they can have different knots: the
+// result is defined over the union of all knots, and it's exactly the weighted mean of the splines everywhere,
+// including the extrapolated regions.
+//
+// All splines must have their control points set.
+func Average(splines []*BSpline, weights []float64) *BSpline {
+	if len(splines) == 0 {
+		panicf(ErrInvalidArgument, "bsplines.Average requires at least one spline")
+	}
+	if weights != nil && len(weights) != len(splines) {
+		panicf(ErrInvalidArgument, "bsplines.Average requires one weight per spline, got %d weights for %d splines", len(weights), len(splines))
+	}
+	first := splines[0]
+	domainMin, domainMax := first.Domain()
+	var sumWeights float64
+	for ii, b := range splines {
+		if len(b.controlPoints) == 0 {
+			panicf(ErrControlPointsNotSet, "bsplines.Average requires the control points of all splines to be set, spline #%d doesn't have them", ii)
+		}
+		bMin, bMax := b.Domain()
+		if b.degree != first.degree || bMin != domainMin || bMax != domainMax || b.closedDomain != first.closedDomain ||
+			b.extrapolation != first.extrapolation {
+			panicf(ErrIncompatibleSplines, "bsplines.Average requires splines with the same degree, domain (closed or not) and extrapolation: "+
+				"spline #0 has degree %d, domain [%g, %g] (closed=%v) and %s, but spline #%d has degree %d, domain [%g, %g] (closed=%v) and %s",
+				first.degree, domainMin, domainMax, first.closedDomain, first.extrapolation, ii, b.degree, bMin, bMax, b.closedDomain, b.extrapolation)
+		}
+		if weights == nil {
+			sumWeights++
+		} else {
+			sumWeights += weights[ii]
+		}
+	}
+	if sumWeights == 0 {
+		panicf(ErrInvalidArgument, "bsplines.Average requires weights that don't sum to 0")
+	}
+	knots := CommonRefinement(splines...)
+
+	control := make([]float64, len(knots)+first.degree-1)
+	for ii, b := range splines {
+		weight := 1.0
+		if weights != nil {
+			weight = weights[ii]
+		}
+		weight /= sumWeights
+		for jj, value := range b.RefineControlPoints(knots, b.controlPoints) {
+			control[jj] += weight * value
+		}
+	}
+	return New(first.degree, knots).WithExtrapolation(first.extrapolation).WithClosedDomain(first.closedDomain).
+		WithControlPoints(control)
+}
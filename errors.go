@@ -0,0 +1,49 @@
+package bsplines
+
+import (
+	"errors"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+// Errors wrapped by the panics (and returned errors) of this package for invalid configurations or arguments, so
+// calling code can tell them apart from internal bugs when recovering, e.g.:
+//
+//	err := exceptions.TryCatch[error](func() { b = bsplines.New(degree, knots) })
+//	if errors.Is(err, bsplines.ErrKnotsNotSorted) { ... }
+//
+// Panics not wrapping any of these errors indicate a bug (or a numerical failure) in the package.
+var (
+	// ErrNotEnoughKnots is wrapped when there are not enough knots for the requested operation.
+	ErrNotEnoughKnots = errors.New("not enough knots")
+
+	// ErrKnotsNotSorted is wrapped when the knots are not sorted, or not strictly increasing where repeated knots
+	// are not supported. Knots repeated more than allowed by New wrap ErrInvalidArgument instead.
+	ErrKnotsNotSorted = errors.New("knots not sorted")
+
+	// ErrControlPointCount is wrapped when the number of control points doesn't match the knots and degree.
+	ErrControlPointCount = errors.New("wrong number of control points")
+
+	// ErrControlPointsNotSet is wrapped when an operation requires the control points, but they were not set
+	// with WithControlPoints.
+	ErrControlPointsNotSet = errors.New("control points not set")
+
+	// ErrOutOfDomain is wrapped when a knot span or value is out of the range supported by the operation.
+	ErrOutOfDomain = errors.New("out of domain")
+
+	// ErrIncompatibleSplines is wrapped when B-splines combined in one operation don't share the required
+	// degree, domain or extrapolation.
+	ErrIncompatibleSplines = errors.New("incompatible B-splines")
+
+	// ErrNotFound is wrapped when looking up a name that is not registered.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidArgument is wrapped for any other invalid argument, like mismatched slice lengths.
+	ErrInvalidArgument = errors.New("invalid argument")
+)
+
+// panicf panics with an error that wraps kind (one of the Err* errors above), with the message formatted as in
+// fmt.Sprintf, and a stack trace -- like exceptions.Panicf does.
+func panicf(kind error, format string, args ...any) {
+	panic(pkgerrors.Wrapf(kind, format, args...))
+}
@@ -0,0 +1,57 @@
+package bsplines
+
+import (
+	"math"
+)
+
+// fairMaxIterations is the maximum number of sweeps of Fair.
+const fairMaxIterations = 10_000
+
+// Fair returns a new B-spline with the same knots and extrapolation, with its control points changed to minimize the
+// bending energy `∫ (d²f/dx²)² dx` (see PenaltyMatrix), while staying within tolerance of the original curve: it's
+// the fairing of curves in design applications, which removes wiggles without refitting to any data.
+//
+// The band is enforced on the control points, each one can move at most tolerance. Since the basis functions are
+// non-negative and sum to 1, this guarantees `|f(x) - original(x)| <= tolerance` over the domain (and over the
+// extrapolation, except for [ExtrapolateLinear], whose slope can change).
+//
+// It's solved with projected Gauss-Seidel iterations, on the bound-constrained quadratic problem. It requires
+// degree >= 2 and the control points to be set.
+func (b *BSpline) Fair(tolerance float64) *BSpline {
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.Fair() require control points to be set using BSpline.WithControlPoints()")
+	}
+	if b.degree < 2 {
+		panicf(ErrInvalidArgument, "BSpline.Fair() requires degree >= 2, got degree %d", b.degree)
+	}
+	if tolerance < 0 {
+		panicf(ErrInvalidArgument, "BSpline.Fair(tolerance=%g) requires tolerance >= 0", tolerance)
+	}
+	p := b.PenaltyMatrix(2)
+	control := make([]float64, len(b.controlPoints))
+	copy(control, b.controlPoints)
+	var scale float64
+	for _, value := range control {
+		scale = max(scale, math.Abs(value))
+	}
+	threshold := 1e-12 * max(scale, tolerance, 1)
+	for range fairMaxIterations {
+		var maxChange float64
+		for ii, row := range p {
+			var gradient float64
+			// The matrix is banded, only the neighbours within degree contribute.
+			for jj := max(ii-b.degree, 0); jj <= min(ii+b.degree, len(control)-1); jj++ {
+				gradient += row[jj] * control[jj]
+			}
+			original := b.controlPoints[ii]
+			value := min(max(control[ii]-gradient/row[ii], original-tolerance), original+tolerance)
+			maxChange = max(maxChange, math.Abs(value-control[ii]))
+			control[ii] = value
+		}
+		if maxChange <= threshold {
+			break
+		}
+	}
+	return newFromExpandedKnots(b.degree, b.expandedKnots).WithExtrapolation(b.extrapolation).
+		WithClosedDomain(b.closedDomain).WithControlPoints(control)
+}
@@ -0,0 +1,181 @@
+package bsplines
+
+import (
+	"fmt"
+	"math"
+)
+
+// FitControlPoints returns the control points that best fit the data points (xs[i], ys[i]) in the least squares
+// sense: they minimize `Σ (f(xs[i]) - ys[i])²`, solving the normal equations. Values of xs outside the domain, or
+// NaN, are ignored.
+//
+// There must be enough data points under the support of each basis function for the system to be non-singular,
+// otherwise it panics.
+//
+// Use it with WithControlPoints, e.g.: `b.WithControlPoints(b.FitControlPoints(xs, ys))`.
+func (b *BSpline) FitControlPoints(xs, ys []float64) []float64 {
+	normal, rhs := b.normalEquations("BSpline.FitControlPoints", xs, ys)
+	return solveLinearSystem(normal, rhs)
+}
+
+// normalEquations returns the normal matrix `Bᵀ B` and the right-hand side `Bᵀ y` of the least squares fit of the
+// data points, where B is the matrix of the basis functions evaluated at the xs inside the domain.
+func (b *BSpline) normalEquations(fnName string, xs, ys []float64) (normal [][]float64, rhs []float64) {
+	if len(xs) != len(ys) {
+		panicf(ErrInvalidArgument, "%s() requires the same number of xs (%d) and ys (%d)", fnName, len(xs), len(ys))
+	}
+	numControlPoints := b.NumControlPoints()
+	normal = newMatrix(numControlPoints, numControlPoints)
+	rhs = make([]float64, numControlPoints)
+	basis := make([]float64, b.degree+1)
+	domainMin, domainMax := b.Domain()
+	for ii, x := range xs {
+		if !(x >= domainMin && x <= domainMax) {
+			// Also NaN.
+			continue
+		}
+		span := b.SpanIndex(x)
+		b.localBasis(span, x, b.degree, basis)
+		for r, br := range basis {
+			row := span - b.degree + r
+			rhs[row] += br * ys[ii]
+			for s, bs := range basis {
+				normal[row][span-b.degree+s] += br * bs
+			}
+		}
+	}
+	return normal, rhs
+}
+
+// Report summarizes the quality of a fit, see BSpline.FitWithReport, BSpline.FitPenalizedWithReport and
+// Periodic.FitWithReport.
+type Report struct {
+	// NumPoints is the number of data points used by the fit: RMSE, MaxError and Spans only cover them.
+	NumPoints int
+
+	// NumIgnored is the number of data points ignored by the fit, because they were outside the domain or NaN.
+	NumIgnored int
+
+	// RMSE is the root-mean-square error of the fit on the data points used.
+	RMSE float64
+
+	// MaxError is the largest absolute error on the data points used.
+	MaxError float64
+
+	// EffectiveDegreesOfFreedom of the fit: the trace of the hat matrix, which maps the data to the fitted values.
+	// For an unpenalized least squares fit, it's the number of control points, and penalized fits have fewer.
+	EffectiveDegreesOfFreedom float64
+
+	// Spans summarizes the residuals on each (non-empty) knot span of the domain.
+	Spans []SpanResiduals
+}
+
+// SpanResiduals summarizes the residuals of a fit on one knot span, see Report.
+type SpanResiduals struct {
+	// Start and End of the knot span.
+	Start, End float64
+
+	// NumPoints in the span, and their RMSE and MaxError. They are 0 if there are no data points in the span.
+	NumPoints      int
+	RMSE, MaxError float64
+}
+
+// String implements fmt.Stringer, with a one-line summary for logging.
+func (r *Report) String() string {
+	return fmt.Sprintf("fit: %d points (%d ignored), RMSE=%.4g, max error=%.4g, effective degrees of freedom=%.4g",
+		r.NumPoints, r.NumIgnored, r.RMSE, r.MaxError, r.EffectiveDegreesOfFreedom)
+}
+
+// FitWithReport fits the data points like FitControlPoints, and returns a new B-spline with the same knots and
+// configuration as b and the fitted control points, along with a Report with the quality of the fit, so callers can
+// log it uniformly instead of recomputing it. b is not changed.
+func (b *BSpline) FitWithReport(xs, ys []float64) (*BSpline, *Report) {
+	normal, rhs := b.normalEquations("BSpline.FitWithReport", xs, ys)
+	return b.fitWithReport(normal, normal, rhs, xs, ys)
+}
+
+// FitPenalizedWithReport is like FitWithReport, but it fits a penalized (P-spline like) least squares, minimizing
+// `Σ (f(xs[i]) - ys[i])² + lambda ∫ (f^(order)(x))² dx`, with the roughness penalty given by PenaltyMatrix(order).
+// Larger values of lambda give smoother fits, with fewer effective degrees of freedom in the Report.
+//
+// It panics if lambda is negative or order is not in `[0, degree]`.
+func (b *BSpline) FitPenalizedWithReport(xs, ys []float64, order int, lambda float64) (*BSpline, *Report) {
+	if lambda < 0 || math.IsNaN(lambda) {
+		panicf(ErrInvalidArgument, "BSpline.FitPenalizedWithReport() requires lambda >= 0, got %g", lambda)
+	}
+	penalty := b.PenaltyMatrix(order)
+	normal, rhs := b.normalEquations("BSpline.FitPenalizedWithReport", xs, ys)
+	system := newMatrix(len(normal), len(normal))
+	for ii := range system {
+		for jj := range system[ii] {
+			system[ii][jj] = normal[ii][jj] + lambda*penalty[ii][jj]
+		}
+	}
+	return b.fitWithReport(system, normal, rhs, xs, ys)
+}
+
+// fitWithReport solves `system * c = rhs` for the control points c, and returns the fitted B-spline and its Report.
+// The normal matrix is used for the effective degrees of freedom.
+func (b *BSpline) fitWithReport(system, normal [][]float64, rhs, xs, ys []float64) (*BSpline, *Report) {
+	fitted := newFromExpandedKnots(b.degree, b.expandedKnots).WithExtrapolation(b.extrapolation).
+		WithClosedDomain(b.closedDomain).WithControlPoints(solveLinearSystem(system, rhs))
+	report := fitted.residualsReport(xs, ys, nil)
+	report.EffectiveDegreesOfFreedom = hatMatrixTrace(system, normal)
+	return fitted, report
+}
+
+// hatMatrixTrace returns the trace of the hat matrix `B system⁻¹ Bᵀ` of a fit, which is the trace of
+// `system⁻¹ normal` for the (symmetric) normal matrix `normal = Bᵀ B`.
+func hatMatrixTrace(system, normal [][]float64) float64 {
+	var trace float64
+	for ii, column := range solveLinearSystems(system, normal) {
+		trace += column[ii]
+	}
+	return trace
+}
+
+// residualsReport returns a Report with the residuals of b on the data points used by the fit -- those in the closed
+// domain, evaluated with the basis of the last span at its end, as in normalEquations -- without the effective
+// degrees of freedom. If wrap is not nil, it maps each x to where b is evaluated, e.g. to the first period of a
+// Periodic.
+func (b *BSpline) residualsReport(xs, ys []float64, wrap func(x float64) float64) *Report {
+	report := &Report{}
+	spanIndex := make(map[int]int) // Knot span -> index in report.Spans.
+	for span := b.degree; span < b.NumControlPoints(); span++ {
+		if start, end := b.expandedKnots[span], b.expandedKnots[span+1]; end > start {
+			spanIndex[span] = len(report.Spans)
+			report.Spans = append(report.Spans, SpanResiduals{Start: start, End: end})
+		}
+	}
+	var sumSquares float64
+	spanSumSquares := make([]float64, len(report.Spans))
+	basis := make([]float64, b.degree+1)
+	domainMin, domainMax := b.Domain()
+	for ii, x := range xs {
+		if wrap != nil {
+			x = wrap(x)
+		}
+		if !(x >= domainMin && x <= domainMax) {
+			report.NumIgnored++
+			continue
+		}
+		span := b.SpanIndex(x)
+		residual := math.Abs(b.evaluateSpan(span, x, basis) - ys[ii])
+		report.NumPoints++
+		sumSquares += residual * residual
+		report.MaxError = max(report.MaxError, residual)
+		idx := spanIndex[span]
+		spanSumSquares[idx] += residual * residual
+		report.Spans[idx].NumPoints++
+		report.Spans[idx].MaxError = max(report.Spans[idx].MaxError, residual)
+	}
+	if report.NumPoints > 0 {
+		report.RMSE = math.Sqrt(sumSquares / float64(report.NumPoints))
+	}
+	for idx := range report.Spans {
+		if n := report.Spans[idx].NumPoints; n > 0 {
+			report.Spans[idx].RMSE = math.Sqrt(spanSumSquares[idx] / float64(n))
+		}
+	}
+	return report
+}
@@ -0,0 +1,141 @@
+package bsplines
+
+import "slices"
+
+// Float is the constraint for the floating point types supported by Evaluator.
+type Float interface {
+	~float32 | ~float64
+}
+
+// Evaluator evaluates a B-spline using the floating point type T for all the arithmetic, so float32 users (embedded,
+// ML) don't need to convert slices back and forth. It matches the float32 support of the GoMLX evaluator.
+//
+// It is immutable: it takes a snapshot of the knots, control points and configuration of the B-spline (create it
+// with NewEvaluator or BSpline.Evaluator), and WithControlPoints returns a new Evaluator. So, unlike the BSpline
+// builder, it's safe to share across goroutines, each evaluating it with different control points.
+type Evaluator[T Float] struct {
+	degree                       int
+	expandedKnots, controlPoints []T
+	extrapolation                ExtrapolationType
+	closedDomain                 bool
+	lowRatio, highRatio          T
+}
+
+// NewEvaluator creates an Evaluator for the B-spline using the floating point type T.
+// If the B-spline has control points set, they are converted to T, otherwise they must be set with
+// Evaluator.WithControlPoints.
+func NewEvaluator[T Float](b *BSpline) *Evaluator[T] {
+	e := &Evaluator[T]{
+		degree:        b.degree,
+		expandedKnots: make([]T, len(b.expandedKnots)),
+		extrapolation: b.extrapolation,
+		closedDomain:  b.closedDomain,
+	}
+	for ii, knot := range b.expandedKnots {
+		e.expandedKnots[ii] = T(knot)
+	}
+	low, high := b.LinearExtrapolationKnotRatios()
+	e.lowRatio, e.highRatio = T(low), T(high)
+	if len(b.controlPoints) > 0 {
+		controlPoints := make([]T, len(b.controlPoints))
+		for ii, value := range b.controlPoints {
+			controlPoints[ii] = T(value)
+		}
+		e.controlPoints = controlPoints
+	}
+	return e
+}
+
+// Evaluator returns an immutable Evaluator with a snapshot of the B-spline, see NewEvaluator.
+func (b *BSpline) Evaluator() *Evaluator[float64] {
+	return NewEvaluator[float64](b)
+}
+
+// WithControlPoints returns a new Evaluator that shares the knots and configuration of e, but uses the given control
+// points, see BSpline.WithControlPoints. The control points are copied, and e is not changed.
+func (e *Evaluator[T]) WithControlPoints(controlPoints []T) *Evaluator[T] {
+	if len(controlPoints) != len(e.expandedKnots)-e.degree-1 {
+		panicf(ErrControlPointCount, "Evaluator.WithControlPoints() expected %d control points, got %d instead", len(e.expandedKnots)-e.degree-1, len(controlPoints))
+	}
+	newE := *e
+	newE.controlPoints = slices.Clone(controlPoints)
+	return &newE
+}
+
+// Evaluate the B-spline at x, see BSpline.Evaluate.
+func (e *Evaluator[T]) Evaluate(x T) T {
+	var scratch [8]T
+	basis := scratch[:]
+	if e.degree+1 > len(scratch) {
+		basis = make([]T, e.degree+1)
+	}
+	return e.evaluate(x, basis)
+}
+
+// EvaluateBatchInto evaluates the B-spline at each of the xs, and writes the results into output, which must have the
+// same length as xs.
+func (e *Evaluator[T]) EvaluateBatchInto(xs, output []T) {
+	if len(output) != len(xs) {
+		panicf(ErrInvalidArgument, "Evaluator.EvaluateBatchInto() requires len(output)=%d to be equal to len(xs)=%d", len(output), len(xs))
+	}
+	basis := make([]T, e.degree+1)
+	for ii, x := range xs {
+		output[ii] = e.evaluate(x, basis)
+	}
+}
+
+// evaluate implements Evaluate, with the given scratch space for the basis functions.
+func (e *Evaluator[T]) evaluate(x T, basis []T) T {
+	if len(e.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "Evaluator.Evaluate() require control points to be set using Evaluator.WithControlPoints()")
+	}
+	knots, control := e.expandedKnots, e.controlPoints
+	first, last := knots[0], knots[len(knots)-1]
+	if x < first || x > last || (x == last && !e.closedDomain) {
+		switch e.extrapolation {
+		case ExtrapolateConstant:
+			if x < first {
+				return control[0]
+			}
+			return control[len(control)-1]
+		case ExtrapolateLinear:
+			if x < first {
+				return control[0] + (x-first)*(control[1]-control[0])*e.lowRatio
+			}
+			return control[len(control)-1] + (x-last)*(control[len(control)-1]-control[len(control)-2])*e.highRatio
+		default:
+			return 0
+		}
+	}
+
+	// Binary search of the span, see BSpline.SpanIndex.
+	low, high := e.degree, len(control)-1
+	for low < high {
+		mid := (low + high + 1) / 2
+		if knots[mid] <= x {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	span := low
+
+	// De Boor triangle, see BSpline.localBasis.
+	basis[0] = 1
+	for jj := 1; jj <= e.degree; jj++ {
+		var saved T
+		for r := range jj {
+			left := x - knots[span+1-jj+r]
+			right := knots[span+1+r] - x
+			temp := basis[r] / (right + left)
+			basis[r] = saved + right*temp
+			saved = left * temp
+		}
+		basis[jj] = saved
+	}
+	var result T
+	for r, weight := range basis[:e.degree+1] {
+		result += weight * control[span-e.degree+r]
+	}
+	return result
+}
@@ -1,12 +1,13 @@
 module github.com/gomlx/bsplines
 
-go 1.22
+go 1.23
 
 require (
 	github.com/MetalBlueberry/go-plotly v0.4.0
 	github.com/gomlx/exceptions v0.0.3
 	github.com/gomlx/gomlx v0.10.0
 	github.com/janpfeifer/gonb v0.10.1
+	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.9.0
 )
 
@@ -15,7 +16,6 @@ require (
 	github.com/go-logr/logr v1.4.2 // indirect
 	github.com/gofrs/uuid v4.4.0+incompatible // indirect
 	github.com/kr/text v0.2.0 // indirect
-	github.com/pkg/errors v0.9.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/x448/float16 v0.8.4 // indirect
 	golang.org/x/exp v0.0.0-20240604190554-fc45aab8b7f8 // indirect
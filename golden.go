@@ -0,0 +1,86 @@
+package bsplines
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+)
+
+// GoldenSamples holds the values of a B-spline sampled on a grid, to be saved to a golden JSON file and used to lock
+// the behavior of a fitted B-spline across library upgrades, see BSpline.GoldenSamples and BSpline.CompareGolden.
+type GoldenSamples struct {
+	Xs []float64 `json:"xs"`
+	Ys []float64 `json:"ys"`
+}
+
+// goldenMargin is the fraction of the domain width sampled beyond each end by GoldenSamples, to cover extrapolation.
+const goldenMargin = 0.1
+
+// GoldenSamples samples the B-spline on a canonical grid: n evenly spaced values (inclusive) covering the domain and
+// 10% of its width beyond each end, so the extrapolation is covered as well.
+//
+// The control points must be set.
+func (b *BSpline) GoldenSamples(n int) *GoldenSamples {
+	domainMin, domainMax := b.Domain()
+	margin := goldenMargin * (domainMax - domainMin)
+	xs, ys := b.EvaluateLinspace(domainMin-margin, domainMax+margin, n)
+	return &GoldenSamples{Xs: xs, Ys: ys}
+}
+
+// WriteFile writes the samples as JSON to the file in path.
+func (g *GoldenSamples) WriteFile(path string) error {
+	encoded, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return fmt.Errorf("bsplines.GoldenSamples failed to encode: %w", err)
+	}
+	if err = os.WriteFile(path, encoded, 0o644); err != nil {
+		return fmt.Errorf("bsplines.GoldenSamples failed to write golden file: %w", err)
+	}
+	return nil
+}
+
+// ReadGoldenFile reads the samples written by GoldenSamples.WriteFile.
+func ReadGoldenFile(path string) (*GoldenSamples, error) {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("bsplines.ReadGoldenFile failed to read golden file: %w", err)
+	}
+	g := &GoldenSamples{}
+	if err = json.Unmarshal(encoded, g); err != nil {
+		return nil, fmt.Errorf("bsplines.ReadGoldenFile failed to decode %q: %w", path, err)
+	}
+	if len(g.Xs) != len(g.Ys) {
+		return nil, fmt.Errorf("bsplines.ReadGoldenFile %q has %d xs and %d ys", path, len(g.Xs), len(g.Ys))
+	}
+	return g, nil
+}
+
+// CompareGolden evaluates the B-spline at the xs of the golden file in path (see GoldenSamples.WriteFile) and
+// compares the results with the saved ys. It returns an error describing the worst mismatch, if any value differs
+// by more than tolerance, or if the file can't be read.
+//
+// A typical test writes the golden file when a flag (e.g. `-update`) is given, and compares it otherwise.
+func (b *BSpline) CompareGolden(path string, tolerance float64) error {
+	g, err := ReadGoldenFile(path)
+	if err != nil {
+		return err
+	}
+	got := b.EvaluateBatch(g.Xs)
+	var worstDiff float64
+	worst := -1
+	for ii, want := range g.Ys {
+		diff := math.Abs(got[ii] - want)
+		if math.IsNaN(got[ii]) != math.IsNaN(want) {
+			diff = math.Inf(1)
+		}
+		if diff > tolerance && diff > worstDiff {
+			worstDiff, worst = diff, ii
+		}
+	}
+	if worst >= 0 {
+		return fmt.Errorf("bsplines.CompareGolden() mismatch of %g > tolerance %g at x=%g: got %g, golden %g",
+			worstDiff, tolerance, g.Xs[worst], got[worst], g.Ys[worst])
+	}
+	return nil
+}
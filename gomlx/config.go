@@ -0,0 +1,146 @@
+package gomlx
+
+import (
+	"github.com/gomlx/bsplines"
+	"github.com/gomlx/exceptions"
+	. "github.com/gomlx/gomlx/graph"
+)
+
+// Config holds the configuration of a B-spline evaluation in GoMLX. Create it with [New], and after configuring it,
+// use Config.Evaluate to build the computation graph.
+//
+// The package functions [Evaluate] and [EvaluateLocal] are shortcuts for the default configurations.
+type Config struct {
+	bspline     *bsplines.BSpline
+	local       bool
+	knotEpsilon float64
+
+	outputExtrapolations []bsplines.ExtrapolationType
+}
+
+// New returns a Config object for the B-spline b (only its knots, degree and extrapolation are used), that can be
+// changed. Once finished, call Config.Evaluate to build the evaluation graph.
+func New(b *bsplines.BSpline) *Config {
+	return &Config{
+		bspline: b,
+	}
+}
+
+// WithLocal configures the evaluation to gather only the knots and control points local to each input, see
+// [EvaluateLocal] for details. Default is false.
+func (c *Config) WithLocal(local bool) *Config {
+	c.local = local
+	return c
+}
+
+// WithKnotEpsilon configures inputs that lie exactly on a knot (after conversion to the inputs dtype) to be nudged
+// by epsilon to the right, into the next knot span.
+//
+// Inputs on knot boundaries are sensitive to the rounding of the knots to the inputs dtype: e.g. a float32
+// input equal to `float32(0.1)` is on the knot in the graph, but it's slightly larger than the float64 knot 0.1 used
+// by the CPU implementation. Nudging them makes the result deterministic, and allows the GoMLX float32 results
+// to match the float64 CPU results, see [Config.CheckParity].
+//
+// Default is 0, which disables the nudging.
+func (c *Config) WithKnotEpsilon(epsilon float64) *Config {
+	c.knotEpsilon = epsilon
+	return c
+}
+
+// WithOutputExtrapolations configures a different extrapolation for each output, instead of using the extrapolation
+// of the B-spline. E.g.: linear tails for a regression output and constant for a probability output.
+//
+// There must be one value per output, that is, `numOutputs = controlPoints.Shape().Dimensions[1]`.
+// Set it to nil (the default) to use the B-spline extrapolation for all outputs.
+func (c *Config) WithOutputExtrapolations(extrapolations []bsplines.ExtrapolationType) *Config {
+	c.outputExtrapolations = extrapolations
+	return c
+}
+
+// Evaluate creates the computation graph to evaluate the B-splines, see the package function [Evaluate] for
+// details on the parameters and the returned shape.
+func (c *Config) Evaluate(inputs, controlPoints *Node) *Node {
+	e, inputIsScalar := newEvalData("Evaluate", newSplineSpec("Evaluate", c.bspline), inputs, controlPoints)
+	return c.evaluate(e, inputIsScalar)
+}
+
+// EvaluateWithKnots is like Evaluate, but the knots are given as a tensor, instead of taken from the configured
+// B-spline, so they can be learned: the gradients with respect to the knots flow through the basis functions and
+// the extrapolation. The degree of the configured B-spline is still used, and its knots are ignored.
+//
+// The knots tensor is shaped `[numKnots]`, with the same dtype as the inputs, where numKnots must match
+// `len(b.Knots())` of the configured B-spline -- the clamping knots at the ends are added in the graph. The knots
+// must be strictly increasing, which is not checked: e.g. parametrize them as the cumulative sum of positive deltas.
+func (c *Config) EvaluateWithKnots(inputs, controlPoints, knots *Node) *Node {
+	e, inputIsScalar := newEvalData("EvaluateWithKnots", newSplineSpec("EvaluateWithKnots", c.bspline), inputs, controlPoints)
+	e.setKnots(knots)
+	return c.evaluate(e, inputIsScalar)
+}
+
+// evaluate implements Evaluate and EvaluateWithKnots.
+func (c *Config) evaluate(e *evalData, inputIsScalar bool) *Node {
+	if c.outputExtrapolations != nil {
+		if len(c.outputExtrapolations) != e.numOutputs {
+			exceptions.Panicf("bsplines.gomlx.Evaluate() configured with %d output extrapolations, but the controlPoints (shape=%s) have numOutputs=%d",
+				len(c.outputExtrapolations), e.controlPoints.Shape(), e.numOutputs)
+		}
+		e.outputExtrapolations = c.outputExtrapolations
+	}
+	if c.knotEpsilon > 0 {
+		e.nudgeKnotInputs(c.knotEpsilon)
+	}
+	var out *Node
+	if c.local {
+		out = e.EvalLocal()
+	} else {
+		out = e.Eval()
+	}
+	if e.numOutputs == 1 && inputIsScalar {
+		out = Reshape(out) // reshape to scalar
+	}
+	return out
+}
+
+// nudgeKnotInputs adds epsilon to the inputs that are exactly on a knot.
+func (e *evalData) nudgeKnotInputs(epsilon float64) {
+	onKnot := ConvertType(Equal(e.flatInputs, e.knots), e.dtype) // shape [batchSize*numInputs, numKnots]
+	onKnot = StopGradient(ReduceMax(onKnot, -1))                 // shape [batchSize*numInputs]
+	e.inputs = Add(e.inputs, MulScalar(Reshape(onKnot, e.batchSize, e.numInputs), epsilon))
+	e.flatInputs = Reshape(e.inputs, -1, 1)
+}
+
+// setKnots replaces the constant knots by the given knots tensor, shaped [numKnots], see Config.EvaluateWithKnots.
+// The domain and the linear extrapolation ratios are calculated from it in the graph.
+func (e *evalData) setKnots(knots *Node) {
+	degree := e.bspline.Degree()
+	numKnots := len(e.bspline.Knots())
+	if knots.Rank() != 1 || knots.Shape().Dimensions[0] != numKnots || knots.DType() != e.dtype {
+		exceptions.Panicf("bsplines.gomlx.EvaluateWithKnots() requires knots shaped [numKnots=%d] with dtype %s, got knots.shape=%s",
+			numKnots, e.dtype, knots.Shape())
+	}
+
+	// Expanded knots: clamp the ends by repeating the first and last knots degree times.
+	first := Slice(knots, AxisElem(0))
+	last := Slice(knots, AxisElem(-1))
+	parts := make([]*Node, 0, 2*degree+1)
+	for range degree {
+		parts = append(parts, first)
+	}
+	parts = append(parts, knots)
+	for range degree {
+		parts = append(parts, last)
+	}
+	expanded := Concatenate(parts, 0) // shape [numExpandedKnots]
+	e.knots = ExpandDims(expanded, 0) // shape [1, numExpandedKnots]
+	e.domainMin, e.domainMax = Reshape(first), Reshape(last)
+
+	// Linear extrapolation ratios, from the x-coordinates of the second and second-to-last control points, see
+	// bsplines.BSpline.ControlPointsX.
+	if degree > 0 {
+		controlX := func(idx int) *Node {
+			return DivScalar(ReduceAllSum(Slice(expanded, AxisRange(idx+1, idx+1+degree))), float64(degree))
+		}
+		e.lowKnotRatio = Inverse(Sub(controlX(1), e.domainMin))
+		e.highKnotRatio = Inverse(Sub(e.domainMax, controlX(e.numControlPoints-2)))
+	}
+}
@@ -0,0 +1,58 @@
+package gomlx
+
+import (
+	"fmt"
+	"github.com/gomlx/exceptions"
+	. "github.com/gomlx/gomlx/graph"
+	"github.com/gomlx/gomlx/types/shapes"
+)
+
+// CompileGraph builds and compiles the B-spline evaluation graph for fixed shapes, without executing it.
+//
+// The graph takes 2 parameters: "inputs", shaped `[batchSize, numInputs]`, and "control_points", shaped
+// `[numInputs, numOutputs, numControlPoints]`, both with the given dtype. It outputs the evaluation shaped
+// `[batchSize, numOutputs, numInputs]`, see [Evaluate].
+//
+// Errors are reported back with panics, as usual in GoMLX.
+func (c *Config) CompileGraph(manager *Manager, dtype shapes.DType, batchSize, numInputs, numOutputs int) *Graph {
+	g := manager.NewGraph(fmt.Sprintf("bsplines_degree%d", c.bspline.Degree()))
+	inputs := g.Parameter("inputs", shapes.Make(dtype, batchSize, numInputs))
+	controlPoints := g.Parameter("control_points", shapes.Make(dtype, numInputs, numOutputs, c.bspline.NumControlPoints()))
+	output := c.Evaluate(inputs, controlPoints)
+	g.Compile(output)
+	return g
+}
+
+// ExportStableHLO compiles the B-spline evaluation for fixed shapes (see [Config.CompileGraph]) and returns its
+// human-readable StableHLO text, so it can be inspected or cached separately from the training code.
+func (c *Config) ExportStableHLO(manager *Manager, dtype shapes.DType, batchSize, numInputs, numOutputs int) (text string, err error) {
+	err = exceptions.TryCatch[error](func() {
+		g := c.CompileGraph(manager, dtype, batchSize, numInputs, numOutputs)
+		defer g.Finalize()
+		stableHLO := g.ConvertToStableHLO()
+		defer stableHLO.Finalize()
+		text = stableHLO.String()
+	})
+	if err != nil {
+		err = fmt.Errorf("bsplines.gomlx.ExportStableHLO() failed: %w", err)
+	}
+	return
+}
+
+// SerializeStableHLO compiles the B-spline evaluation for fixed shapes (see [Config.CompileGraph]) and serializes
+// its StableHLO bytecode to filePath, so the compiled artifact can be shipped separately from the training code.
+func (c *Config) SerializeStableHLO(manager *Manager, dtype shapes.DType, batchSize, numInputs, numOutputs int, filePath string) error {
+	err := exceptions.TryCatch[error](func() {
+		g := c.CompileGraph(manager, dtype, batchSize, numInputs, numOutputs)
+		defer g.Finalize()
+		stableHLO := g.ConvertToStableHLO()
+		defer stableHLO.Finalize()
+		if err := stableHLO.Serialize(filePath); err != nil {
+			panic(err)
+		}
+	})
+	if err != nil {
+		err = fmt.Errorf("bsplines.gomlx.SerializeStableHLO() failed: %w", err)
+	}
+	return err
+}
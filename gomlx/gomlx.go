@@ -14,6 +14,7 @@ import (
 	"github.com/gomlx/exceptions"
 	. "github.com/gomlx/gomlx/graph"
 	"github.com/gomlx/gomlx/types/shapes"
+	"slices"
 )
 
 // Evaluate creates the computation graph to evaluate the B-splines defined by b (it's used only for the knots) and
@@ -39,50 +40,100 @@ import (
 //
 // If the inputs tensor was a scalar, and numInputs==1 and numOutput==1, it returns a scalar
 // as well -- for individual points inference, useful for testing.
+//
+// For more options, see [New] and [Config].
 func Evaluate(b *bsplines.BSpline, inputs, controlPoints *Node) *Node {
+	return New(b).Evaluate(inputs, controlPoints)
+}
+
+// NewGraphFn returns a pure graph function that evaluates the B-spline b, with the same semantics as [Evaluate].
+//
+// The configuration of b (degree, knots and extrapolation) is captured and validated at creation time, and the values
+// derived from it (expanded knots, domain and extrapolation ratios) are calculated once: so later changes to b don't
+// affect the returned function, and its output depends only on its arguments. Each call only checks the shapes of
+// its arguments. This makes it safe and cheap to use inside graph functions that are built multiple times, e.g. in
+// loops or mapped constructs.
+//
+// The control points set in b are ignored, the ones passed as argument are used instead.
+func NewGraphFn(b *bsplines.BSpline) func(inputs, controlPoints *Node) *Node {
+	snapshot := bsplines.New(b.Degree(), slices.Clone(b.Knots())).WithExtrapolation(b.Extrapolation())
+	spec := newSplineSpec("NewGraphFn", snapshot)
+	config := New(snapshot)
+	return func(inputs, controlPoints *Node) *Node {
+		e, inputIsScalar := newEvalData("NewGraphFn", spec, inputs, controlPoints)
+		return config.evaluate(e, inputIsScalar)
+	}
+}
+
+// splineSpec holds the values derived from the B-spline used to build the evaluation graph, after validating it.
+type splineSpec struct {
+	bspline                                           *bsplines.BSpline
+	numControlPoints                                  int
+	expandedKnots                                     []float64
+	domainMin, domainMax, lowKnotRatio, highKnotRatio float64
+}
+
+// newSplineSpec validates the B-spline and calculates its splineSpec. The fnName is used in the error messages.
+func newSplineSpec(fnName string, b *bsplines.BSpline) *splineSpec {
+	if b.Extrapolation() < bsplines.ExtrapolateZero || b.Extrapolation() > bsplines.ExtrapolateLinear {
+		exceptions.Panicf("bsplines.gomlx.%s() got a B-spline with an unknown extrapolation %s", fnName, b.Extrapolation())
+	}
+	spec := &splineSpec{
+		bspline:          b,
+		numControlPoints: b.NumControlPoints(),
+		expandedKnots:    b.ExpandedKnots(),
+	}
+	spec.domainMin, spec.domainMax = b.Domain()
+	spec.lowKnotRatio, spec.highKnotRatio = b.LinearExtrapolationKnotRatios()
+	return spec
+}
+
+// newEvalData checks the inputs and control points shapes, and returns the evalData used to build the evaluation
+// of the B-spline described by spec. The fnName is used in the error messages.
+func newEvalData(fnName string, spec *splineSpec, inputs, controlPoints *Node) (e *evalData, inputIsScalar bool) {
 	// Sanity checks.
 	if inputs.DType() != controlPoints.DType() {
-		exceptions.Panicf("bsplines.gomlx.Evaluate() requires the inputs.dtype=%s and controlPoints.dtype=%s to be the same",
-			inputs.DType(), controlPoints.DType())
+		exceptions.Panicf("bsplines.gomlx.%s() requires the inputs.dtype=%s and controlPoints.dtype=%s to be the same",
+			fnName, inputs.DType(), controlPoints.DType())
 	}
 	if controlPoints.Rank() == 1 {
 		controlPoints = ExpandDims(controlPoints, 0, 0)
 	}
 	if controlPoints.Rank() != 3 {
-		exceptions.Panicf("bsplines.gomlx.Evaluate() requires control points to have rank 3, shape [numInputs, numOutputs, numControlPoints], instead got shape %s",
-			controlPoints.Shape())
+		exceptions.Panicf("bsplines.gomlx.%s() requires control points to have rank 3, shape [numInputs, numOutputs, numControlPoints], instead got shape %s",
+			fnName, controlPoints.Shape())
 	}
 	numInputs := controlPoints.Shape().Dimensions[0]
 	numOutputs := controlPoints.Shape().Dimensions[1]
 	numControlPoints := controlPoints.Shape().Dimensions[2]
-	if numControlPoints != b.NumControlPoints() {
-		exceptions.Panicf("bsplines.gomlx.Evaluate() the controlPoints (shape=%s) last dimension doesn't match the B-spline b's required control points %d",
-			controlPoints.Shape(), b.NumControlPoints())
+	if numControlPoints != spec.numControlPoints {
+		exceptions.Panicf("bsplines.gomlx.%s() the controlPoints (shape=%s) last dimension doesn't match the B-spline b's required control points %d",
+			fnName, controlPoints.Shape(), spec.numControlPoints)
 	}
-	inputIsScalar := inputs.Shape().IsScalar()
+	inputIsScalar = inputs.Shape().IsScalar()
 	if inputIsScalar {
 		inputs = Reshape(inputs, 1, 1) // `[batchSize, numInputs]`
 		if numInputs != 1 {
-			exceptions.Panicf("bsplines.gomlx.Evaluate() the controlPoints has shape=%s (numInputs=%d), but inputs given is a scalar, shapes don't match",
-				controlPoints.Shape(), numInputs)
+			exceptions.Panicf("bsplines.gomlx.%s() the controlPoints has shape=%s (numInputs=%d), but inputs given is a scalar, shapes don't match",
+				fnName, controlPoints.Shape(), numInputs)
 		}
 	} else if inputs.Rank() == 2 { // `[batchSize, numInputs]`
 		if inputs.Shape().Dimensions[1] != numInputs {
-			exceptions.Panicf("bsplines.gomlx.Evaluate() the controlPoints (shape=%s) numInputs=%d doesn't match the inputs (%s) numInputs=%d",
-				controlPoints.Shape(), numInputs, inputs.Shape(), inputs.Shape().Dimensions[1])
+			exceptions.Panicf("bsplines.gomlx.%s() the controlPoints (shape=%s) numInputs=%d doesn't match the inputs (%s) numInputs=%d",
+				fnName, controlPoints.Shape(), numInputs, inputs.Shape(), inputs.Shape().Dimensions[1])
 		}
 	} else {
-		exceptions.Panicf("bsplines.gomlx.Evaluate() expects inputs to be of rank=2 or a scalar, got inputs.shape=%s",
-			inputs.Shape())
+		exceptions.Panicf("bsplines.gomlx.%s() expects inputs to be of rank=2 or a scalar, got inputs.shape=%s",
+			fnName, inputs.Shape())
 	}
 
 	// Create knots constant.
-	knots := ConstAsDType(inputs.Graph(), inputs.DType(), b.ExpandedKnots())
+	knots := ConstAsDType(inputs.Graph(), inputs.DType(), spec.expandedKnots)
 	numKnots := knots.Shape().Dimensions[0]
 	knots = ExpandDims(knots, 0) // shape [1, numKnots]
 
-	out := (&evalData{
-		bspline:          b,
+	e = &evalData{
+		bspline:          spec.bspline,
 		graph:            inputs.Graph(),
 		dtype:            inputs.DType(),
 		batchSize:        inputs.Shape().Dimensions[0],
@@ -94,11 +145,12 @@ func Evaluate(b *bsplines.BSpline, inputs, controlPoints *Node) *Node {
 		controlPoints:    controlPoints,
 		knots:            knots,
 		flatInputs:       Reshape(inputs, -1, 1), // shape [batchSize*numInputs, 1]
-	}).Eval()
-	if numOutputs == 1 && inputIsScalar {
-		out = Reshape(out) // reshape to scalar
 	}
-	return out
+	e.domainMin = Scalar(e.graph, e.dtype, spec.domainMin)
+	e.domainMax = Scalar(e.graph, e.dtype, spec.domainMax)
+	e.lowKnotRatio = Scalar(e.graph, e.dtype, spec.lowKnotRatio)
+	e.highKnotRatio = Scalar(e.graph, e.dtype, spec.highKnotRatio)
+	return
 }
 
 // evalData holds all parameters for building an B-Splines evaluation graph, after all inputs have been checked.
@@ -108,6 +160,14 @@ type evalData struct {
 	dtype                                                        shapes.DType
 	batchSize, numInputs, numOutputs, numControlPoints, numKnots int // dimensions
 	inputs, controlPoints, knots, flatInputs                     *Node
+
+	// outputExtrapolations, if not nil, overrides the extrapolation of bspline for each output.
+	outputExtrapolations []bsplines.ExtrapolationType
+
+	// domainMin, domainMax are the first and last knots, and lowKnotRatio, highKnotRatio are the ratios used by
+	// the linear extrapolation (see bsplines.BSpline.LinearExtrapolationKnotRatios): all scalars.
+	// They are constants, except if the knots are given as a tensor, see Config.EvaluateWithKnots.
+	domainMin, domainMax, lowKnotRatio, highKnotRatio *Node
 }
 
 func (e *evalData) Eval() *Node {
@@ -124,7 +184,7 @@ func (e *evalData) Eval() *Node {
 	// - l: numOutputs
 	// Result: [batchSize, numOutputs, numInputs]
 	output := Einsum("ijk,jlk->ilj", basis, e.controlPoints)
-	if e.bspline.Extrapolation() != bsplines.ExtrapolateZero {
+	if e.needsExtrapolation() {
 		// Default extrapolated values are already zero, so extrapolation only needed if != ExtrapolateZero.
 		where, extrapolation := e.Extrapolate()
 		output = Where(where, extrapolation, output)
@@ -174,47 +234,73 @@ func (e *evalData) basisFunction(degree int) *Node {
 	return Add(left, right)
 }
 
-// last returns the last element of a slice.
-func last[E any](s []E) E {
-	return s[len(s)-1]
-}
-
 // Extrapolate returns a boolean tensor of which values should be replaced by extrapolation, and
 // the extrapolated values. Both are shaped `[batchSize, numOutput, numInput]`.
 func (e *evalData) Extrapolate() (where, value *Node) {
-	staticKnots := e.bspline.Knots()
-	kFirst := Scalar(e.graph, e.dtype, staticKnots[0])
-	kLast := Scalar(e.graph, e.dtype, last(staticKnots))
-
-	// broadcastInputs from shape [batchSize, numInputs] to [batchSize, numOutputs, numInputs]
-	broadcastInputs := func(x *Node) *Node {
-		return ExpandAndBroadcast(x, []int{e.batchSize, e.numOutputs, e.numInputs}, []int{1})
-	}
-	expandedInputs := broadcastInputs(e.inputs)
-	tooLow := LessThan(expandedInputs, kFirst)
+	expandedInputs := e.broadcastInputs(e.inputs)
+	tooLow := LessThan(expandedInputs, e.domainMin)
 	where = Or(
 		tooLow,
-		GreaterOrEqual(expandedInputs, kLast))
+		GreaterOrEqual(expandedInputs, e.domainMax))
+
+	if e.outputExtrapolations == nil {
+		value = e.extrapolationValue(e.bspline.Extrapolation(), tooLow)
+		return
+	}
+
+	// Extrapolation configured per output: calculate each type of extrapolation used only once, and
+	// take the slice corresponding to each output.
+	values := make(map[bsplines.ExtrapolationType]*Node)
+	parts := make([]*Node, e.numOutputs)
+	for outputIdx, extrapolation := range e.outputExtrapolations {
+		if _, found := values[extrapolation]; !found {
+			values[extrapolation] = e.extrapolationValue(extrapolation, tooLow)
+		}
+		parts[outputIdx] = Slice(values[extrapolation], AxisRange(), AxisElem(outputIdx))
+	}
+	value = Concatenate(parts, 1)
+	return
+}
 
-	// From shape [numInputs, numOutputs, 1] to [batchSize, numOutputs, numInputs].
-	transposeAndBroadcastControlPoints := func(control *Node) *Node {
-		// Input shape: [numInputs, numOutputs, 1]
-		// Output shape: [batchSize, numOutputs, numInputs]
-		control = TransposeAllDims(control, 2, 1, 0)
-		control = BroadcastToDims(control, e.batchSize, e.numOutputs, e.numInputs)
-		return control
+// needsExtrapolation returns whether any of the outputs uses an extrapolation other than ExtrapolateZero.
+func (e *evalData) needsExtrapolation() bool {
+	if e.outputExtrapolations == nil {
+		return e.bspline.Extrapolation() != bsplines.ExtrapolateZero
 	}
+	for _, extrapolation := range e.outputExtrapolations {
+		if extrapolation != bsplines.ExtrapolateZero {
+			return true
+		}
+	}
+	return false
+}
+
+// broadcastInputs from shape [batchSize, numInputs] to [batchSize, numOutputs, numInputs]
+func (e *evalData) broadcastInputs(x *Node) *Node {
+	return ExpandAndBroadcast(x, []int{e.batchSize, e.numOutputs, e.numInputs}, []int{1})
+}
+
+// transposeAndBroadcastControlPoints from shape [numInputs, numOutputs, 1] to [batchSize, numOutputs, numInputs].
+func (e *evalData) transposeAndBroadcastControlPoints(control *Node) *Node {
+	control = TransposeAllDims(control, 2, 1, 0)
+	control = BroadcastToDims(control, e.batchSize, e.numOutputs, e.numInputs)
+	return control
+}
 
-	switch e.bspline.Extrapolation() {
+// extrapolationValue returns the values extrapolated with the given extrapolation type, shaped
+// `[batchSize, numOutput, numInput]`. tooLow indicates which inputs are below the first knot, the others are
+// assumed to be above the last knot.
+func (e *evalData) extrapolationValue(extrapolation bsplines.ExtrapolationType, tooLow *Node) (value *Node) {
+	switch extrapolation {
 	case bsplines.ExtrapolateZero:
 		// Not necessary, since values will already be zero outsize of the knots range.
 		value = Zeros(e.graph, shapes.Make(e.dtype, e.batchSize, e.numOutputs, e.numInputs))
 
 	case bsplines.ExtrapolateConstant:
 		controlFirst := Slice(e.controlPoints, AxisRange(), AxisRange(), AxisElem(0))
-		controlFirst = transposeAndBroadcastControlPoints(controlFirst)
+		controlFirst = e.transposeAndBroadcastControlPoints(controlFirst)
 		controlLast := Slice(e.controlPoints, AxisRange(), AxisRange(), AxisElem(-1))
-		controlLast = transposeAndBroadcastControlPoints(controlLast)
+		controlLast = e.transposeAndBroadcastControlPoints(controlLast)
 		value = Where(tooLow, controlFirst, controlLast)
 
 	case bsplines.ExtrapolateLinear:
@@ -222,29 +308,28 @@ func (e *evalData) Extrapolate() (where, value *Node) {
 		// High -> for values above the last knot.
 
 		// Shapes: [numInputs, numOutputs, 1]
-		lowKnotRatio, highKnotRatio := e.bspline.LinearExtrapolationKnotRatios()
 		lowStart := Slice(e.controlPoints /*numInputs*/, AxisRange() /*numOutputs*/, AxisRange(), AxisElem(0))
 		lowLinearCoef := Sub(
 			Slice(e.controlPoints /*numInputs*/, AxisRange() /*numOutputs*/, AxisRange(), AxisElem(1)),
 			lowStart)
-		lowLinearCoef = MulScalar(lowLinearCoef, lowKnotRatio)
+		lowLinearCoef = Mul(lowLinearCoef, e.lowKnotRatio)
 		highStart := Slice(e.controlPoints /*numInputs*/, AxisRange() /*numOutputs*/, AxisRange(), AxisElem(-1))
 		highLinearCoef := Sub(
 			highStart,
 			Slice(e.controlPoints /*numInputs*/, AxisRange() /*numOutputs*/, AxisRange(), AxisElem(-2)))
-		highLinearCoef = MulScalar(highLinearCoef, highKnotRatio)
+		highLinearCoef = Mul(highLinearCoef, e.highKnotRatio)
 
 		// Shapes: [batchSize, numInputs]
-		lowDelta := AddScalar(e.inputs, -staticKnots[0])     // x - knots[0], a negative number if x < knots[0]
-		highDelta := AddScalar(e.inputs, -last(staticKnots)) // x - knots[-1]
+		lowDelta := Sub(e.inputs, e.domainMin)  // x - knots[0], a negative number if x < knots[0]
+		highDelta := Sub(e.inputs, e.domainMax) // x - knots[-1]
 
 		// Broadcast everything to [batchSize, numOutputs, numInputs]
-		lowLinearCoef = transposeAndBroadcastControlPoints(lowLinearCoef)
-		lowStart = transposeAndBroadcastControlPoints(lowStart)
-		highLinearCoef = transposeAndBroadcastControlPoints(highLinearCoef)
-		highStart = transposeAndBroadcastControlPoints(highStart)
-		lowDelta = broadcastInputs(lowDelta)
-		highDelta = broadcastInputs(highDelta)
+		lowLinearCoef = e.transposeAndBroadcastControlPoints(lowLinearCoef)
+		lowStart = e.transposeAndBroadcastControlPoints(lowStart)
+		highLinearCoef = e.transposeAndBroadcastControlPoints(highLinearCoef)
+		highStart = e.transposeAndBroadcastControlPoints(highStart)
+		lowDelta = e.broadcastInputs(lowDelta)
+		highDelta = e.broadcastInputs(highDelta)
 
 		// Calculate linear extrapolations:
 		lowExtrapolation := Add(
@@ -5,9 +5,12 @@ import (
 	"github.com/gomlx/bsplines"
 	. "github.com/gomlx/gomlx/graph"
 	"github.com/gomlx/gomlx/graph/graphtest"
+	"github.com/gomlx/gomlx/ml/context"
+	"github.com/gomlx/gomlx/types/shapes"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"math/rand/v2"
+	"slices"
 	"testing"
 )
 
@@ -135,3 +138,313 @@ func TestExtrapolation(t *testing.T) {
 		fmt.Printf("\tOk.\n")
 	}
 }
+
+func TestEvaluateLocal(t *testing.T) {
+	const (
+		batchSize        = 11
+		numInputs        = 3
+		numOutputs       = 5
+		numControlPoints = 37
+		margin           = 0.1 // So we get some extrapolated points.
+	)
+	rng := rand.New(rand.NewPCG(42, 42))
+	inputs := make([][]float64, batchSize)
+	for ee := range batchSize {
+		inputs[ee] = make([]float64, numInputs)
+		for ii := range numInputs {
+			inputs[ee][ii] = rng.Float64()*(1+2*margin) - margin
+		}
+	}
+	controlPoints := make([][][]float64, numInputs)
+	for ii := range numInputs {
+		controlPoints[ii] = make([][]float64, numOutputs)
+		for oo := range numOutputs {
+			controlPoints[ii][oo] = make([]float64, numControlPoints)
+			for cc := range numControlPoints {
+				controlPoints[ii][oo][cc] = rng.NormFloat64()
+			}
+		}
+	}
+
+	for _, degree := range []int{0, 1, 3} {
+		for _, extrapolation := range []bsplines.ExtrapolationType{bsplines.ExtrapolateZero, bsplines.ExtrapolateLinear} {
+			b := bsplines.NewRegular(degree, numControlPoints).WithExtrapolation(extrapolation)
+			want := make([][][]float64, batchSize)
+			for ee := range batchSize {
+				want[ee] = make([][]float64, numOutputs)
+				for oo := range numOutputs {
+					want[ee][oo] = make([]float64, numInputs)
+					for ii := range numInputs {
+						b.WithControlPoints(controlPoints[ii][oo])
+						want[ee][oo][ii] = b.Evaluate(inputs[ee][ii])
+					}
+				}
+			}
+			graphtest.RunTestGraphFn(t, fmt.Sprintf("EvaluateLocal(degree=%d, %s)", degree, extrapolation), func(g *Graph) ([]*Node, []*Node) {
+				nodeInputs := Const(g, inputs)
+				nodeControlPoints := Const(g, controlPoints)
+				outputs := EvaluateLocal(b, nodeInputs, nodeControlPoints)
+				return []*Node{nodeInputs, nodeControlPoints}, []*Node{outputs}
+			}, []any{want},
+				1e-4)
+		}
+	}
+}
+
+func TestNewGraphFn(t *testing.T) {
+	controlPoints := []float64{1.0, 0.7, -0.7, -1.0, -0.7, 0.7, 1.0, 0.7}
+	b := bsplines.NewRegular(3, len(controlPoints)).WithControlPoints(controlPoints).WithExtrapolation(bsplines.ExtrapolateLinear)
+	xs := []float64{-0.1, 0.0, 0.3, 0.7, 1.1}
+	want := make([]float64, len(xs))
+	for ii, x := range xs {
+		want[ii] = b.Evaluate(x)
+	}
+	evalFn := NewGraphFn(b)
+
+	// Changing b after creating the graph function must not affect it.
+	b.WithExtrapolation(bsplines.ExtrapolateZero)
+
+	manager := graphtest.BuildTestManager()
+	exec := NewExec(manager, evalFn)
+	got := make([]float64, len(xs))
+	for ii, x := range xs {
+		got[ii] = exec.Call(x, controlPoints)[0].Value().(float64)
+	}
+	require.InDeltaSlice(t, want, got, 1e-4)
+
+	// The B-spline is validated once, when the graph function is created.
+	require.Panics(t, func() { NewGraphFn(b.WithExtrapolation(bsplines.ExtrapolationType(7))) })
+}
+
+func TestCheckParity(t *testing.T) {
+	controlPoints := [][][]float64{{{1.0, 0.7, -0.7, -1.0, -0.7, 0.7, 1.0, 0.7}}}
+	b := bsplines.NewRegular(3, len(controlPoints[0][0])).WithExtrapolation(bsplines.ExtrapolateLinear)
+	knots := b.Knots()
+	inputs := make([][]float64, len(knots))
+	for ii, knot := range knots {
+		inputs[ii] = []float64{knot} // Inputs exactly on the knots.
+	}
+	manager := graphtest.BuildTestManager()
+	require.NoError(t, New(b).WithKnotEpsilon(1e-5).CheckParity(manager, shapes.Float32, inputs, controlPoints, 1e-4))
+	require.NoError(t, New(b).WithLocal(true).WithKnotEpsilon(1e-5).CheckParity(manager, shapes.Float32, inputs, controlPoints, 1e-4))
+	require.NoError(t, New(b).CheckParity(manager, shapes.Float64, inputs, controlPoints, 1e-9))
+	require.NoError(t, New(b).WithKnotEpsilon(1e-7).CheckParity(manager, shapes.Float64, inputs, controlPoints, 1e-9))
+}
+
+func TestOutputExtrapolations(t *testing.T) {
+	controlPoints := []float64{1.0, 0.7, -0.7, -1.0, -0.7, 0.7, 1.0, 0.7}
+	b := bsplines.NewRegular(3, len(controlPoints)).WithControlPoints(controlPoints)
+	extrapolations := []bsplines.ExtrapolationType{bsplines.ExtrapolateZero, bsplines.ExtrapolateConstant, bsplines.ExtrapolateLinear}
+	inputs := [][]float64{{-0.1}, {0.5}, {1.1}}
+
+	// Same control points for each output, only the extrapolation changes.
+	want := make([][][]float64, len(inputs))
+	for ee, example := range inputs {
+		want[ee] = make([][]float64, len(extrapolations))
+		for oo, extrapolation := range extrapolations {
+			b.WithExtrapolation(extrapolation)
+			want[ee][oo] = []float64{b.Evaluate(example[0])}
+		}
+	}
+	b.WithExtrapolation(bsplines.ExtrapolateConstant)
+	graphtest.RunTestGraphFn(t, "Per-output extrapolation", func(g *Graph) ([]*Node, []*Node) {
+		nodeInputs := Const(g, inputs)
+		nodeControlPoints := Const(g, [][][]float64{{controlPoints, controlPoints, controlPoints}})
+		outputs := New(b).WithOutputExtrapolations(extrapolations).Evaluate(nodeInputs, nodeControlPoints)
+		return []*Node{nodeInputs, nodeControlPoints}, []*Node{outputs}
+	}, []any{want},
+		1e-4)
+}
+
+func TestEvaluateMixed(t *testing.T) {
+	bs := []*bsplines.BSpline{
+		bsplines.NewRegular(1, 4),
+		bsplines.NewRegular(3, 9).WithExtrapolation(bsplines.ExtrapolateLinear),
+	}
+	controlPoints := [][][]float64{
+		{{0.0, 1.0, -1.0, 0.5}, {1.0, 2.0, 3.0, 4.0}},
+		{{1.0, 0.7, -0.7, -1.0, -0.7, 0.7, 1.0, 0.7, 0.1}, {0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9}},
+	}
+	inputs := [][]float64{{-0.1, 0.2}, {0.35, 0.75}, {0.9, 1.2}}
+	want := make([][][]float64, len(inputs))
+	for ee, example := range inputs {
+		want[ee] = [][]float64{make([]float64, len(bs)), make([]float64, len(bs))}
+		for ii, b := range bs {
+			for oo := range 2 {
+				want[ee][oo][ii] = b.WithControlPoints(controlPoints[ii][oo]).Evaluate(example[ii])
+			}
+		}
+	}
+	graphtest.RunTestGraphFn(t, "EvaluateMixed", func(g *Graph) ([]*Node, []*Node) {
+		nodeInputs := Const(g, inputs)
+		configs := []*Config{New(bs[0]), New(bs[1]).WithLocal(true)}
+		outputs := EvaluateMixed(configs, nodeInputs, []*Node{Const(g, controlPoints[0]), Const(g, controlPoints[1])})
+		return []*Node{nodeInputs}, []*Node{outputs}
+	}, []any{want},
+		1e-4)
+}
+
+func TestExportStableHLO(t *testing.T) {
+	b := bsplines.NewRegular(2, 5)
+	text, err := New(b).ExportStableHLO(graphtest.BuildTestManager(), shapes.Float32, 4, 3, 2)
+	require.NoError(t, err)
+	assert.Contains(t, text, "func.func")
+}
+
+func TestOutOfRangeFraction(t *testing.T) {
+	b := bsplines.NewRegular(2, 5)
+	inputs := [][]float32{{-0.1, 0.5}, {0.2, 1.0}, {0.3, 1.5}, {1.2, 0.0}}
+	graphtest.RunTestGraphFn(t, "OutOfRangeFraction", func(g *Graph) ([]*Node, []*Node) {
+		nodeInputs := Const(g, inputs)
+		return []*Node{nodeInputs}, []*Node{OutOfRangeFraction(b, nodeInputs)}
+	}, []any{[]float32{0.5, 0.5}}, 1e-6)
+}
+
+func TestBoundaryWeightDecay(t *testing.T) {
+	controlPoints := [][][]float64{{{1, 2, 3, 4, 5}}, {{-1, 0, 0, 0, 2}}}
+	// Boundary (weight 10): 1+25+1+4 = 31; interior (weight 0.5): 4+9+16+0 = 29.
+	graphtest.RunTestGraphFn(t, "BoundaryWeightDecay", func(g *Graph) ([]*Node, []*Node) {
+		nodeControlPoints := Const(g, controlPoints)
+		return []*Node{nodeControlPoints}, []*Node{BoundaryWeightDecay(nodeControlPoints, 0.5, 10, 1)}
+	}, []any{10*31 + 0.5*29}, 1e-9)
+}
+
+func TestEvaluateWithKnotsGradient(t *testing.T) {
+	const delta = 1e-6
+	knots := []float64{0, 0.2, 0.45, 0.7, 1}
+	controlPoints := []float64{1, -0.5, 2, 0.3, -1, 0.8, 1.5}
+	inputs := [][]float64{{-0.2}, {0.1}, {0.3}, {0.5}, {0.65}, {0.9}, {1.3}}
+
+	// Reference gradient of the sum of the outputs with respect to the knots, with central finite differences
+	// of the CPU implementation.
+	sumOutputs := func(knots []float64) float64 {
+		b := bsplines.New(3, knots).WithExtrapolation(bsplines.ExtrapolateLinear).WithControlPoints(controlPoints)
+		var sum float64
+		for _, example := range inputs {
+			sum += b.Evaluate(example[0])
+		}
+		return sum
+	}
+	want := make([]float64, len(knots))
+	for ii := range knots {
+		plus, minus := slices.Clone(knots), slices.Clone(knots)
+		plus[ii] += delta
+		minus[ii] -= delta
+		want[ii] = (sumOutputs(plus) - sumOutputs(minus)) / (2 * delta)
+	}
+
+	b := bsplines.New(3, knots).WithExtrapolation(bsplines.ExtrapolateLinear)
+	for _, local := range []bool{false, true} {
+		manager := graphtest.BuildTestManager()
+		exec := NewExec(manager, func(inputs, controlPoints, knots *Node) (value, gradient *Node) {
+			value = ReduceAllSum(New(b).WithLocal(local).EvaluateWithKnots(inputs, controlPoints, knots))
+			gradient = Gradient(value, knots)[0]
+			return
+		})
+		results := exec.Call(inputs, controlPoints, knots)
+		assert.InDelta(t, sumOutputs(knots), results[0].Value().(float64), 1e-9, "local=%v", local)
+		assert.InDeltaSlice(t, want, results[1].Value().([]float64), 1e-5, "local=%v", local)
+	}
+}
+
+func TestEvaluateSurface(t *testing.T) {
+	const (
+		batchSize  = 5
+		numPairs   = 2
+		numOutputs = 3
+	)
+	bU := bsplines.NewRegular(3, 6).WithExtrapolation(bsplines.ExtrapolateZero)
+	bV := bsplines.New(2, []float64{0, 0.3, 1}).WithExtrapolation(bsplines.ExtrapolateZero)
+	nU, nV := bU.NumControlPoints(), bV.NumControlPoints()
+	rng := rand.New(rand.NewPCG(42, 42))
+
+	inputs := make([][][]float64, batchSize)
+	for ee := range inputs {
+		inputs[ee] = [][]float64{{rng.Float64(), rng.Float64()}, {rng.Float64(), rng.Float64()}}
+	}
+	controlPoints := make([][][][]float64, numPairs)
+	for pp := range controlPoints {
+		controlPoints[pp] = make([][][]float64, numOutputs)
+		for oo := range controlPoints[pp] {
+			controlPoints[pp][oo] = make([][]float64, nU)
+			for ii := range nU {
+				controlPoints[pp][oo][ii] = make([]float64, nV)
+				for jj := range nV {
+					controlPoints[pp][oo][ii][jj] = rng.NormFloat64()
+				}
+			}
+		}
+	}
+
+	// CPU reference: evaluate along v for each row of control points, and then along u.
+	want := make([][][]float64, batchSize)
+	for ee := range batchSize {
+		want[ee] = make([][]float64, numOutputs)
+		for oo := range numOutputs {
+			want[ee][oo] = make([]float64, numPairs)
+			for pp := range numPairs {
+				u, v := inputs[ee][pp][0], inputs[ee][pp][1]
+				rows := make([]float64, nU)
+				for ii := range nU {
+					rows[ii] = bV.WithControlPoints(controlPoints[pp][oo][ii]).Evaluate(v)
+				}
+				want[ee][oo][pp] = bU.WithControlPoints(rows).Evaluate(u)
+			}
+		}
+	}
+
+	graphtest.RunTestGraphFn(t, "EvaluateSurface", func(g *Graph) ([]*Node, []*Node) {
+		nodeInputs := Const(g, inputs)
+		nodeControlPoints := Const(g, controlPoints)
+		return []*Node{nodeInputs, nodeControlPoints}, []*Node{EvaluateSurface(bU, bV, nodeInputs, nodeControlPoints)}
+	}, []any{want}, 1e-9)
+}
+
+func TestDropoutAndNoisyControlPoints(t *testing.T) {
+	const numInputs, numOutputs, numControlPoints = 20, 10, 6
+	controlPoints := make([][][]float64, numInputs)
+	for ii := range controlPoints {
+		controlPoints[ii] = make([][]float64, numOutputs)
+		for oo := range controlPoints[ii] {
+			controlPoints[ii][oo] = []float64{1, 2, 3, 4, 5, 6}
+		}
+	}
+	manager := graphtest.BuildTestManager()
+	for _, training := range []bool{false, true} {
+		ctx := context.NewContext(manager)
+		ctx.RngStateFromSeed(42)
+		exec := context.NewExec(manager, ctx, func(ctx *context.Context, controlPoints *Node) (dropped, noisy *Node) {
+			ctx.SetTraining(controlPoints.Graph(), training)
+			dropped = DropoutControlPoints(ctx, controlPoints, 0.5, true)
+			noisy = NoisyControlPoints(ctx, controlPoints, 0.1, true)
+			return
+		})
+		results := exec.Call(controlPoints)
+		dropped := results[0].Value().([][][]float64)
+		noisy := results[1].Value().([][][]float64)
+		if !training {
+			assert.Equal(t, controlPoints, dropped)
+			assert.Equal(t, controlPoints, noisy)
+			continue
+		}
+		var numDropped int
+		for ii := range numInputs {
+			for oo := range numOutputs {
+				edge, noisyEdge := dropped[ii][oo], noisy[ii][oo]
+				if edge[0] == 0 {
+					numDropped++
+					assert.Equal(t, make([]float64, numControlPoints), edge)
+				} else {
+					assert.Equal(t, []float64{2, 4, 6, 8, 10, 12}, edge)
+				}
+				// Whole edge noise: the same offset for all the control points of the edge.
+				offset := noisyEdge[0] - 1
+				for cc, value := range noisyEdge {
+					assert.InDelta(t, float64(cc+1)+offset, value, 1e-9)
+				}
+			}
+		}
+		assert.Greater(t, numDropped, 0)
+		assert.Less(t, numDropped, numInputs*numOutputs)
+	}
+}
@@ -0,0 +1,101 @@
+package gomlx
+
+import (
+	"github.com/gomlx/bsplines"
+	. "github.com/gomlx/gomlx/graph"
+	"github.com/gomlx/gomlx/types/shapes"
+	"math/bits"
+)
+
+// EvaluateLocal is equivalent to [Evaluate], but instead of calculating the basis functions for every knot, it finds
+// the knot span of each input (with a binary search) and gathers only the `degree+1` knots and control points that
+// affect it.
+//
+// This keeps the memory used at `O(batchSize*numInputs*(degree+1))`, instead of `O(batchSize*numInputs*numKnots)`,
+// which makes a large difference for fine-grained grids, with hundreds or thousands of control points -- e.g.:
+// for KAN networks with large grids. For small grids [Evaluate] is usually faster.
+//
+// Parameters and the shape of the returned tensor are the same as in [Evaluate].
+// It's a shortcut to `New(b).WithLocal(true).Evaluate(inputs, controlPoints)`.
+func EvaluateLocal(b *bsplines.BSpline, inputs, controlPoints *Node) *Node {
+	return New(b).WithLocal(true).Evaluate(inputs, controlPoints)
+}
+
+// EvalLocal builds the evaluation graph using only the knots and control points local to each input.
+// See [EvaluateLocal].
+func (e *evalData) EvalLocal() *Node {
+	degree := e.bspline.Degree()
+	knots := Reshape(e.knots, -1) // shape [numKnots]
+	x := Reshape(e.inputs, -1)    // shape [batchSize*numInputs]
+	span := e.spanIndex(knots, x) // shape [batchSize*numInputs], dtype Int32
+
+	// Basis functions of the span, calculated with the De Boor triangle: basis[r] is the weight for control point
+	// `span-degree+r`. Each is shaped [batchSize*numInputs].
+	basis := make([]*Node, degree+1)
+	basis[0] = OnesLike(x)
+	if degree > 0 {
+		// Local knots window: knots[span-degree+1 : span+degree+1], shape [batchSize*numInputs, 2*degree].
+		start := ExpandDims(AddScalar(span, float64(1-degree)), -1)
+		window := GatherSlices(knots, []int{0}, start, []int{2 * degree})
+		windowKnot := func(idx int) *Node {
+			return Reshape(Slice(window, AxisRange(), AxisElem(idx)), -1)
+		}
+		for jj := 1; jj <= degree; jj++ {
+			saved := ZerosLike(x)
+			for r := range jj {
+				left := Sub(x, windowKnot(degree-jj+r))
+				right := Sub(windowKnot(degree+r), x)
+				temp := Div(basis[r], Add(right, left))
+				basis[r] = Add(saved, Mul(right, temp))
+				saved = Mul(left, temp)
+			}
+			basis[jj] = saved
+		}
+	}
+	for r := range basis {
+		basis[r] = ExpandDims(basis[r], -1)
+	}
+	localBasis := Concatenate(basis, -1)                                    // shape [batchSize*numInputs, degree+1]
+	localBasis = Reshape(localBasis, e.batchSize, e.numInputs, 1, degree+1) // shape [batchSize, numInputs, 1, degree+1]
+
+	// Local control points window: controlPoints[input, :, span-degree : span+1], for each example and input.
+	inputIdx := Iota(e.graph, shapes.Make(shapes.Int32, e.batchSize, e.numInputs, 1), 1)
+	controlStart := Reshape(AddScalar(span, float64(-degree)), e.batchSize, e.numInputs, 1)
+	controlStart = Concatenate([]*Node{inputIdx, controlStart}, -1) // shape [batchSize, numInputs, 2]
+	localControl := GatherSlices(e.controlPoints, []int{0, 2}, controlStart, []int{1, degree + 1})
+	localControl = Reshape(localControl, e.batchSize, e.numInputs, e.numOutputs, degree+1)
+
+	output := ReduceSum(Mul(localBasis, localControl), -1) // shape [batchSize, numInputs, numOutputs]
+	output = TransposeAllDims(output, 0, 2, 1)             // shape [batchSize, numOutputs, numInputs]
+
+	// Inputs outside the knots are evaluated using their closest span, so they always need to be replaced by the
+	// extrapolation, even with ExtrapolateZero.
+	where, extrapolation := e.Extrapolate()
+	return Where(where, extrapolation, output)
+}
+
+// spanIndex returns for each x the index k of the knots such that `knots[k] <= x < knots[k+1]`, limited to the
+// range `[degree, numControlPoints-1]`, using a binary search unrolled in the graph.
+// Values of x outside the knots range get the first or last span.
+//
+// The returned value has the same shape as x, and dtype Int32.
+func (e *evalData) spanIndex(knots, x *Node) *Node {
+	degree := e.bspline.Degree()
+	x = Clip(x, e.domainMin, e.domainMax)
+	spanShape := shapes.Make(shapes.Int32, x.Shape().Dimensions...)
+	low := AddScalar(Zeros(e.graph, spanShape), float64(degree))
+	high := AddScalar(Zeros(e.graph, spanShape), float64(e.numControlPoints-1))
+	one := ScalarOne(e.graph, shapes.Int32)
+	two := Scalar(e.graph, shapes.Int32, 2)
+
+	// Invariant: knots[low] <= x, guaranteed by the clipping of x above.
+	numSteps := bits.Len(uint(e.numControlPoints - 1 - degree))
+	for range numSteps {
+		mid := Div(Add(Add(low, high), one), two)
+		midKnot := Gather(knots, ExpandDims(mid, -1))
+		goRight := LessOrEqual(midKnot, x)
+		low = Where(goRight, mid, low)
+		high = Where(goRight, high, Sub(mid, one))
+	}
+	return low
+}
@@ -0,0 +1,45 @@
+package gomlx
+
+import (
+	"github.com/gomlx/exceptions"
+	. "github.com/gomlx/gomlx/graph"
+)
+
+// EvaluateMixed evaluates a different B-spline configuration (degree, knots, extrapolation and other options) per
+// input feature, building one graph for all of them. It's useful when input features are heterogeneous, and a
+// uniform degree or number of knots is not a good fit for all of them.
+//
+// Parameters:
+//   - configs: one [Config] per input feature, see [New].
+//   - inputs: tensor with shape `[batchSize, numInputs]`, where `numInputs == len(configs)`.
+//   - controlPoints: one tensor per input feature, each shaped `[numOutputs, numControlPoints]`, where
+//     numControlPoints must match the corresponding B-spline's `NumControlPoints()`, and numOutputs must be the
+//     same for all inputs. Their dtype must match the dtype of inputs.
+//
+// The returned tensor is shaped `[batchSize, numOutputs, numInputs]`, the same as [Evaluate].
+func EvaluateMixed(configs []*Config, inputs *Node, controlPoints []*Node) *Node {
+	numInputs := len(configs)
+	if len(controlPoints) != numInputs {
+		exceptions.Panicf("bsplines.gomlx.EvaluateMixed() requires one controlPoints tensor per config, got %d configs and %d controlPoints",
+			numInputs, len(controlPoints))
+	}
+	if inputs.Rank() != 2 || inputs.Shape().Dimensions[1] != numInputs {
+		exceptions.Panicf("bsplines.gomlx.EvaluateMixed() requires inputs shaped [batchSize, numInputs=%d], got inputs.shape=%s",
+			numInputs, inputs.Shape())
+	}
+	outputs := make([]*Node, numInputs)
+	for inputIdx, config := range configs {
+		control := controlPoints[inputIdx]
+		if control.Rank() != 2 {
+			exceptions.Panicf("bsplines.gomlx.EvaluateMixed() requires control points shaped [numOutputs, numControlPoints], got controlPoints[%d].shape=%s",
+				inputIdx, control.Shape())
+		}
+		if control.Shape().Dimensions[0] != controlPoints[0].Shape().Dimensions[0] {
+			exceptions.Panicf("bsplines.gomlx.EvaluateMixed() requires all control points to have the same numOutputs, got controlPoints[0].shape=%s and controlPoints[%d].shape=%s",
+				controlPoints[0].Shape(), inputIdx, control.Shape())
+		}
+		input := Slice(inputs, AxisRange(), AxisElem(inputIdx))            // shape [batchSize, 1]
+		outputs[inputIdx] = config.Evaluate(input, ExpandDims(control, 0)) // shape [batchSize, numOutputs, 1]
+	}
+	return Concatenate(outputs, -1)
+}
@@ -0,0 +1,27 @@
+package gomlx
+
+import (
+	"github.com/gomlx/bsplines"
+	"github.com/gomlx/exceptions"
+	. "github.com/gomlx/gomlx/graph"
+)
+
+// OutOfRangeFraction returns the fraction of the batch inputs that fall outside the domain of b (the range of its
+// knots), per input feature. Log it as a training metric: a growing fraction means the inputs drifted away from the
+// grid of the B-spline -- e.g. the grid of a KAN layer no longer covers the data -- and they are being extrapolated.
+//
+// The inputs are shaped `[batchSize, numInputs]`, as in [Evaluate], and the returned tensor is shaped `[numInputs]`,
+// with the same dtype. The inputs equal to the last knot are counted as outside, as in [Evaluate]. No gradient flows
+// through the returned value.
+func OutOfRangeFraction(b *bsplines.BSpline, inputs *Node) *Node {
+	if inputs.Rank() != 2 {
+		exceptions.Panicf("bsplines.gomlx.OutOfRangeFraction() expects inputs to be of rank=2, got inputs.shape=%s",
+			inputs.Shape())
+	}
+	g, dtype := inputs.Graph(), inputs.DType()
+	domainMin, domainMax := b.Domain()
+	outside := Or(
+		LessThan(inputs, Scalar(g, dtype, domainMin)),
+		GreaterOrEqual(inputs, Scalar(g, dtype, domainMax)))
+	return StopGradient(ReduceMean(ConvertType(outside, dtype), 0))
+}
@@ -0,0 +1,64 @@
+package gomlx
+
+import (
+	"github.com/gomlx/exceptions"
+	. "github.com/gomlx/gomlx/graph"
+	"github.com/gomlx/gomlx/ml/context"
+	"github.com/gomlx/gomlx/types/shapes"
+	"slices"
+)
+
+// DropoutControlPoints randomly replaces control points by zero with probability rate, and scales the kept ones by
+// `1/(1-rate)` to preserve their mean -- a regularization for KAN layers. If wholeEdge is true, all the control
+// points of a B-spline (edge) are dropped together, otherwise each control point is dropped independently.
+//
+// The controlPoints can have any rank >= 1, with the control points in the last axis, e.g.
+// `[numInputs, numOutputs, numControlPoints]` as in [Evaluate]. The random values are drawn from the context
+// random number generator.
+//
+// It's a no-op (it returns controlPoints) if rate <= 0 or if the context is not training, see
+// context.Context.IsTraining.
+func DropoutControlPoints(ctx *context.Context, controlPoints *Node, rate float64, wholeEdge bool) *Node {
+	g := controlPoints.Graph()
+	if rate <= 0 || !ctx.IsTraining(g) {
+		return controlPoints
+	}
+	if rate >= 1 {
+		exceptions.Panicf("bsplines.gomlx.DropoutControlPoints() requires rate < 1, got %g", rate)
+	}
+	random := ctx.RandomUniform(g, noiseShape(controlPoints, wholeEdge))
+	keep := GreaterOrEqual(random, Scalar(g, controlPoints.DType(), rate))
+	keep = BroadcastToDims(keep, controlPoints.Shape().Dimensions...)
+	return Where(keep, DivScalar(controlPoints, 1-rate), ZerosLike(controlPoints))
+}
+
+// NoisyControlPoints adds gaussian noise with the given standard deviation to the control points -- a regularization
+// for KAN layers. If wholeEdge is true, the same noise is added to all the control points of a B-spline (edge),
+// shifting the whole curve, otherwise each control point gets independent noise.
+//
+// The controlPoints can have any rank >= 1, with the control points in the last axis, see DropoutControlPoints.
+//
+// It's a no-op (it returns controlPoints) if stddev <= 0 or if the context is not training, see
+// context.Context.IsTraining.
+func NoisyControlPoints(ctx *context.Context, controlPoints *Node, stddev float64, wholeEdge bool) *Node {
+	g := controlPoints.Graph()
+	if stddev <= 0 || !ctx.IsTraining(g) {
+		return controlPoints
+	}
+	noise := MulScalar(ctx.RandomNormal(g, noiseShape(controlPoints, wholeEdge)), stddev)
+	return Add(controlPoints, BroadcastToDims(noise, controlPoints.Shape().Dimensions...))
+}
+
+// noiseShape returns the shape of the random values for controlPoints: the same shape, or with the last axis
+// (the control points) of dimension 1 if wholeEdge is set.
+func noiseShape(controlPoints *Node, wholeEdge bool) shapes.Shape {
+	if controlPoints.Rank() < 1 {
+		exceptions.Panicf("bsplines.gomlx: random control points noise requires control points with rank >= 1, got shape %s",
+			controlPoints.Shape())
+	}
+	dims := slices.Clone(controlPoints.Shape().Dimensions)
+	if wholeEdge {
+		dims[len(dims)-1] = 1
+	}
+	return shapes.Make(controlPoints.DType(), dims...)
+}
@@ -0,0 +1,90 @@
+package gomlx
+
+import (
+	"fmt"
+	"github.com/gomlx/bsplines"
+	"github.com/gomlx/exceptions"
+	. "github.com/gomlx/gomlx/graph"
+	"github.com/gomlx/gomlx/types/shapes"
+	"math"
+	"slices"
+)
+
+// CheckParity evaluates the B-splines in GoMLX, using the given manager and dtype, and compares the results with the
+// CPU implementation in the bsplines package (in float64). It returns an error describing the worst mismatch, if
+// any result differs by more than tolerance.
+//
+// The inputs are shaped `[batchSize][numInputs]` and the controlPoints `[numInputs][numOutputs][numControlPoints]`,
+// see [Evaluate]. They are converted to dtype (only Float32 and Float64 are supported) for the GoMLX evaluation, and
+// the CPU evaluation uses the same rounded values -- inputs, control points and knots -- with the same nudging
+// configured by [Config.WithKnotEpsilon]. It returns an error if rounding the knots to dtype makes them repeated.
+//
+// The control points set in the configured B-spline are not used or changed.
+func (c *Config) CheckParity(manager *Manager, dtype shapes.DType, inputs [][]float64, controlPoints [][][]float64, tolerance float64) error {
+	var round func(x float64) float64
+	switch dtype {
+	case shapes.Float32:
+		round = func(x float64) float64 { return float64(float32(x)) }
+	case shapes.Float64:
+		round = func(x float64) float64 { return x }
+	default:
+		return fmt.Errorf("bsplines.gomlx.CheckParity() only supports Float32 and Float64, got dtype %s", dtype)
+	}
+
+	exec := NewExec(manager, func(inputs, controlPoints *Node) *Node {
+		return ConvertType(c.Evaluate(ConvertType(inputs, dtype), ConvertType(controlPoints, dtype)), shapes.Float64)
+	})
+	var got [][][]float64
+	err := exceptions.TryCatch[error](func() {
+		got = exec.Call(inputs, controlPoints)[0].Value().([][][]float64)
+	})
+	if err != nil {
+		return fmt.Errorf("bsplines.gomlx.CheckParity() failed to evaluate in GoMLX: %w", err)
+	}
+
+	// CPU evaluation using the same rounded values, including the knots.
+	b := c.bspline
+	knots := make([]float64, len(b.Knots()))
+	for ii, knot := range b.Knots() {
+		knots[ii] = round(knot)
+	}
+	var cpu *bsplines.BSpline
+	err = exceptions.TryCatch[error](func() {
+		cpu = bsplines.New(b.Degree(), knots).WithExtrapolation(b.Extrapolation())
+	})
+	if err != nil {
+		return fmt.Errorf("bsplines.gomlx.CheckParity() failed to round the knots to %s: %w", dtype, err)
+	}
+	roundedKnots := cpu.ExpandedKnots()
+	var worstDiff float64
+	var worstMsg string
+	for ee, example := range inputs {
+		for ii, input := range example {
+			x := round(input)
+			if c.knotEpsilon > 0 && slices.Contains(roundedKnots, x) {
+				// Nudged in dtype, as in the graph.
+				x = round(x + round(c.knotEpsilon))
+			}
+			for oo, control := range controlPoints[ii] {
+				rounded := make([]float64, len(control))
+				for cc, value := range control {
+					rounded[cc] = round(value)
+				}
+				if c.outputExtrapolations != nil {
+					cpu.WithExtrapolation(c.outputExtrapolations[oo])
+				}
+				want := cpu.WithControlPoints(rounded).Evaluate(x)
+				diff := math.Abs(want - got[ee][oo][ii])
+				if diff > tolerance && diff > worstDiff {
+					worstDiff = diff
+					worstMsg = fmt.Sprintf("example=%d, input=%d (x=%g), output=%d: CPU=%g, GoMLX=%g",
+						ee, ii, input, oo, want, got[ee][oo][ii])
+				}
+			}
+		}
+	}
+	if worstMsg != "" {
+		return fmt.Errorf("bsplines.gomlx.CheckParity() mismatch of %g > tolerance %g at %s", worstDiff, tolerance, worstMsg)
+	}
+	return nil
+}
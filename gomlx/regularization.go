@@ -0,0 +1,40 @@
+package gomlx
+
+import (
+	"github.com/gomlx/exceptions"
+	. "github.com/gomlx/gomlx/graph"
+)
+
+// BoundaryWeightDecay returns a scalar L2 regularization term (to be added to the loss) for the control points, with
+// a separate weight for the numBoundary first and last control points of each B-spline, which govern the
+// extrapolation. A boundaryWeight larger than the interiorWeight stabilizes the extrapolation of KAN layers
+// without penalizing the interior fit -- interiorWeight can be 0.
+//
+// The term is `Σ w_k * c_k²`, summed over all B-splines, where w_k is boundaryWeight for the numBoundary first and
+// last control points, and interiorWeight for the others. The controlPoints can have any rank >= 1, with the control
+// points in the last axis, e.g. `[numInputs, numOutputs, numControlPoints]` as in [Evaluate].
+func BoundaryWeightDecay(controlPoints *Node, interiorWeight, boundaryWeight float64, numBoundary int) *Node {
+	if controlPoints.Rank() < 1 {
+		exceptions.Panicf("bsplines.gomlx.BoundaryWeightDecay() requires control points with rank >= 1, got shape %s",
+			controlPoints.Shape())
+	}
+	numControlPoints := controlPoints.Shape().Dimensions[controlPoints.Rank()-1]
+	if numBoundary < 0 || 2*numBoundary > numControlPoints {
+		exceptions.Panicf("bsplines.gomlx.BoundaryWeightDecay() requires 0 <= numBoundary <= numControlPoints/2 (%d), got %d",
+			numControlPoints/2, numBoundary)
+	}
+	weights := make([]float64, numControlPoints)
+	for ii := range weights {
+		weights[ii] = interiorWeight
+		if ii < numBoundary || ii >= numControlPoints-numBoundary {
+			weights[ii] = boundaryWeight
+		}
+	}
+	weightsDims := make([]int, controlPoints.Rank())
+	for axis := range weightsDims {
+		weightsDims[axis] = 1
+	}
+	weightsDims[len(weightsDims)-1] = numControlPoints
+	weightsNode := Reshape(ConstAsDType(controlPoints.Graph(), controlPoints.DType(), weights), weightsDims...)
+	return ReduceAllSum(Mul(Square(controlPoints), weightsNode))
+}
@@ -0,0 +1,65 @@
+package gomlx
+
+import (
+	"github.com/gomlx/bsplines"
+	"github.com/gomlx/exceptions"
+	. "github.com/gomlx/gomlx/graph"
+)
+
+// EvaluateSurface evaluates batched tensor-product B-spline surfaces at paired inputs:
+// `f(u, v) = Σ_{i,j} controlPoints[i][j] * Nu_i(u) * Nv_j(v)`, where Nu and Nv are the basis functions of bU and bV
+// (only their knots and degree are used). In KAN networks they model learned pairwise-interaction surfaces, in
+// addition to the per-feature 1D edges of [Evaluate].
+//
+// Parameters:
+//   - bU, bV: bsplines.BSpline with the specification of the B-spline of each axis of the surface.
+//   - inputs: tensor shaped `[batchSize, numPairs, 2]`, with the pair (u, v) of inputs of each surface.
+//   - controlPoints: tensor shaped `[numPairs, numOutputs, nU, nV]`, where nU and nV must match
+//     `bU.NumControlPoints()` and `bV.NumControlPoints()`. Its dtype must match the dtype of inputs.
+//
+// The returned tensor is shaped `[batchSize, numOutputs, numPairs]`, like [Evaluate]. There is no extrapolation:
+// the surfaces are zero outside the domain of bU or bV (as with bsplines.ExtrapolateZero).
+func EvaluateSurface(bU, bV *bsplines.BSpline, inputs, controlPoints *Node) *Node {
+	if inputs.DType() != controlPoints.DType() {
+		exceptions.Panicf("bsplines.gomlx.EvaluateSurface() requires the inputs.dtype=%s and controlPoints.dtype=%s to be the same",
+			inputs.DType(), controlPoints.DType())
+	}
+	if controlPoints.Rank() != 4 {
+		exceptions.Panicf("bsplines.gomlx.EvaluateSurface() requires control points to have rank 4, shape [numPairs, numOutputs, nU, nV], instead got shape %s",
+			controlPoints.Shape())
+	}
+	numPairs := controlPoints.Shape().Dimensions[0]
+	nU, nV := controlPoints.Shape().Dimensions[2], controlPoints.Shape().Dimensions[3]
+	if nU != bU.NumControlPoints() || nV != bV.NumControlPoints() {
+		exceptions.Panicf("bsplines.gomlx.EvaluateSurface() the controlPoints (shape=%s) grid doesn't match the B-splines required control points [%d, %d]",
+			controlPoints.Shape(), bU.NumControlPoints(), bV.NumControlPoints())
+	}
+	if inputs.Rank() != 3 || inputs.Shape().Dimensions[1] != numPairs || inputs.Shape().Dimensions[2] != 2 {
+		exceptions.Panicf("bsplines.gomlx.EvaluateSurface() expects inputs shaped [batchSize, numPairs=%d, 2], got inputs.shape=%s",
+			numPairs, inputs.Shape())
+	}
+	batchSize := inputs.Shape().Dimensions[0]
+
+	u := Reshape(Slice(inputs, AxisRange(), AxisRange(), AxisElem(0)), -1) // shape [batchSize*numPairs]
+	v := Reshape(Slice(inputs, AxisRange(), AxisRange(), AxisElem(1)), -1)
+	basisU := Reshape(basisMatrix(bU, u), batchSize, numPairs, nU)
+	basisV := Reshape(basisMatrix(bV, v), batchSize, numPairs, nV)
+
+	// Einsum indices: b=batchSize, p=numPairs, o=numOutputs, u=nU, v=nV.
+	partial := Einsum("bpv,pouv->bpou", basisV, controlPoints)
+	return Einsum("bpu,bpou->bop", basisU, partial)
+}
+
+// basisMatrix returns the values of all the basis functions of b at each x, shaped `[len(x), b.NumControlPoints()]`.
+// x must be of rank 1. The values are zero outside the domain of b.
+func basisMatrix(b *bsplines.BSpline, x *Node) *Node {
+	e := &evalData{
+		bspline:    b,
+		graph:      x.Graph(),
+		dtype:      x.DType(),
+		knots:      ExpandDims(ConstAsDType(x.Graph(), x.DType(), b.ExpandedKnots()), 0), // shape [1, numKnots]
+		flatInputs: Reshape(x, -1, 1),                                                    // shape [len(x), 1]
+	}
+	basis := e.basisFunction(b.Degree()) // shape [len(x), numKnots]
+	return Slice(basis, AxisRange(), AxisRange(0, b.NumControlPoints()))
+}
@@ -0,0 +1,228 @@
+package bsplines
+
+import (
+	"math"
+)
+
+// GridPlan holds the values of the basis functions, and of their derivatives up to some order, for a fixed grid of
+// x values. It's used to evaluate the B-spline and its derivatives over the same grid (e.g. for plotting or fitting),
+// computing the basis functions only once per x value, see NewGridPlan.
+//
+// The plan only depends on the knots and degree of the B-spline, so it can be reused after the control points are
+// changed with BSpline.WithControlPoints.
+type GridPlan struct {
+	bspline  *BSpline
+	xs       []float64
+	maxOrder int
+
+	// spans holds the knot span for each x, or -1 if x is outside the domain.
+	spans []int
+
+	// basis holds, for each x and order (up to maxOrder), the derivatives of the degree+1 non-zero basis functions,
+	// flattened to shape [len(xs), maxOrder+1, degree+1].
+	basis []float64
+}
+
+// NewGridPlan creates a GridPlan for the B-spline evaluated on the xs values, with derivatives up to maxOrder.
+// The xs slice is not copied, and must not be changed.
+func NewGridPlan(b *BSpline, xs []float64, maxOrder int) *GridPlan {
+	if maxOrder < 0 {
+		panicf(ErrInvalidArgument, "bsplines.NewGridPlan(maxOrder=%d) requires maxOrder >= 0", maxOrder)
+	}
+	p := &GridPlan{
+		bspline:  b,
+		xs:       xs,
+		maxOrder: maxOrder,
+		spans:    make([]int, len(xs)),
+		basis:    make([]float64, len(xs)*(maxOrder+1)*(b.degree+1)),
+	}
+	stride := (maxOrder + 1) * (b.degree + 1)
+	ders := newMatrix(maxOrder+1, b.degree+1)
+	scratch := newBasisDerivativesScratch(b.degree)
+	span := b.degree
+	for ii, x := range xs {
+		if !b.inDomain(x) {
+			p.spans[ii] = -1
+			continue
+		}
+		span = b.spanIndexWithHint(x, span)
+		p.spans[ii] = span
+		b.basisDerivatives(span, x, maxOrder, ders, scratch)
+		for k, row := range ders {
+			copy(p.basis[ii*stride+k*(b.degree+1):], row)
+		}
+	}
+	return p
+}
+
+// GridEvaluator returns a GridPlan for the xs grid with only the basis functions (no derivatives) cached, so
+// evaluating the same grid with different control points is a single dot product per point. This is the inner loop
+// of curve fitting and animations.
+//
+// Change the control points with WithControlPoints, and evaluate with GridPlan.Evaluate(0) or
+// GridPlan.EvaluateInto(0, output).
+func (b *BSpline) GridEvaluator(xs []float64) *GridPlan {
+	return NewGridPlan(b, xs, 0)
+}
+
+// Evaluate returns the derivative of the given order (0 for the B-spline value itself) on each of the grid values,
+// using the current control points of the B-spline.
+//
+// Outside the domain, the values are the ones of the extrapolation and its derivatives, as in BSpline.DerivativeN.
+func (p *GridPlan) Evaluate(order int) []float64 {
+	output := make([]float64, len(p.xs))
+	p.EvaluateInto(order, output)
+	return output
+}
+
+// EvaluateInto is like Evaluate, but writes the results into output, which must have the same length as the grid.
+// It doesn't allocate any memory.
+func (p *GridPlan) EvaluateInto(order int, output []float64) {
+	b := p.bspline
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "GridPlan.Evaluate() require control points to be set using BSpline.WithControlPoints()")
+	}
+	if order < 0 || order > p.maxOrder {
+		panicf(ErrInvalidArgument, "GridPlan.Evaluate(order=%d) requires 0 <= order <= maxOrder (%d)", order, p.maxOrder)
+	}
+	if len(output) != len(p.xs) {
+		panicf(ErrInvalidArgument, "GridPlan.EvaluateInto() requires len(output)=%d to be equal to the grid size %d", len(output), len(p.xs))
+	}
+	stride := (p.maxOrder + 1) * (b.degree + 1)
+	for ii, x := range p.xs {
+		span := p.spans[ii]
+		if span < 0 {
+			switch {
+			case math.IsNaN(x):
+				output[ii] = x
+			case order == 0:
+				output[ii] = b.extrapolate(x)
+			case order == 1:
+				output[ii] = b.extrapolationSlope(x)
+			default:
+				output[ii] = 0
+			}
+			continue
+		}
+		output[ii] = b.combine(span, p.basis[ii*stride+order*(b.degree+1):ii*stride+(order+1)*(b.degree+1)])
+	}
+}
+
+// BasisMatrix returns the dense matrix shaped `[len(xs)][NumControlPoints()]` with the values of the basis
+// functions on each of the grid values -- the derivatives of the given order of the basis functions, if order > 0.
+// The B-spline on the grid is the product of this matrix by the control points, so it's the design matrix used by
+// least squares fitting.
+//
+// Values of the grid outside the domain have rows of zeros.
+func (p *GridPlan) BasisMatrix(order int) [][]float64 {
+	b := p.bspline
+	if order < 0 || order > p.maxOrder {
+		panicf(ErrInvalidArgument, "GridPlan.BasisMatrix(order=%d) requires 0 <= order <= maxOrder (%d)", order, p.maxOrder)
+	}
+	m := newMatrix(len(p.xs), b.NumControlPoints())
+	stride := (p.maxOrder + 1) * (b.degree + 1)
+	for ii, span := range p.spans {
+		if span < 0 {
+			continue
+		}
+		copy(m[ii][span-b.degree:], p.basis[ii*stride+order*(b.degree+1):ii*stride+(order+1)*(b.degree+1)])
+	}
+	return m
+}
+
+// EvaluateAll returns the B-spline and all its derivatives up to maxOrder on each of the grid values: the result
+// is indexed by `[order][xIdx]`. See Evaluate.
+func (p *GridPlan) EvaluateAll() [][]float64 {
+	results := make([][]float64, p.maxOrder+1)
+	for order := range results {
+		results[order] = p.Evaluate(order)
+	}
+	return results
+}
+
+// basisDerivativesScratch holds the temporary buffers used by BSpline.basisDerivatives.
+type basisDerivativesScratch struct {
+	ndu, a      [][]float64
+	left, right []float64
+}
+
+func newBasisDerivativesScratch(degree int) *basisDerivativesScratch {
+	return &basisDerivativesScratch{
+		ndu:   newMatrix(degree+1, degree+1),
+		a:     newMatrix(2, degree+1),
+		left:  make([]float64, degree+1),
+		right: make([]float64, degree+1),
+	}
+}
+
+// basisDerivatives calculates the derivatives, up to order n, of the `degree+1` basis functions that are non-zero in
+// the knot span (see SpanIndex), and stores them in ders, shaped `[n+1][degree+1]`: ders[k][r] holds the k-th
+// derivative of the basis function for control point `span-degree+r`.
+//
+// It's the algorithm A2.3 in "The NURBS Book", by Piegl and Tiller.
+func (b *BSpline) basisDerivatives(span int, x float64, n int, ders [][]float64, scratch *basisDerivativesScratch) {
+	p := b.degree
+	ndu, a, left, right := scratch.ndu, scratch.a, scratch.left, scratch.right
+
+	// Basis functions and knot differences.
+	ndu[0][0] = 1
+	for j := 1; j <= p; j++ {
+		left[j] = x - b.expandedKnots[span+1-j]
+		right[j] = b.expandedKnots[span+j] - x
+		saved := 0.0
+		for r := range j {
+			ndu[j][r] = right[r+1] + left[j-r]
+			temp := ndu[r][j-1] / ndu[j][r]
+			ndu[r][j] = saved + right[r+1]*temp
+			saved = left[j-r] * temp
+		}
+		ndu[j][j] = saved
+	}
+	for j := 0; j <= p; j++ {
+		ders[0][j] = ndu[j][p]
+	}
+
+	// Derivatives.
+	for r := 0; r <= p; r++ {
+		s1, s2 := 0, 1
+		a[0][0] = 1
+		for k := 1; k <= n; k++ {
+			if k > p {
+				ders[k][r] = 0
+				continue
+			}
+			d := 0.0
+			rk, pk := r-k, p-k
+			if r >= k {
+				a[s2][0] = a[s1][0] / ndu[pk+1][rk]
+				d = a[s2][0] * ndu[rk][pk]
+			}
+			j1, j2 := 1, k-1
+			if rk < -1 {
+				j1 = -rk
+			}
+			if r-1 > pk {
+				j2 = p - r
+			}
+			for j := j1; j <= j2; j++ {
+				a[s2][j] = (a[s1][j] - a[s1][j-1]) / ndu[pk+1][rk+j]
+				d += a[s2][j] * ndu[rk+j][pk]
+			}
+			if r <= pk {
+				a[s2][k] = -a[s1][k-1] / ndu[pk+1][r]
+				d += a[s2][k] * ndu[r][pk]
+			}
+			ders[k][r] = d
+			s1, s2 = s2, s1
+		}
+	}
+
+	// Multiply by the correct factors: p!/(p-k)!
+	factor := float64(p)
+	for k := 1; k <= min(n, p); k++ {
+		for j := 0; j <= p; j++ {
+			ders[k][j] *= factor
+		}
+		factor *= float64(p - k)
+	}
+}
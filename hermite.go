@@ -0,0 +1,112 @@
+package bsplines
+
+import (
+	"math"
+)
+
+// NewHermite creates a cubic B-spline that is the piecewise cubic Hermite interpolant of the points (x[i], y[i]),
+// with derivatives slopes[i] at each point.
+//
+// The x values become the knots, and must be strictly increasing. Since a piecewise Hermite cubic is only C¹, the
+// interior knots are repeated twice in the expanded knots (see BSpline.ExpandedKnots), so there are
+// `2*len(x)` control points -- the control points of the equivalent Bézier segments.
+//
+// It's the same B-spline created by New with each interior value of x repeated twice.
+func NewHermite(x, y, slopes []float64) *BSpline {
+	if len(y) != len(x) || len(slopes) != len(x) {
+		panicf(ErrInvalidArgument, "bsplines.NewHermite requires the same number of x (%d), y (%d) and slopes (%d)", len(x), len(y), len(slopes))
+	}
+	checkStrictlyIncreasing("bsplines.NewHermite", x)
+	expandedKnots := make([]float64, 0, 2*len(x)+4)
+	expandedKnots = append(expandedKnots, x[0], x[0], x[0], x[0])
+	for _, knot := range x[1 : len(x)-1] {
+		expandedKnots = append(expandedKnots, knot, knot)
+	}
+	expandedKnots = append(expandedKnots, at(x, -1), at(x, -1), at(x, -1), at(x, -1))
+
+	control := make([]float64, 0, 2*len(x))
+	control = append(control, y[0])
+	for ii := range len(x) - 1 {
+		h := x[ii+1] - x[ii]
+		control = append(control, y[ii]+h*slopes[ii]/3, y[ii+1]-h*slopes[ii+1]/3)
+	}
+	control = append(control, at(y, -1))
+	return newFromExpandedKnots(3, expandedKnots).WithControlPoints(control)
+}
+
+// NewPCHIP creates a cubic B-spline that interpolates the points (x[i], y[i]) using the Piecewise Cubic Hermite
+// Interpolating Polynomial (PCHIP): the slopes at each point are chosen with the Fritsch-Carlson method,
+// so that the interpolant is monotone wherever the data is monotone, and it doesn't overshoot the data.
+//
+// It's the same interpolant as in SciPy's `PchipInterpolator` or Matlab's `pchip`. See NewHermite for details
+// on the returned B-spline.
+func NewPCHIP(x, y []float64) *BSpline {
+	if len(y) != len(x) {
+		panicf(ErrInvalidArgument, "bsplines.NewPCHIP requires the same number of x (%d) and y (%d)", len(x), len(y))
+	}
+	return NewHermite(x, y, pchipSlopes(x, y))
+}
+
+// pchipSlopes calculates the slopes of the PCHIP interpolant at each point.
+func pchipSlopes(x, y []float64) []float64 {
+	n := len(x)
+	slopes := make([]float64, n)
+	if n < 2 {
+		return slopes
+	}
+	h := make([]float64, n-1)
+	delta := make([]float64, n-1)
+	for ii := range n - 1 {
+		h[ii] = x[ii+1] - x[ii]
+		delta[ii] = (y[ii+1] - y[ii]) / h[ii]
+	}
+	if n == 2 {
+		slopes[0], slopes[1] = delta[0], delta[0]
+		return slopes
+	}
+
+	// Interior points: weighted harmonic mean of the neighbouring secants, or 0 at local extremes.
+	for ii := 1; ii < n-1; ii++ {
+		if delta[ii-1]*delta[ii] <= 0 {
+			continue
+		}
+		w1, w2 := 2*h[ii]+h[ii-1], h[ii]+2*h[ii-1]
+		slopes[ii] = (w1 + w2) / (w1/delta[ii-1] + w2/delta[ii])
+	}
+
+	// End points: one-sided three-point formula, adjusted to preserve the shape.
+	endSlope := func(h0, h1, delta0, delta1 float64) float64 {
+		d := ((2*h0+h1)*delta0 - h0*delta1) / (h0 + h1)
+		if math.Signbit(d) != math.Signbit(delta0) || d == 0 {
+			return 0
+		}
+		if math.Signbit(delta0) != math.Signbit(delta1) && math.Abs(d) > 3*math.Abs(delta0) {
+			return 3 * delta0
+		}
+		return d
+	}
+	slopes[0] = endSlope(h[0], h[1], delta[0], delta[1])
+	slopes[n-1] = endSlope(h[n-2], h[n-3], delta[n-2], delta[n-3])
+	return slopes
+}
+
+// ToHermite converts the B-spline to a piecewise cubic Hermite representation: it returns the distinct knots x,
+// and the values y and derivatives slopes of the B-spline at each of them.
+//
+// The conversion is exact (NewHermite(x, y, slopes) reproduces the curve) if the degree is at most 3 and
+// the B-spline is C¹ at the knots. Otherwise, it's the Hermite interpolant of the B-spline. At discontinuities,
+// the values and derivatives are taken from the right, except for the last knot.
+//
+// The control points must be set.
+func (b *BSpline) ToHermite() (x, y, slopes []float64) {
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.ToHermite() require control points to be set using BSpline.WithControlPoints()")
+	}
+	x, _ = b.KnotMultiplicities()
+	y = make([]float64, len(x))
+	slopes = make([]float64, len(x))
+	for ii, knot := range x {
+		y[ii], slopes[ii] = b.evaluateSpanWithGradient(b.SpanIndex(knot), knot)
+	}
+	return
+}
@@ -0,0 +1,62 @@
+package bsplines
+
+import (
+	"math"
+)
+
+// intervalSubdivisionLevels is the number of levels of Subdivide used by EvaluateInterval to tighten the bounds.
+const intervalSubdivisionLevels = 2
+
+// EvaluateInterval returns guaranteed bounds lo <= f(x) <= hi of the B-spline f for all x in [xlo, xhi], e.g. for
+// verification or branch-and-bound algorithms.
+//
+// The part of the interval within the domain is trimmed with SplitAt and subdivided (see Subdivide): the
+// B-spline is within the convex hull of the resulting control points, and the bounds are their minimum and maximum.
+// They are conservative, but they get tight quickly as the interval shrinks. The extrapolated parts are monotone
+// (or constant), so they are bounded by their values at their ends.
+//
+// The control points must be set.
+func (b *BSpline) EvaluateInterval(xlo, xhi float64) (lo, hi float64) {
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.EvaluateInterval() require control points to be set using BSpline.WithControlPoints()")
+	}
+	if !(xlo <= xhi) {
+		panicf(ErrInvalidArgument, "BSpline.EvaluateInterval(xlo=%g, xhi=%g) requires xlo <= xhi", xlo, xhi)
+	}
+	lo, hi = math.Inf(1), math.Inf(-1)
+	include := func(value float64) {
+		lo, hi = min(lo, value), max(hi, value)
+	}
+	domainMin, domainMax := b.Domain()
+	if xlo < domainMin {
+		include(b.extrapolate(xlo))
+		include(b.extrapolate(min(xhi, math.Nextafter(domainMin, math.Inf(-1)))))
+	}
+	if xhi >= domainMax {
+		include(b.extrapolate(xhi))
+		include(b.extrapolate(max(xlo, domainMax)))
+	}
+
+	// Part within the domain.
+	start, end := max(xlo, domainMin), min(xhi, domainMax)
+	switch {
+	case start > end:
+		return
+	case start == end:
+		if start < domainMax {
+			include(b.Evaluate(start))
+		}
+		return
+	}
+	piece := b
+	if start > domainMin {
+		_, piece = piece.SplitAt(start)
+	}
+	if end < domainMax {
+		piece, _ = piece.SplitAt(end)
+	}
+	for _, value := range piece.Subdivide(intervalSubdivisionLevels).controlPoints {
+		include(value)
+	}
+	return
+}
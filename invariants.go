@@ -0,0 +1,84 @@
+package bsplines
+
+import (
+	"fmt"
+	"math"
+)
+
+// Invariant identifies one of the properties of the B-spline basis functions checked by [BSpline.CheckInvariants].
+type Invariant string
+
+const (
+	// PartitionOfUnity is the property that the basis functions sum to 1 everywhere in the domain.
+	PartitionOfUnity Invariant = "partition of unity"
+
+	// NonNegativity is the property that the basis functions are never negative.
+	NonNegativity Invariant = "non-negativity"
+
+	// LocalSupport is the property that the basis function `i` of degree `p` is zero outside the
+	// interval `[knots[i], knots[i+p+1])` of the expanded knots.
+	LocalSupport Invariant = "local support"
+)
+
+// InvariantViolation describes one point where an invariant doesn't hold, see [BSpline.CheckInvariants].
+type InvariantViolation struct {
+	Invariant Invariant
+
+	// X where the invariant was violated.
+	X float64
+
+	// ControlPointIdx is the index of the basis function that violated the invariant, or -1 if
+	// the invariant is about all basis functions (PartitionOfUnity).
+	ControlPointIdx int
+
+	// Value is the offending value: the sum of the basis functions for PartitionOfUnity, or the value of the basis
+	// function otherwise.
+	Value float64
+}
+
+// String implements fmt.Stringer.
+func (v InvariantViolation) String() string {
+	if v.ControlPointIdx < 0 {
+		return fmt.Sprintf("%s violated at x=%g: value=%g", v.Invariant, v.X, v.Value)
+	}
+	return fmt.Sprintf("%s violated at x=%g by basis function #%d: value=%g", v.Invariant, v.X, v.ControlPointIdx, v.Value)
+}
+
+// CheckInvariants checks the properties of the basis functions over numSamples points evenly spaced over the
+// domain, and returns the list of violations (with a tolerance of tol) found, or nil if all invariants hold.
+//
+// It checks [PartitionOfUnity], [NonNegativity] and [LocalSupport]. It uses [BSpline.BasisFunction], and doesn't
+// require the control points to be set. It's useful to validate custom knot vectors.
+func (b *BSpline) CheckInvariants(numSamples int, tol float64) []InvariantViolation {
+	var violations []InvariantViolation
+	for _, x := range b.invariantSamples(numSamples) {
+		sum := 0.0
+		for ii := range b.NumControlPoints() {
+			value := b.BasisFunction(ii, b.degree, x)
+			sum += value
+			if value < -tol {
+				violations = append(violations, InvariantViolation{NonNegativity, x, ii, value})
+			}
+			inSupport := x >= b.expandedKnots[ii] && x < b.expandedKnots[ii+b.degree+1]
+			if !inSupport && math.Abs(value) > tol {
+				violations = append(violations, InvariantViolation{LocalSupport, x, ii, value})
+			}
+		}
+		if math.Abs(sum-1.0) > tol {
+			violations = append(violations, InvariantViolation{PartitionOfUnity, x, -1, sum})
+		}
+	}
+	return violations
+}
+
+// invariantSamples returns numSamples points evenly spaced over the domain, excluding the last knot, where the
+// B-spline is extrapolated.
+func (b *BSpline) invariantSamples(numSamples int) []float64 {
+	numSamples = max(numSamples, 1)
+	first, last := b.Domain()
+	xs := make([]float64, numSamples)
+	for ii := range numSamples {
+		xs[ii] = first + (last-first)*float64(ii)/float64(numSamples)
+	}
+	return xs
+}
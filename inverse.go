@@ -0,0 +1,83 @@
+package bsplines
+
+import (
+	"fmt"
+	"math"
+)
+
+// inverseSamplesPerControlPoint is the number of samples of the inverse function used to fit each control point
+// in BSpline.Inverse.
+const inverseSamplesPerControlPoint = 20
+
+// Inverse returns a B-spline g, with the same degree and numControlPoints evenly spaced control points, that
+// approximates the inverse function of the B-spline f over its domain: `g(f(x)) ≈ x`. It's used, for instance, to
+// convert a forward calibration curve into a decoding (or quantile) transform.
+//
+// The domain of g is the range of f over its domain. If f uses [ExtrapolateLinear], g extrapolates linearly too, with
+// the end slopes of its own fit: the inverse of a linear tail of slope s is a line of slope 1/s, so the tails of g
+// approximate the inverse of the tails of f only as well as the fit matches 1/s at the ends -- and not at all if an
+// end slope of f is 0, where the inverse has a vertical tangent. Otherwise g extrapolates as constant, so values
+// beyond the range of f map to the ends of its domain.
+//
+// It returns an error if f is not strictly monotone over its domain, which is verified by sampling it.
+// The control points must be set.
+func (b *BSpline) Inverse(numControlPoints int) (*BSpline, error) {
+	if len(b.controlPoints) == 0 {
+		return nil, fmt.Errorf("BSpline.Inverse() require control points to be set using BSpline.WithControlPoints(): %w", ErrControlPointsNotSet)
+	}
+	if numControlPoints < b.degree+1 {
+		return nil, fmt.Errorf("BSpline.Inverse(numControlPoints=%d) requires numControlPoints >= degree+1 (%d): %w", numControlPoints, b.degree+1, ErrControlPointCount)
+	}
+
+	// Check strict monotonicity, sampling the B-spline (including the end of the domain).
+	domainMin, domainMax := b.Domain()
+	basis := make([]float64, b.degree+1)
+	evalClosed := func(x float64) float64 {
+		return b.evaluateSpan(b.SpanIndex(x), x, basis)
+	}
+	numSamples := inverseSamplesPerControlPoint * max(numControlPoints, b.NumControlPoints())
+	first, last := evalClosed(domainMin), evalClosed(domainMax)
+	increasing := last > first
+	previous := first
+	for ii := 1; ii <= numSamples; ii++ {
+		x := domainMin + (domainMax-domainMin)*float64(ii)/float64(numSamples)
+		value := evalClosed(x)
+		if value == previous || (value > previous) != increasing {
+			return nil, fmt.Errorf("BSpline.Inverse() requires a strictly monotone B-spline, but f(%g)=%g and f(%g)=%g",
+				domainMin+(domainMax-domainMin)*float64(ii-1)/float64(numSamples), previous, x, value)
+		}
+		previous = value
+	}
+
+	// Sample the inverse function evenly in its domain, solving f(x)=y with bisection.
+	rangeMin, rangeMax := min(first, last), max(first, last)
+	ys := make([]float64, numSamples+1)
+	xs := make([]float64, numSamples+1)
+	for ii := range ys {
+		y := rangeMin + (rangeMax-rangeMin)*float64(ii)/float64(numSamples)
+		low, high := domainMin, domainMax
+		for range 100 {
+			mid := (low + high) / 2
+			if (evalClosed(mid) < y) == increasing {
+				low = mid
+			} else {
+				high = mid
+			}
+			if high-low <= 1e-15*math.Max(1, math.Abs(mid)) {
+				break
+			}
+		}
+		ys[ii], xs[ii] = y, (low+high)/2
+	}
+
+	knots := make([]float64, numControlPoints-b.degree+1)
+	for ii := range knots {
+		knots[ii] = rangeMin + (rangeMax-rangeMin)*float64(ii)/float64(len(knots)-1)
+	}
+	inverse := New(b.degree, knots)
+	inverse.WithControlPoints(inverse.FitControlPoints(ys, xs))
+	if b.extrapolation == ExtrapolateLinear {
+		inverse.WithExtrapolation(ExtrapolateLinear)
+	}
+	return inverse, nil
+}
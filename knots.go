@@ -0,0 +1,53 @@
+package bsplines
+
+import (
+	"slices"
+)
+
+// MergeKnots returns the union of two sorted knot vectors, with multiplicities: each distinct value is repeated the
+// maximum number of times it appears in either a or b. It's the smallest knot vector that contains both, e.g.:
+// MergeKnots([0, 0, 1, 2], [0, 1, 1, 3]) = [0, 0, 1, 1, 2, 3].
+//
+// It works both on knots and on expanded knots (see BSpline.ExpandedKnots).
+func MergeKnots(a, b []float64) []float64 {
+	merged := make([]float64, 0, len(a)+len(b))
+	ii, jj := 0, 0
+	for ii < len(a) || jj < len(b) {
+		switch {
+		case jj >= len(b) || (ii < len(a) && a[ii] < b[jj]):
+			merged = append(merged, a[ii])
+			ii++
+		case ii >= len(a) || b[jj] < a[ii]:
+			merged = append(merged, b[jj])
+			jj++
+		default:
+			// Same value: counted once.
+			merged = append(merged, a[ii])
+			ii++
+			jj++
+		}
+	}
+	return merged
+}
+
+// CommonRefinement returns the knots (not expanded) that refine the knots of all the given B-splines: the sorted
+// union of their knots. The B-splines must have the same domain.
+//
+// The control points of each B-spline can then be mapped to the common knots with BSpline.RefineControlPoints,
+// which is the base for combining B-splines, like in Average.
+func CommonRefinement(splines ...*BSpline) []float64 {
+	if len(splines) == 0 {
+		panicf(ErrInvalidArgument, "bsplines.CommonRefinement requires at least one B-spline")
+	}
+	domainMin, domainMax := splines[0].Domain()
+	var knots []float64
+	for ii, b := range splines {
+		bMin, bMax := b.Domain()
+		if bMin != domainMin || bMax != domainMax {
+			panicf(ErrIncompatibleSplines, "bsplines.CommonRefinement requires B-splines with the same domain: spline #0 has domain [%g, %g], "+
+				"but spline #%d has domain [%g, %g]", domainMin, domainMax, ii, bMin, bMax)
+		}
+		knots = MergeKnots(knots, b.Knots())
+	}
+	return slices.Clip(knots)
+}
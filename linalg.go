@@ -0,0 +1,138 @@
+package bsplines
+
+import (
+	"github.com/gomlx/exceptions"
+	"math"
+)
+
+// solveLinearSystem solves `a * x = b` for a square matrix a, using Gaussian elimination with partial pivoting.
+// The inputs are not changed.
+//
+// It panics if the matrix is singular.
+func solveLinearSystem(a [][]float64, b []float64) []float64 {
+	return solveLinearSystems(a, [][]float64{b})[0]
+}
+
+// solveLinearSystems is like solveLinearSystem, but it solves `a * x = b` for each of the right-hand sides bs, sharing
+// the elimination of a. It returns one solution per right-hand side.
+func solveLinearSystems(a [][]float64, bs [][]float64) [][]float64 {
+	n := len(a)
+	numRHS := len(bs)
+	m := make([][]float64, n)
+	for ii := range n {
+		m[ii] = make([]float64, n+numRHS)
+		copy(m[ii], a[ii])
+		for k, b := range bs {
+			m[ii][n+k] = b[ii]
+		}
+	}
+	for col := range n {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if m[pivot][col] == 0 {
+			exceptions.Panicf("bsplines: singular linear system (column %d)", col)
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		for row := col + 1; row < n; row++ {
+			factor := m[row][col] / m[col][col]
+			if factor == 0 {
+				continue
+			}
+			for jj := col; jj < n+numRHS; jj++ {
+				m[row][jj] -= factor * m[col][jj]
+			}
+		}
+	}
+	xs := make([][]float64, numRHS)
+	for k := range xs {
+		x := make([]float64, n)
+		for row := n - 1; row >= 0; row-- {
+			sum := m[row][n+k]
+			for jj := row + 1; jj < n; jj++ {
+				sum -= m[row][jj] * x[jj]
+			}
+			x[row] = sum / m[row][row]
+		}
+		xs[k] = x
+	}
+	return xs
+}
+
+// newMatrix returns a zero-initialized matrix with the given number of rows and columns.
+func newMatrix(rows, cols int) [][]float64 {
+	m := make([][]float64, rows)
+	for ii := range m {
+		m[ii] = make([]float64, cols)
+	}
+	return m
+}
+
+// gaussLegendre returns the n nodes and weights of the Gauss-Legendre quadrature over the interval [-1, 1].
+// It integrates exactly polynomials of degree up to `2n-1`.
+func gaussLegendre(n int) (nodes, weights []float64) {
+	nodes, weights = make([]float64, n), make([]float64, n)
+	for ii := range n {
+		// Initial guess (Tricomi), refined with Newton's method on the Legendre polynomial P_n.
+		x := math.Cos(math.Pi * (float64(ii) + 0.75) / (float64(n) + 0.5))
+		var derivative float64
+		for range 100 {
+			// p1 = P_n(x), p0 = P_{n-1}(x).
+			p0, p1 := 1.0, x
+			for k := 2; k <= n; k++ {
+				p0, p1 = p1, (float64(2*k-1)*x*p1-float64(k-1)*p0)/float64(k)
+			}
+			// P'_n(x) = n * (x*P_n(x) - P_{n-1}(x)) / (x² - 1)
+			derivative = float64(n) * (x*p1 - p0) / (x*x - 1)
+			delta := p1 / derivative
+			x -= delta
+			if math.Abs(delta) < 1e-15 {
+				break
+			}
+		}
+		nodes[n-1-ii] = x
+		weights[n-1-ii] = 2 / ((1 - x*x) * derivative * derivative)
+	}
+	return
+}
+
+// matMul returns the matrix product `a * b`.
+func matMul(a, b [][]float64) [][]float64 {
+	result := newMatrix(len(a), len(b[0]))
+	for ii, row := range a {
+		for kk, aValue := range row {
+			if aValue == 0 {
+				continue
+			}
+			for jj, bValue := range b[kk] {
+				result[ii][jj] += aValue * bValue
+			}
+		}
+	}
+	return result
+}
+
+// matVecMul returns the product `a * v`.
+func matVecMul(a [][]float64, v []float64) []float64 {
+	result := make([]float64, len(a))
+	for ii, row := range a {
+		for jj, value := range row {
+			result[ii] += value * v[jj]
+		}
+	}
+	return result
+}
+
+// transpose returns the transposed matrix.
+func transpose(a [][]float64) [][]float64 {
+	result := newMatrix(len(a[0]), len(a))
+	for ii, row := range a {
+		for jj, value := range row {
+			result[jj][ii] = value
+		}
+	}
+	return result
+}
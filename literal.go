@@ -0,0 +1,133 @@
+package bsplines
+
+import (
+	"fmt"
+	"github.com/gomlx/exceptions"
+	"strconv"
+	"strings"
+)
+
+// FormatLiteral returns the B-spline encoded in a compact single-line text format (the "spline literal"), that can
+// be embedded in flag values, URLs or test tables:
+//
+//	<degree>;<knots>;<control points>;<extrapolation>[;closed]
+//
+// With the knots (see Knots, repeated knots included) and control points separated by commas, and the extrapolation
+// as in ExtrapolationType.String. E.g.: `2;0,0.5,1;0,1,0.5,2;ExtrapolateLinear`. The control points are empty if they
+// are not set, and the optional last field `closed` is present if the B-spline has a closed domain (see
+// WithClosedDomain).
+//
+// Numbers are formatted in the shortest form that parses back to the same value, independent of the locale, so
+// ParseLiteral returns an identical B-spline.
+//
+// Only clamped B-splines (see IsClamped), like the ones created with New, can be encoded: it panics with
+// ErrInvalidArgument otherwise. Use the JSON encoding (see MarshalJSON) for the others.
+func (b *BSpline) FormatLiteral() string {
+	if !b.IsClamped() {
+		panicf(ErrInvalidArgument, "BSpline.FormatLiteral() requires a clamped B-spline, got expanded knots %v", b.expandedKnots)
+	}
+	var sb strings.Builder
+	sb.WriteString(strconv.Itoa(b.degree))
+	sb.WriteByte(';')
+	writeFloats(&sb, b.Knots())
+	sb.WriteByte(';')
+	writeFloats(&sb, b.controlPoints)
+	sb.WriteByte(';')
+	sb.WriteString(b.extrapolation.String())
+	if b.closedDomain {
+		sb.WriteString(";" + literalClosed)
+	}
+	return sb.String()
+}
+
+// literalClosed is the optional last field of the spline literal for B-splines with a closed domain.
+const literalClosed = "closed"
+
+// writeFloats writes the values separated by commas.
+func writeFloats(sb *strings.Builder, values []float64) {
+	for ii, value := range values {
+		if ii > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	}
+}
+
+// ParseLiteral parses a B-spline encoded with FormatLiteral. Spaces around the fields and numbers are ignored.
+//
+// It returns an error if the literal is malformed or it doesn't define a valid B-spline.
+func ParseLiteral(literal string) (b *BSpline, err error) {
+	fields := strings.Split(literal, ";")
+	if len(fields) != 4 && len(fields) != 5 {
+		return nil, fmt.Errorf("bsplines: spline literal %q must have 4 or 5 fields separated by ';' (degree;knots;control points;extrapolation[;closed]), got %d: %w",
+			literal, len(fields), ErrInvalidArgument)
+	}
+	closed := len(fields) == 5
+	if closed && strings.TrimSpace(fields[4]) != literalClosed {
+		return nil, fmt.Errorf("bsplines: spline literal %q has invalid last field %q, only %q is accepted: %w",
+			literal, fields[4], literalClosed, ErrInvalidArgument)
+	}
+	degree, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+	if err != nil || degree < 0 {
+		return nil, fmt.Errorf("bsplines: spline literal %q has invalid degree %q: %w", literal, fields[0], ErrInvalidArgument)
+	}
+	knots, err := parseFloats(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("bsplines: spline literal %q has invalid knots: %w", literal, err)
+	}
+	controlPoints, err := parseFloats(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("bsplines: spline literal %q has invalid control points: %w", literal, err)
+	}
+	extrapolation, err := parseExtrapolation(strings.TrimSpace(fields[3]))
+	if err != nil {
+		return nil, fmt.Errorf("bsplines: spline literal %q: %w", literal, err)
+	}
+	err = exceptions.TryCatch[error](func() {
+		b = New(degree, knots).WithExtrapolation(extrapolation).WithClosedDomain(closed)
+		if len(controlPoints) > 0 {
+			b.WithControlPoints(controlPoints)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bsplines: spline literal %q is not a valid B-spline: %w", literal, err)
+	}
+	return b, nil
+}
+
+// parseFloats parses comma separated numbers. An empty (or blank) string returns nil.
+func parseFloats(text string) ([]float64, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	parts := strings.Split(text, ",")
+	values := make([]float64, len(parts))
+	for ii, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", err, ErrInvalidArgument)
+		}
+		values[ii] = value
+	}
+	return values, nil
+}
+
+// MarshalText implements encoding.TextMarshaler, using the spline literal format, see FormatLiteral. It returns an
+// error for B-splines that are not clamped.
+//
+// Notice that JSON uses MarshalJSON instead.
+func (b *BSpline) MarshalText() (text []byte, err error) {
+	err = exceptions.TryCatch[error](func() { text = []byte(b.FormatLiteral()) })
+	return
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, using the spline literal format, see ParseLiteral. So a
+// B-spline can be used directly as a flag, with flag.TextVar.
+func (b *BSpline) UnmarshalText(text []byte) error {
+	parsed, err := ParseLiteral(string(text))
+	if err != nil {
+		return err
+	}
+	*b = *parsed
+	return nil
+}
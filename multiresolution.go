@@ -0,0 +1,95 @@
+package bsplines
+
+import (
+	"math"
+	"slices"
+)
+
+// Decomposition is a multiresolution decomposition of a B-spline, see [BSpline.Decompose].
+type Decomposition struct {
+	// Coarse is the approximation of the original B-spline on the coarsest grid.
+	Coarse *BSpline
+
+	// Details holds, for each level from the coarsest to the finest, the detail function lost when moving from
+	// that level's grid to the next coarser one: it's represented as a B-spline over the finer grid
+	// of the level, with ExtrapolateZero.
+	Details []*BSpline
+}
+
+// Decompose the B-spline into a coarse approximation plus details for the given number of levels.
+// Each level halves the number of knot intervals, by dropping every other interior knot (the last knot is always
+// kept).
+//
+// The coarse approximation of each level is the L2-orthogonal projection of the finer B-spline onto the coarser
+// space of B-splines, and the details are the residual (orthogonal to the coarser space), as in semi-orthogonal
+// B-spline wavelets. The details are kept in the finer B-spline basis.
+//
+// The original B-spline is reconstructed exactly (up to rounding errors) with Decomposition.Reconstruct.
+// Dropping small details (see Decomposition.Threshold) can be used for compression and denoising.
+//
+// The control points must be set, and it panics if there are not enough knots for the number of levels.
+func (b *BSpline) Decompose(levels int) *Decomposition {
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.Decompose() require control points to be set using BSpline.WithControlPoints()")
+	}
+	d := &Decomposition{Details: make([]*BSpline, levels)}
+	fine := b
+	for level := levels - 1; level >= 0; level-- {
+		fineKnots := fine.Knots()
+		if len(fineKnots) < 3 {
+			panicf(ErrNotEnoughKnots, "BSpline.Decompose(levels=%d) requires more knots, the B-spline only has %d knots", levels, len(b.Knots()))
+		}
+		coarseKnots := make([]float64, 0, len(fineKnots)/2+1)
+		for ii := 0; ii < len(fineKnots)-1; ii += 2 {
+			coarseKnots = append(coarseKnots, fineKnots[ii])
+		}
+		coarseKnots = append(coarseKnots, at(fineKnots, -1))
+		coarse := New(b.degree, coarseKnots).WithExtrapolation(b.extrapolation).WithClosedDomain(b.closedDomain)
+
+		// Least squares in the L2 norm: (Pᵀ G P) c = Pᵀ G f
+		p := coarse.RefinementMatrix(fineKnots)
+		g := fine.gramMatrix()
+		gp := matMul(g, p)
+		normal := matMul(transpose(p), gp)
+		rhs := matVecMul(transpose(gp), fine.controlPoints)
+		coarse.WithControlPoints(solveLinearSystem(normal, rhs))
+
+		projected := matVecMul(p, coarse.controlPoints)
+		details := make([]float64, len(projected))
+		for ii := range details {
+			details[ii] = fine.controlPoints[ii] - projected[ii]
+		}
+		d.Details[level] = New(b.degree, slices.Clone(fineKnots)).WithExtrapolation(ExtrapolateZero).WithControlPoints(details)
+		fine = coarse
+	}
+	d.Coarse = fine
+	return d
+}
+
+// Reconstruct the original B-spline from the coarse approximation and the details.
+func (d *Decomposition) Reconstruct() *BSpline {
+	current := d.Coarse
+	for _, detail := range d.Details {
+		control := current.RefineControlPoints(detail.Knots(), current.controlPoints)
+		for ii := range control {
+			control[ii] += detail.controlPoints[ii]
+		}
+		current = New(current.degree, slices.Clone(detail.Knots())).WithExtrapolation(d.Coarse.extrapolation).
+			WithClosedDomain(d.Coarse.closedDomain).WithControlPoints(control)
+	}
+	return current
+}
+
+// Threshold sets to zero the detail coefficients whose absolute value is below tol, and returns the number of
+// coefficients zeroed. It's used to compress or denoise the B-spline before reconstructing it.
+func (d *Decomposition) Threshold(tol float64) (numZeroed int) {
+	for _, detail := range d.Details {
+		for ii, value := range detail.controlPoints {
+			if value != 0 && math.Abs(value) < tol {
+				detail.controlPoints[ii] = 0
+				numZeroed++
+			}
+		}
+	}
+	return
+}
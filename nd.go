@@ -0,0 +1,122 @@
+package bsplines
+
+import (
+	"math"
+)
+
+// WithControlPointsND sets vector-valued control points, shaped `[NumControlPoints()][dim]`, so the B-spline can be
+// evaluated as a parametric curve in R^dim with EvaluateND -- e.g. 2D or 3D paths -- sharing the knots, degree and
+// configuration, instead of keeping one B-spline per coordinate in sync. See also Curve.
+//
+// They are independent of the scalar control points (see WithControlPoints), and all points must have the same
+// dimension. Derivative and Refine carry them along to the new B-spline. The slices are not copied.
+// It returns itself, so configuration calls can be cascaded.
+func (b *BSpline) WithControlPointsND(controlPoints [][]float64) *BSpline {
+	numControlPoints := b.NumControlPoints()
+	if len(controlPoints) != numControlPoints {
+		panicf(ErrControlPointCount, "BSpline.WithControlPointsND() expected %d control points (== `len(knots)+degree-1`), but got %d instead",
+			numControlPoints, len(controlPoints))
+	}
+	for ii, point := range controlPoints {
+		if len(point) != len(controlPoints[0]) || len(point) == 0 {
+			panicf(ErrInvalidArgument, "BSpline.WithControlPointsND() requires all control points to have the same non-zero dimension, "+
+				"controlPoints[0] has dimension %d, controlPoints[%d] has %d", len(controlPoints[0]), ii, len(point))
+		}
+	}
+	b.controlPointsND = controlPoints
+	return b
+}
+
+// ControlPointsND returns the vector-valued control points set with WithControlPointsND, or nil.
+func (b *BSpline) ControlPointsND() [][]float64 {
+	return b.controlPointsND
+}
+
+// EvaluateND evaluates the B-spline with the vector-valued control points (see WithControlPointsND) at x, and
+// returns a point with one value per dimension. Outside the domain, each dimension is extrapolated as configured.
+func (b *BSpline) EvaluateND(x float64) []float64 {
+	if len(b.controlPointsND) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.EvaluateND() require control points to be set using BSpline.WithControlPointsND()")
+	}
+	if math.IsNaN(x) {
+		return b.nanND()
+	}
+	first, basis := b.BasisFunctionsAt(x) // Also the weights of the extrapolation.
+	return b.combineND(first, basis)
+}
+
+// EvaluateNDWithGradient is like EvaluateND, but it also returns the derivative of each dimension with respect to x:
+// for a curve, its tangent.
+func (b *BSpline) EvaluateNDWithGradient(x float64) (point, derivative []float64) {
+	if len(b.controlPointsND) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.EvaluateNDWithGradient() require control points to be set using BSpline.WithControlPointsND()")
+	}
+	if math.IsNaN(x) {
+		return b.nanND(), b.nanND()
+	}
+	first, values, derivatives := b.basisWithDerivativesAt(x)
+	return b.combineND(first, values), b.combineND(first, derivatives)
+}
+
+// nanND returns a point with all dimensions set to NaN.
+func (b *BSpline) nanND() []float64 {
+	point := make([]float64, len(b.controlPointsND[0]))
+	for d := range point {
+		point[d] = math.NaN()
+	}
+	return point
+}
+
+// combineND returns `Σ weights[r] * controlPointsND[first+r]`.
+func (b *BSpline) combineND(first int, weights []float64) []float64 {
+	point := make([]float64, len(b.controlPointsND[0]))
+	for r, weight := range weights {
+		if weight == 0 {
+			continue
+		}
+		for d, value := range b.controlPointsND[first+r] {
+			point[d] += weight * value
+		}
+	}
+	return point
+}
+
+// coordinateControlPoints returns the control points of the dimension d of the vector-valued control points.
+func (b *BSpline) coordinateControlPoints(d int) []float64 {
+	control := make([]float64, len(b.controlPointsND))
+	for ii, point := range b.controlPointsND {
+		control[ii] = point[d]
+	}
+	return control
+}
+
+// mapControlPointsND applies the linear map fn to the control points of each dimension of the vector-valued control
+// points, and returns the resulting vector-valued control points. It's used to carry them along operations like
+// Derivative and Refine.
+func (b *BSpline) mapControlPointsND(fn func(control []float64) []float64) [][]float64 {
+	var mapped [][]float64
+	for d := range len(b.controlPointsND[0]) {
+		control := fn(b.coordinateControlPoints(d))
+		if mapped == nil {
+			mapped = make([][]float64, len(control))
+			for ii := range mapped {
+				mapped[ii] = make([]float64, len(b.controlPointsND[0]))
+			}
+		}
+		for ii, value := range control {
+			mapped[ii][d] = value
+		}
+	}
+	return mapped
+}
+
+// Curve returns the parametric Curve defined by the vector-valued control points (see WithControlPointsND): e.g.
+// for its tangents, arc length, clearance or NURBS weights.
+//
+// The curve is backed by b, which must not be changed afterward.
+func (b *BSpline) Curve() *Curve {
+	if len(b.controlPointsND) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.Curve() require control points to be set using BSpline.WithControlPointsND()")
+	}
+	return &Curve{spline: b}
+}
@@ -0,0 +1,43 @@
+package bsplines
+
+// PenaltyMatrix returns the matrix P shaped `[NumControlPoints()][NumControlPoints()]` with the integrals over the
+// domain of the products of the derivatives of order k of the basis functions: `P[i][j] = ∫ B_i^(k)(x) B_j^(k)(x) dx`.
+//
+// For control points c, `cᵀ P c` is the roughness penalty `∫ (f^(k)(x))² dx` of the B-spline f, used by
+// smoothing splines (k=2) and P-splines fitters, or as a regularizer. PenaltyMatrix(0) is the Gram matrix of the
+// basis functions.
+//
+// The matrix is banded: entries with `|i-j| > degree` are zero. It's calculated exactly with Gauss-Legendre quadrature
+// on each knot span. It panics if order is larger than the degree.
+func (b *BSpline) PenaltyMatrix(order int) [][]float64 {
+	if order < 0 || order > b.degree {
+		panicf(ErrInvalidArgument, "BSpline.PenaltyMatrix(%d) requires 0 <= order <= degree (%d)", order, b.degree)
+	}
+	if order == 0 {
+		return b.gramMatrix()
+	}
+
+	// P = Dᵀ G D, where D maps the control points to the ones of the derivative, and G is the Gram matrix of the
+	// derivative basis functions.
+	d := b.derivativeMatrix(order)
+	g := newFromExpandedKnots(b.degree-order, b.expandedKnots[order:len(b.expandedKnots)-order]).gramMatrix()
+	return matMul(transpose(d), matMul(g, d))
+}
+
+// derivativeMatrix returns the matrix D shaped `[NumControlPoints()-order][NumControlPoints()]` that maps the
+// control points of b to the control points of its derivative of the given order (see BSpline.DerivativeN).
+func (b *BSpline) derivativeMatrix(order int) [][]float64 {
+	numControlPoints := b.NumControlPoints()
+	d := newMatrix(numControlPoints-order, numControlPoints)
+	unit := make([]float64, numControlPoints)
+	unitSpline := newFromExpandedKnots(b.degree, b.expandedKnots)
+	for jj := range numControlPoints {
+		unit[jj] = 1
+		column := unitSpline.WithControlPoints(unit).DerivativeWithExtrapolation(order, ExtrapolateZero).ControlPoints()
+		unit[jj] = 0
+		for ii, value := range column {
+			d[ii][jj] = value
+		}
+	}
+	return d
+}
@@ -0,0 +1,165 @@
+package bsplines
+
+import (
+	"math"
+)
+
+// Periodic is a closed (periodic) B-spline: the knots define one period, and the first and last control points
+// wrap around, so the B-spline and its derivatives (up to degree-1) are continuous across the seam. It's the natural
+// model for seasonality (daily, weekly) and angles, without the artifacts of clamped ends.
+//
+// Create it with NewPeriodic, and set the control points with WithControlPoints.
+type Periodic struct {
+	// bspline is the equivalent unclamped B-spline over one period, with the first degree control points repeated
+	// at the end.
+	bspline *BSpline
+	period  float64
+}
+
+// NewPeriodic creates a periodic B-spline of the given degree, with period `knots[len(knots)-1] - knots[0]`.
+// The knots must be strictly increasing, and have at least `degree+1` values.
+//
+// There is one control point per knot interval, so `len(knots)-1` control points.
+func NewPeriodic(degree int, knots []float64) *Periodic {
+	checkStrictlyIncreasing("bsplines.NewPeriodic", knots)
+	n := len(knots) - 1
+	if n < max(degree, 1) {
+		panicf(ErrNotEnoughKnots, "bsplines.NewPeriodic requires at least degree+1=%d knots, got %d", max(degree, 1)+1, len(knots))
+	}
+	period := at(knots, -1) - knots[0]
+	expandedKnots := make([]float64, 0, len(knots)+2*degree)
+	for jj := degree; jj >= 1; jj-- {
+		expandedKnots = append(expandedKnots, knots[n-jj]-period)
+	}
+	expandedKnots = append(expandedKnots, knots...)
+	for jj := 1; jj <= degree; jj++ {
+		expandedKnots = append(expandedKnots, knots[jj]+period)
+	}
+	return &Periodic{
+		bspline: newFromExpandedKnots(degree, expandedKnots),
+		period:  period,
+	}
+}
+
+// Degree of the B-spline.
+func (p *Periodic) Degree() int { return p.bspline.degree }
+
+// Knots of one period. Values must not be changed.
+func (p *Periodic) Knots() []float64 { return p.bspline.Knots() }
+
+// Period of the B-spline.
+func (p *Periodic) Period() float64 { return p.period }
+
+// NumControlPoints returns the number of control points, one per knot interval.
+func (p *Periodic) NumControlPoints() int {
+	return len(p.Knots()) - 1
+}
+
+// WithControlPoints sets the control points, there must be NumControlPoints() of them.
+// As in BSpline.WithControlPoints, it changes the B-spline, and it returns itself.
+func (p *Periodic) WithControlPoints(controlPoints []float64) *Periodic {
+	n := p.NumControlPoints()
+	if len(controlPoints) != n {
+		panicf(ErrControlPointCount, "Periodic.WithControlPoints() with %d knots, expected %d control points (== `len(knots)-1`), but got %d instead",
+			n+1, n, len(controlPoints))
+	}
+	wrapped := make([]float64, n+p.bspline.degree)
+	for ii := range wrapped {
+		wrapped[ii] = controlPoints[ii%n]
+	}
+	p.bspline.WithControlPoints(wrapped)
+	return p
+}
+
+// ControlPoints returns the control points, or nil if they were not set.
+func (p *Periodic) ControlPoints() []float64 {
+	if len(p.bspline.controlPoints) == 0 {
+		return nil
+	}
+	return p.bspline.controlPoints[:p.NumControlPoints()]
+}
+
+// BSpline returns the (unclamped) B-spline equal to the periodic one over the first period -- e.g. for its
+// derivatives or integral. Changing it changes p.
+func (p *Periodic) BSpline() *BSpline {
+	return p.bspline
+}
+
+// wrap maps x to the first period.
+func (p *Periodic) wrap(x float64) float64 {
+	domainMin, domainMax := p.bspline.Domain()
+	x = domainMin + math.Mod(x-domainMin, p.period)
+	if x < domainMin {
+		x += p.period
+	}
+	if x >= domainMax {
+		// Rounding errors.
+		x = domainMin
+	}
+	return x
+}
+
+// Evaluate the periodic B-spline at x, any value is valid. It returns NaN if x is NaN.
+func (p *Periodic) Evaluate(x float64) float64 {
+	return p.bspline.Evaluate(p.wrap(x))
+}
+
+// EvaluateWithGradient evaluates the periodic B-spline at x, and its derivative with respect to x.
+func (p *Periodic) EvaluateWithGradient(x float64) (value, dydx float64) {
+	return p.bspline.EvaluateWithGradient(p.wrap(x))
+}
+
+// EvaluateBatch evaluates the periodic B-spline at each of the xs.
+func (p *Periodic) EvaluateBatch(xs []float64) []float64 {
+	output := make([]float64, len(xs))
+	for ii, x := range xs {
+		output[ii] = p.Evaluate(x)
+	}
+	return output
+}
+
+// FitControlPoints returns the control points that best fit the data points (xs[i], ys[i]) in the least squares
+// sense, like BSpline.FitControlPoints, with the xs wrapped to the period: so data spanning several periods is
+// folded into one, as in seasonality models.
+//
+// Use it with WithControlPoints.
+func (p *Periodic) FitControlPoints(xs, ys []float64) []float64 {
+	normal, rhs := p.normalEquations("Periodic.FitControlPoints", xs, ys)
+	return solveLinearSystem(normal, rhs)
+}
+
+// FitWithReport fits the data points like FitControlPoints, and returns a new periodic B-spline with the same knots
+// and the fitted control points, along with a Report with the quality of the fit (see BSpline.FitWithReport).
+// The spans of the Report cover one period. p is not changed.
+func (p *Periodic) FitWithReport(xs, ys []float64) (*Periodic, *Report) {
+	normal, rhs := p.normalEquations("Periodic.FitWithReport", xs, ys)
+	fitted := NewPeriodic(p.bspline.degree, p.Knots()).WithControlPoints(solveLinearSystem(normal, rhs))
+	report := fitted.bspline.residualsReport(xs, ys, fitted.wrap)
+	report.EffectiveDegreesOfFreedom = hatMatrixTrace(normal, normal)
+	return fitted, report
+}
+
+// normalEquations returns the normal matrix and the right-hand side of the least squares fit of the data points,
+// with the xs wrapped to the period, see BSpline.normalEquations.
+func (p *Periodic) normalEquations(fnName string, xs, ys []float64) (normal [][]float64, rhs []float64) {
+	if len(xs) != len(ys) {
+		panicf(ErrInvalidArgument, "%s() requires the same number of xs (%d) and ys (%d)", fnName, len(xs), len(ys))
+	}
+	n := p.NumControlPoints()
+	normal = newMatrix(n, n)
+	rhs = make([]float64, n)
+	for ii, x := range xs {
+		if math.IsNaN(x) {
+			continue
+		}
+		first, basis := p.bspline.BasisFunctionsAt(p.wrap(x))
+		for r, br := range basis {
+			row := (first + r) % n
+			rhs[row] += br * ys[ii]
+			for s, bs := range basis {
+				normal[row][(first+s)%n] += br * bs
+			}
+		}
+	}
+	return normal, rhs
+}
@@ -0,0 +1,206 @@
+package bsplines
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gomlx/exceptions"
+	"slices"
+)
+
+// Affine is an affine transform `Scale*x + Offset` used by Pipeline.
+type Affine struct {
+	Scale  float64 `json:"scale"`
+	Offset float64 `json:"offset"`
+}
+
+// Apply the affine transform to x.
+func (a Affine) Apply(x float64) float64 {
+	return a.Scale*x + a.Offset
+}
+
+// Pipeline chains an affine transform of the input, a B-spline and an affine transform of the output, as
+// calibrations are usually deployed: `standardize → spline → rescale`.
+//
+// It can be serialized to/from JSON, see MarshalJSON and UnmarshalJSON.
+type Pipeline struct {
+	Input  Affine
+	Spline *BSpline
+	Output Affine
+}
+
+// NewPipeline creates a Pipeline with the B-spline and identity input and output transforms.
+// The control points of the B-spline must be set before evaluation.
+func NewPipeline(b *BSpline) *Pipeline {
+	return &Pipeline{
+		Input:  Affine{Scale: 1},
+		Spline: b,
+		Output: Affine{Scale: 1},
+	}
+}
+
+// WithInputTransform sets the affine transform `scale*x + offset` applied to the input before the B-spline.
+//
+// It returns itself so configuration calls can be cascaded.
+func (p *Pipeline) WithInputTransform(scale, offset float64) *Pipeline {
+	p.Input = Affine{Scale: scale, Offset: offset}
+	return p
+}
+
+// WithStandardizedInput sets the input transform to standardize the input, `(x - mean) / stdDev`.
+//
+// It returns itself so configuration calls can be cascaded.
+func (p *Pipeline) WithStandardizedInput(mean, stdDev float64) *Pipeline {
+	if stdDev == 0 {
+		panicf(ErrInvalidArgument, "Pipeline.WithStandardizedInput() requires stdDev != 0")
+	}
+	return p.WithInputTransform(1/stdDev, -mean/stdDev)
+}
+
+// WithOutputTransform sets the affine transform `scale*y + offset` applied to the output of the B-spline.
+//
+// It returns itself so configuration calls can be cascaded.
+func (p *Pipeline) WithOutputTransform(scale, offset float64) *Pipeline {
+	p.Output = Affine{Scale: scale, Offset: offset}
+	return p
+}
+
+// Evaluate the pipeline at x.
+func (p *Pipeline) Evaluate(x float64) float64 {
+	return p.Output.Apply(p.Spline.Evaluate(p.Input.Apply(x)))
+}
+
+// pipelineJSON is the serialized form of a Pipeline.
+type pipelineJSON struct {
+	Input  Affine   `json:"input"`
+	Spline *BSpline `json:"spline"`
+	Output Affine   `json:"output"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p *Pipeline) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&pipelineJSON{Input: p.Input, Spline: p.Spline, Output: p.Output})
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Besides a valid B-spline with its control points, it requires a non-zero
+// input scale and a non-empty domain, so decoded pipelines are usable -- e.g. by Registry and Store.
+func (p *Pipeline) UnmarshalJSON(data []byte) (err error) {
+	var decoded pipelineJSON
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	if decoded.Spline == nil {
+		return fmt.Errorf("bsplines.Pipeline JSON is missing the spline")
+	}
+	if len(decoded.Spline.controlPoints) == 0 {
+		return fmt.Errorf("bsplines.Pipeline JSON is missing the control points of the spline: %w", ErrControlPointsNotSet)
+	}
+	if decoded.Input.Scale == 0 {
+		return fmt.Errorf("bsplines.Pipeline JSON has an input transform with scale 0: %w", ErrInvalidArgument)
+	}
+	if domainMin, domainMax := decoded.Spline.Domain(); !(domainMin < domainMax) {
+		return fmt.Errorf("bsplines.Pipeline JSON has a spline with an empty domain [%g, %g]: %w", domainMin, domainMax, ErrOutOfDomain)
+	}
+	p.Input, p.Spline, p.Output = decoded.Input, decoded.Spline, decoded.Output
+	return nil
+}
+
+// bsplineJSON is the serialized form of a B-spline.
+type bsplineJSON struct {
+	Degree        int       `json:"degree"`
+	ExpandedKnots []float64 `json:"expanded_knots"`
+	ControlPoints []float64 `json:"control_points"`
+	Extrapolation string    `json:"extrapolation"`
+	ClosedDomain  bool      `json:"closed_domain,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler: a B-spline is serialized as an object with its degree, expanded knots,
+// control points, extrapolation and whether its domain is closed -- the same form used in a Pipeline. Unlike the
+// spline literal (see MarshalText), it also encodes B-splines that are not clamped.
+func (b *BSpline) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&bsplineJSON{
+		Degree:        b.degree,
+		ExpandedKnots: b.expandedKnots,
+		ControlPoints: b.controlPoints,
+		Extrapolation: b.extrapolation.String(),
+		ClosedDomain:  b.closedDomain,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler, see MarshalJSON. The control points are optional.
+//
+// It returns an error if the expanded knots are not sorted, if a knot is repeated more than degree+1 times, or if
+// an end is only partially clamped: each end knot must either be repeated degree+1 times (clamped) or not at all.
+func (b *BSpline) UnmarshalJSON(data []byte) (err error) {
+	var s bsplineJSON
+	if err = json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s.Degree < 0 || len(s.ExpandedKnots) < 2*s.Degree+2 {
+		return fmt.Errorf("bsplines: B-spline JSON has invalid degree %d for %d expanded knots", s.Degree, len(s.ExpandedKnots))
+	}
+	if err = checkExpandedKnots(s.Degree, s.ExpandedKnots); err != nil {
+		return fmt.Errorf("bsplines: B-spline JSON has invalid expanded knots %v: %w", s.ExpandedKnots, err)
+	}
+	extrapolation, err := parseExtrapolation(s.Extrapolation)
+	if err != nil {
+		return err
+	}
+	var decoded *BSpline
+	err = exceptions.TryCatch[error](func() {
+		decoded = newFromExpandedKnots(s.Degree, s.ExpandedKnots).WithExtrapolation(extrapolation).WithClosedDomain(s.ClosedDomain)
+		if len(s.ControlPoints) > 0 {
+			decoded.WithControlPoints(s.ControlPoints)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("bsplines: B-spline JSON is not a valid B-spline: %w", err)
+	}
+	*b = *decoded
+	return nil
+}
+
+// checkExpandedKnots returns an error if the expanded knots are not sorted, have a knot repeated more than degree+1
+// times, or an end knot repeated more than once but less than degree+1 times.
+func checkExpandedKnots(degree int, expandedKnots []float64) error {
+	if !slices.IsSorted(expandedKnots) {
+		return ErrKnotsNotSorted
+	}
+	multiplicity := 1
+	for ii := 1; ii < len(expandedKnots); ii++ {
+		if expandedKnots[ii] != expandedKnots[ii-1] {
+			multiplicity = 1
+			continue
+		}
+		multiplicity++
+		if multiplicity > degree+1 {
+			return fmt.Errorf("knot %g repeated more than degree+1=%d times: %w", expandedKnots[ii], degree+1, ErrInvalidArgument)
+		}
+	}
+	first, last := expandedKnots[0], at(expandedKnots, -1)
+	firstMultiplicity, lastMultiplicity := 1, 1
+	for ii := 1; ii <= degree; ii++ {
+		if expandedKnots[ii] == first {
+			firstMultiplicity++
+		}
+		if at(expandedKnots, -ii-1) == last {
+			lastMultiplicity++
+		}
+	}
+	for _, m := range []int{firstMultiplicity, lastMultiplicity} {
+		if m != 1 && m != degree+1 {
+			return fmt.Errorf("end knot repeated %d times, it must be clamped (repeated degree+1=%d times) or not repeated: %w",
+				m, degree+1, ErrInvalidArgument)
+		}
+	}
+	return nil
+}
+
+// parseExtrapolation returns the ExtrapolationType with the given name (as returned by its String method).
+func parseExtrapolation(name string) (ExtrapolationType, error) {
+	for e := ExtrapolateZero; e <= ExtrapolateLinear; e++ {
+		if e.String() == name {
+			return e, nil
+		}
+	}
+	return ExtrapolateZero, fmt.Errorf("bsplines: unknown extrapolation %q", name)
+}
@@ -0,0 +1,88 @@
+package plotly
+
+import (
+	grob "github.com/MetalBlueberry/go-plotly/graph_objects"
+	"github.com/gomlx/bsplines"
+	"github.com/gomlx/exceptions"
+)
+
+// PlotComparison plots the configured (original) B-spline against a refined (or refit) version of it, along with the
+// difference `refined - original`, to help with adaptive fitting workflows.
+//
+// Optionally, the data points used for fitting can be given in dataX and dataY (they must have the same length),
+// and they are plotted as markers. Leave them nil if there is no data to plot.
+//
+// Both B-splines must have their control points set. The x-range plotted is the one of the original B-spline, plus
+// the configured margin.
+//
+// The figure is annotated with the metadata of the refined B-spline, including its fit RMSE if data is given, see
+// Config.WithAnnotation.
+//
+// It returns an error if plotting failed for some reason.
+func (c *Config) PlotComparison(refined *bsplines.BSpline, dataX, dataY []float64) error {
+	return displayFig(c.comparisonFigure(refined, dataX, dataY))
+}
+
+// comparisonFigure builds the figure plotted by PlotComparison.
+func (c *Config) comparisonFigure(refined *bsplines.BSpline, dataX, dataY []float64) *grob.Fig {
+	if len(dataX) != len(dataY) {
+		exceptions.Panicf("plotly.PlotComparison() requires dataX and dataY to have the same length, got %d and %d",
+			len(dataX), len(dataY))
+	}
+	x := c.plotX()
+	originalY, refinedY, differenceY := make([]float64, len(x)), make([]float64, len(x)), make([]float64, len(x))
+	for ii, xi := range x {
+		originalY[ii] = c.bspline.Evaluate(xi)
+		refinedY[ii] = refined.Evaluate(xi)
+		differenceY[ii] = refinedY[ii] - originalY[ii]
+	}
+
+	fig := &grob.Fig{
+		Data: grob.Traces{
+			&grob.Scatter{
+				Name:       "Original",
+				X:          x,
+				Y:          originalY,
+				Mode:       grob.ScatterModeLines,
+				Showlegend: grob.True,
+			},
+			&grob.Scatter{
+				Name:       "Refined",
+				X:          x,
+				Y:          refinedY,
+				Mode:       grob.ScatterModeLines,
+				Showlegend: grob.True,
+			},
+			&grob.Scatter{
+				Name:       "Difference (refined - original)",
+				X:          x,
+				Y:          differenceY,
+				Mode:       grob.ScatterModeLines,
+				Showlegend: grob.True,
+				Line: &grob.ScatterLine{
+					Dash: "dot",
+				},
+			},
+		},
+		Layout: &grob.Layout{
+			Title: &grob.LayoutTitle{
+				Text: "B-Spline Refinement",
+			},
+			Legend: &grob.LayoutLegend{},
+		},
+	}
+	if len(dataX) > 0 {
+		fig.Data = append(fig.Data, &grob.Scatter{
+			Name:       "Data",
+			X:          dataX,
+			Y:          dataY,
+			Mode:       grob.ScatterModeMarkers,
+			Showlegend: grob.True,
+		})
+	}
+	if c.annotation {
+		annotate(fig, metadataText(refined, dataX, dataY))
+	}
+	c.applyLayout(fig)
+	return fig
+}
@@ -0,0 +1,79 @@
+package plotly
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	grob "github.com/MetalBlueberry/go-plotly/graph_objects"
+	"io"
+	"strconv"
+)
+
+// Series is one of the plotted traces, as exported by [Config.ExportData].
+type Series struct {
+	Name string    `json:"name"`
+	X    []float64 `json:"x"`
+	Y    []float64 `json:"y"`
+}
+
+// ExportData writes the x/y values of every trace of the figure that [Config.Plot] would display, so the same
+// numbers can be re-plotted with other tools.
+//
+// The format can be:
+//   - "csv": one row per point, with the columns `series,x,y`, and a header line.
+//   - "json": a list of [Series] objects.
+//
+// It returns an error if the format is unknown or if writing fails.
+func (c *Config) ExportData(w io.Writer, format string) error {
+	series := figureSeries(c.figure())
+	switch format {
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write([]string{"series", "x", "y"}); err != nil {
+			return fmt.Errorf("plotly.ExportData failed to write CSV: %w", err)
+		}
+		for _, s := range series {
+			for ii, x := range s.X {
+				record := []string{s.Name, strconv.FormatFloat(x, 'g', -1, 64), strconv.FormatFloat(s.Y[ii], 'g', -1, 64)}
+				if err := writer.Write(record); err != nil {
+					return fmt.Errorf("plotly.ExportData failed to write CSV: %w", err)
+				}
+			}
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return fmt.Errorf("plotly.ExportData failed to write CSV: %w", err)
+		}
+		return nil
+	case "json":
+		if err := json.NewEncoder(w).Encode(series); err != nil {
+			return fmt.Errorf("plotly.ExportData failed to write JSON: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("plotly.ExportData unknown format %q, valid values are \"csv\" or \"json\"", format)
+}
+
+// figureSeries extracts the x/y values of the Bar and Scatter traces of fig.
+func figureSeries(fig *grob.Fig) []Series {
+	var series []Series
+	for _, trace := range fig.Data {
+		var name grob.String
+		var x, y any
+		switch t := trace.(type) {
+		case *grob.Bar:
+			name, x, y = t.Name, t.X, t.Y
+		case *grob.Scatter:
+			name, x, y = t.Name, t.X, t.Y
+		default:
+			continue
+		}
+		xs, okX := x.([]float64)
+		ys, okY := y.([]float64)
+		if !okX || !okY {
+			continue
+		}
+		series = append(series, Series{Name: fmt.Sprint(name), X: xs, Y: ys})
+	}
+	return series
+}
@@ -0,0 +1,215 @@
+package plotly
+
+import (
+	"encoding/json"
+	"fmt"
+	grob "github.com/MetalBlueberry/go-plotly/graph_objects"
+	"github.com/gomlx/bsplines"
+	"github.com/gomlx/exceptions"
+	"github.com/janpfeifer/gonb/gonbui"
+	"github.com/janpfeifer/gonb/gonbui/dom"
+	gonbplotly "github.com/janpfeifer/gonb/gonbui/plotly"
+	"math"
+)
+
+//go:generate stringer -type=EdgeStatistic
+
+// EdgeStatistic defines how each edge (one B-spline) of a KAN layer is summarized to a scalar, see
+// [Config.PlotKANHeatmap].
+type EdgeStatistic int
+
+const (
+	// EdgeL2Norm summarizes an edge by the L2 norm of its B-spline over the knots range, `sqrt(∫f(x)²dx)`.
+	EdgeL2Norm EdgeStatistic = iota
+
+	// EdgeRange summarizes an edge by the range of values its B-spline takes over the knots range, `max(f)-min(f)`.
+	EdgeRange
+)
+
+// PlotKANHeatmap plots a heatmap summarizing a bank of B-splines (the edges of a KAN layer) by the given statistic.
+// Dead edges (near zero) or dominant ones are easy to spot this way.
+//
+// The controlPoints are shaped `[numInputs][numOutputs][numControlPoints]`, the same layout used by the
+// [github.com/gomlx/bsplines/gomlx] package, and the knots used are the ones of the configured B-spline.
+// The statistics are estimated from numPlotPoints samples over the knots range (the margin is not used).
+// The configured B-spline is not changed, and its control points don't need to be set.
+//
+// Hovering over a cell shows its input and output indices, and clicking on it plots the curve of that edge below
+// the heatmap: the sampled curves of all edges are embedded in the notebook output, so it works without calling back
+// into Go. For the full [Config.Plot] view of an edge, use [Config.PlotKANEdge].
+//
+// It returns an error if plotting failed for some reason.
+func (c *Config) PlotKANHeatmap(controlPoints [][][]float64, stat EdgeStatistic) error {
+	heatmapID, edgeID := gonbui.UniqueId(), gonbui.UniqueId()
+	script, err := c.kanHeatmapScript(controlPoints, stat, heatmapID, edgeID)
+	if err != nil {
+		return err
+	}
+	gonbui.DisplayHTML(fmt.Sprintf(`<div id="%s"></div><div id="%s"></div>`, heatmapID, edgeID))
+	err = dom.LoadScriptOrRequireJSModuleAndRun("plotly", gonbplotly.PlotlySrc, map[string]string{"charset": "utf-8"}, script)
+	if err != nil {
+		return fmt.Errorf("plotly.PlotKANHeatmap failed to display the figure: %w", err)
+	}
+	return nil
+}
+
+// kanHeatmapScript returns the Javascript that plots the heatmap in the HTML element heatmapID, and on a click on a
+// cell plots the curve of its edge in the HTML element edgeID.
+func (c *Config) kanHeatmapScript(controlPoints [][][]float64, stat EdgeStatistic, heatmapID, edgeID string) (string, error) {
+	heatmap, err := json.Marshal(c.kanHeatmapFigure(controlPoints, stat))
+	if err != nil {
+		return "", fmt.Errorf("plotly.PlotKANHeatmap failed to encode the heatmap: %w", err)
+	}
+	edgeLayout := &grob.Fig{Layout: &grob.Layout{
+		Xaxis: &grob.LayoutXaxis{Title: &grob.LayoutXaxisTitle{Text: "x"}},
+		Yaxis: &grob.LayoutYaxis{Title: &grob.LayoutYaxisTitle{Text: "y"}},
+	}}
+	c.applyLayout(edgeLayout)
+	layout, err := json.Marshal(edgeLayout.Layout)
+	if err != nil {
+		return "", fmt.Errorf("plotly.PlotKANHeatmap failed to encode the edge layout: %w", err)
+	}
+	edges, err := json.Marshal(c.kanEdgeCurves(controlPoints))
+	if err != nil {
+		return "", fmt.Errorf("plotly.PlotKANHeatmap failed to encode the edges: %w", err)
+	}
+	return fmt.Sprintf(`
+	if (!module) {
+		module = window.Plotly;
+	}
+	let heatmap = %s;
+	let edgeLayout = %s;
+	let edges = %s;
+	module.newPlot('%s', heatmap).then(function(div) {
+		div.on('plotly_click', function(event) {
+			let point = event.points[0];
+			let edge = edges[point.y][point.x];
+			let layout = Object.assign({}, edgeLayout, {title: {text: edge.name}});
+			module.newPlot('%s', [{x: edge.x, y: edge.y, mode: 'lines', name: edge.name}], layout);
+		});
+	});
+`, heatmap, layout, edges, heatmapID, edgeID), nil
+}
+
+// kanEdgeCurves samples the curve of each edge over the domain, indexed `[outputIdx][inputIdx]` like the heatmap.
+func (c *Config) kanEdgeCurves(controlPoints [][][]float64) [][]Series {
+	first, last := c.bspline.Domain()
+	curves := make([][]Series, len(controlPoints[0]))
+	for outputIdx := range curves {
+		curves[outputIdx] = make([]Series, len(controlPoints))
+		for inputIdx := range controlPoints {
+			edge := c.edge(controlPoints, inputIdx, outputIdx)
+			series := Series{
+				Name: fmt.Sprintf("KAN edge input=%d, output=%d", inputIdx, outputIdx),
+				X:    make([]float64, c.numPlotPoints),
+				Y:    make([]float64, c.numPlotPoints),
+			}
+			for ii := range c.numPlotPoints {
+				x := first + (last-first)*float64(ii)/float64(c.numPlotPoints-1)
+				series.X[ii] = x
+				if ii == c.numPlotPoints-1 {
+					// The last knot itself is extrapolated, take the value just before it.
+					x = math.Nextafter(last, first)
+				}
+				series.Y[ii] = edge.Evaluate(x)
+			}
+			curves[outputIdx][inputIdx] = series
+		}
+	}
+	return curves
+}
+
+// kanHeatmapFigure builds the figure plotted by PlotKANHeatmap.
+func (c *Config) kanHeatmapFigure(controlPoints [][][]float64, stat EdgeStatistic) *grob.Fig {
+	numInputs := len(controlPoints)
+	if numInputs == 0 {
+		exceptions.Panicf("plotly.PlotKANHeatmap() requires at least one input edge, got empty controlPoints")
+	}
+	numOutputs := len(controlPoints[0])
+
+	first, last := c.bspline.Domain()
+	z := make([][]float64, numOutputs) // Heatmaps are indexed [y][x].
+	hover := make([][]string, numOutputs)
+	for outputIdx := range numOutputs {
+		z[outputIdx] = make([]float64, numInputs)
+		hover[outputIdx] = make([]string, numInputs)
+	}
+	for inputIdx := range numInputs {
+		if len(controlPoints[inputIdx]) != numOutputs {
+			exceptions.Panicf("plotly.PlotKANHeatmap() requires all inputs to have the same number of outputs, "+
+				"input #0 has %d outputs, but input #%d has %d", numOutputs, inputIdx, len(controlPoints[inputIdx]))
+		}
+		for outputIdx := range numOutputs {
+			value := c.edgeStatistic(c.edge(controlPoints, inputIdx, outputIdx), stat, first, last)
+			z[outputIdx][inputIdx] = value
+			hover[outputIdx][inputIdx] = fmt.Sprintf("input=%d, output=%d<br>%s=%g", inputIdx, outputIdx, stat, value)
+		}
+	}
+
+	fig := &grob.Fig{
+		Data: grob.Traces{
+			&grob.Heatmap{
+				Name:      stat.String(),
+				Z:         z,
+				Hovertext: hover,
+				Hoverinfo: grob.HeatmapHoverinfoText,
+			},
+		},
+		Layout: &grob.Layout{
+			Title: &grob.LayoutTitle{
+				Text: fmt.Sprintf("KAN Edges (%s)", stat),
+			},
+			Xaxis: &grob.LayoutXaxis{
+				Title: &grob.LayoutXaxisTitle{Text: "input"},
+				Dtick: 1,
+			},
+			Yaxis: &grob.LayoutYaxis{
+				Title: &grob.LayoutYaxisTitle{Text: "output"},
+				Dtick: 1,
+			},
+		},
+	}
+	c.applyLayout(fig)
+	return fig
+}
+
+// PlotKANEdge plots the B-spline of one edge of a KAN layer, with the full [Config.Plot] view.
+// The controlPoints are shaped `[numInputs][numOutputs][numControlPoints]`, as in [Config.PlotKANHeatmap].
+//
+// The configured B-spline is not changed: the edge is plotted with a copy of it.
+func (c *Config) PlotKANEdge(controlPoints [][][]float64, inputIdx, outputIdx int) error {
+	edgeConfig := *c
+	edgeConfig.bspline = c.edge(controlPoints, inputIdx, outputIdx)
+	return edgeConfig.Plot()
+}
+
+// edge returns a copy of the configured B-spline with the control points of the given edge.
+func (c *Config) edge(controlPoints [][][]float64, inputIdx, outputIdx int) *bsplines.BSpline {
+	edge := *c.bspline
+	return edge.WithControlPoints(controlPoints[inputIdx][outputIdx])
+}
+
+// edgeStatistic calculates the statistic for the B-spline of an edge, sampling it on the range [first, last].
+func (c *Config) edgeStatistic(edge *bsplines.BSpline, stat EdgeStatistic, first, last float64) float64 {
+	var sumSquares float64
+	minValue, maxValue := math.Inf(1), math.Inf(-1)
+	for ii := range c.numPlotPoints {
+		x := first + (last-first)*float64(ii)/float64(c.numPlotPoints-1)
+		if ii == c.numPlotPoints-1 {
+			// The last knot itself is extrapolated, take the value just before it.
+			x = math.Nextafter(last, first)
+		}
+		y := edge.Evaluate(x)
+		sumSquares += y * y
+		minValue = min(minValue, y)
+		maxValue = max(maxValue, y)
+	}
+	switch stat {
+	case EdgeL2Norm:
+		return math.Sqrt(sumSquares / float64(c.numPlotPoints) * (last - first))
+	case EdgeRange:
+		return maxValue - minValue
+	}
+	exceptions.Panicf("plotly: unknown EdgeStatistic %s", stat)
+	return 0
+}
@@ -6,24 +6,43 @@
 // Features:
 //   - B-spline function, visible by default.
 //   - Control Points, visible by default.
+//   - Control Polygon, non-visible by default, see Config.WithControlPolygon.
 //   - Derivative, non-visible by default.
-//   - Basis functions, non-visible by default.
+//   - Basis functions, non-visible by default. For many control points, see Config.WithBasisStride and
+//     Config.WithBasisEnvelope.
+//   - Derivatives of the basis functions, disabled by default, see Config.WithBasisDerivatives.
+//   - A reference function being approximated, and the error, disabled by default, see Config.WithReference.
+//
+// The figures can be themed (light or dark) and sized with Config.WithTheme and Config.WithSize.
 package plotly
 
 import (
 	"fmt"
 	grob "github.com/MetalBlueberry/go-plotly/graph_objects"
 	"github.com/gomlx/bsplines"
-	xslices "github.com/gomlx/gomlx/types/slices"
 	"github.com/janpfeifer/gonb/gonbui/plotly"
+	"math"
 )
 
 // Config holds a plot configuration that can be changed.
 // Once finished, call the method [Plot] to actually plot.
 type Config struct {
-	bspline       *bsplines.BSpline
-	numPlotPoints int
-	marginRatio   float64
+	bspline        *bsplines.BSpline
+	numPlotPoints  int
+	marginRatio    float64
+	controlPolygon bool
+	filledBasis    bool
+	basisStride    int
+	basisEnvelope  bool
+
+	basisDerivativeOrder int
+	annotation           bool
+
+	theme         Theme
+	width, height int
+
+	referenceName string
+	reference     func(x float64) float64
 }
 
 // New returns a Config object that can be changed.
@@ -33,6 +52,8 @@ func New(b *bsplines.BSpline) *Config {
 		bspline:       b,
 		numPlotPoints: 1000,
 		marginRatio:   0.1,
+		basisStride:   1,
+		annotation:    true,
 	}
 }
 
@@ -55,23 +76,78 @@ func (c *Config) WithMargin(marginRatio float64) *Config {
 	return c
 }
 
+// WithControlPolygon defines whether the control polygon (the lines connecting the control points) is visible
+// when the plot is first displayed. It can always be toggled by clicking on its legend. Default is false.
+func (c *Config) WithControlPolygon(visible bool) *Config {
+	c.controlPolygon = visible
+	return c
+}
+
+// WithFilledBasis configures the basis functions to be rendered as translucent filled areas under their curves,
+// instead of thin lines. This makes the partition-of-unity structure visually obvious. Default is false.
+func (c *Config) WithFilledBasis(filled bool) *Config {
+	c.filledBasis = filled
+	return c
+}
+
+// WithBasisStride plots only every k-th basis function (and basis derivative, see WithBasisDerivatives), for
+// B-splines with many control points (like fine KAN grids), where plotting all of them makes the figure unusable.
+// Default is 1, which plots all of them.
+func (c *Config) WithBasisStride(k int) *Config {
+	c.basisStride = max(k, 1)
+	return c
+}
+
+// WithBasisEnvelope adds a trace with the envelope of the basis functions -- the maximum of all of them at each x --
+// which summarizes the resolution of the knots in a single trace. Default is false.
+func (c *Config) WithBasisEnvelope(envelope bool) *Config {
+	c.basisEnvelope = envelope
+	return c
+}
+
+// WithBasisDerivatives adds traces with the derivative of the given order of each basis function, non-visible by
+// default (toggle them by clicking on their legend). They are what is needed to debug the continuity at the knots,
+// in particular for knots with multiplicity (see bsplines.NewWithMultiplicities): a jump in the derivative of the
+// basis functions means the B-spline is not smooth at that order.
+//
+// Default is 0, which disables them. Orders larger than the degree are plotted as zero.
+func (c *Config) WithBasisDerivatives(order int) *Config {
+	c.basisDerivativeOrder = max(order, 0)
+	return c
+}
+
+// WithAnnotation defines whether the figure is annotated with the metadata of the B-spline: degree, number of
+// control points, knot range and extrapolation -- and the fit RMSE, when data is given (see Config.PlotComparison).
+// This makes exported figures self-describing. Default is true.
+func (c *Config) WithAnnotation(annotation bool) *Config {
+	c.annotation = annotation
+	return c
+}
+
+// WithReference overlays a ground-truth function f, the one the B-spline is approximating, with the given name,
+// and a trace with the error `B-spline - f` (non-visible by default). Pass a nil f to remove the reference.
+func (c *Config) WithReference(name string, f func(x float64) float64) *Config {
+	c.referenceName, c.reference = name, f
+	return c
+}
+
 // Plot using the current configuration.
 // It returns an error if plotting failed for some reason.
 func (c *Config) Plot() error {
-	knots := c.bspline.Knots()
+	return displayFig(c.figure())
+}
+
+// figure builds the figure plotted by Plot.
+func (c *Config) figure() *grob.Fig {
 	derivative := c.bspline.Derivative()
 
-	x, bsplineY, derivativeY := make([]float64, c.numPlotPoints), make([]float64, c.numPlotPoints), make([]float64, c.numPlotPoints)
-	first, last := knots[0], xslices.Last(knots)
-	delta := last - first
-	first, last = first-c.marginRatio*delta, last+c.marginRatio*delta
-	for ii := range c.numPlotPoints {
-		x[ii] = first + (last-first)*float64(ii)/float64(c.numPlotPoints)
-		bsplineY[ii] = c.bspline.Evaluate(x[ii])
-		derivativeY[ii] = derivative.Evaluate(x[ii])
-	}
+	x := c.plotX()
+	bsplineY, derivativeY := c.bspline.EvaluateBatch(x), derivative.EvaluateBatch(x)
 	basisPlots := make([][]float64, c.bspline.NumControlPoints())
 	for controlIdx := range len(basisPlots) {
+		if controlIdx%c.basisStride != 0 && !c.basisEnvelope {
+			continue
+		}
 		basisPlots[controlIdx] = make([]float64, c.numPlotPoints)
 		basisPlot := basisPlots[controlIdx]
 		for ii := range c.numPlotPoints {
@@ -93,6 +169,14 @@ func (c *Config) Plot() error {
 					},
 				},
 			},
+			&grob.Scatter{
+				Name:       "Control Polygon",
+				X:          c.bspline.ControlPointsX(),
+				Y:          controls,
+				Mode:       grob.ScatterModeLines + "+" + grob.ScatterModeMarkers,
+				Showlegend: grob.True,
+				Visible:    scatterVisibility(c.controlPolygon),
+			},
 			&grob.Bar{
 				Name:       "B-spline",
 				X:          x,
@@ -116,11 +200,30 @@ func (c *Config) Plot() error {
 			Legend: &grob.LayoutLegend{},
 		},
 	}
-	for controlIdx := range len(controls) {
+	for controlIdx := 0; controlIdx < len(controls); controlIdx += c.basisStride {
 		basisPlot := basisPlots[controlIdx]
+		name := fmt.Sprintf("Basis(idx=%d, control[idx]=%f, degree=%d)", controlIdx, controls[controlIdx], c.bspline.Degree())
+		if c.filledBasis {
+			fig.Data = append(fig.Data,
+				&grob.Scatter{
+					Name:       name,
+					X:          x,
+					Y:          basisPlot,
+					Mode:       grob.ScatterModeLines,
+					Fill:       grob.ScatterFillTozeroy,
+					Opacity:    0.4,
+					Showlegend: grob.True,
+					Line: &grob.ScatterLine{
+						Width: 0.5,
+					},
+					Visible: grob.ScatterVisibleLegendonly,
+				},
+			)
+			continue
+		}
 		fig.Data = append(fig.Data,
 			&grob.Bar{
-				Name:       fmt.Sprintf("Basis(idx=%d, control[idx]=%f, degree=%d)", controlIdx, controls[controlIdx], c.bspline.Degree()),
+				Name:       name,
 				X:          x,
 				Y:          basisPlot,
 				Showlegend: grob.True,
@@ -129,7 +232,148 @@ func (c *Config) Plot() error {
 			},
 		)
 	}
+	if c.basisEnvelope {
+		fig.Data = append(fig.Data, basisEnvelopeTrace(x, basisPlots))
+	}
+	if c.basisDerivativeOrder > 0 {
+		fig.Data = append(fig.Data, c.basisDerivativeTraces(x)...)
+	}
+	if c.reference != nil {
+		fig.Data = append(fig.Data, c.referenceTraces(x, bsplineY)...)
+	}
+	if c.annotation {
+		annotate(fig, metadataText(c.bspline, nil, nil))
+	}
+	c.applyLayout(fig)
+	return fig
+}
+
+// metadataText describes the B-spline in one line. If data is given, it includes the RMSE of the B-spline on it.
+func metadataText(b *bsplines.BSpline, dataX, dataY []float64) string {
+	domainMin, domainMax := b.Domain()
+	text := fmt.Sprintf("degree=%d, control points=%d, knots=[%g, %g], %s",
+		b.Degree(), b.NumControlPoints(), domainMin, domainMax, b.Extrapolation())
+	if len(dataX) > 0 {
+		var sumSquares float64
+		for ii, y := range b.EvaluateBatch(dataX) {
+			sumSquares += (y - dataY[ii]) * (y - dataY[ii])
+		}
+		text += fmt.Sprintf(", fit RMSE=%.4g", math.Sqrt(sumSquares/float64(len(dataX))))
+	}
+	return text
+}
+
+// annotate adds the text as an annotation at the top left corner of the figure.
+func annotate(fig *grob.Fig, text string) {
+	fig.Layout.Annotations = []map[string]any{{
+		"text":      text,
+		"showarrow": false,
+		"xref":      "paper",
+		"yref":      "paper",
+		"x":         0,
+		"y":         1.05,
+		"xanchor":   "left",
+		"yanchor":   "bottom",
+	}}
+}
+
+// basisDerivativeTraces returns one trace per basis function, with its derivative of order basisDerivativeOrder
+// evaluated at x.
+func (c *Config) basisDerivativeTraces(x []float64) grob.Traces {
+	order := c.basisDerivativeOrder
+	matrix := bsplines.NewGridPlan(c.bspline, x, order).BasisMatrix(order) // shape [len(x)][numControlPoints]
+	traces := make(grob.Traces, 0, c.bspline.NumControlPoints()/c.basisStride+1)
+	for controlIdx := 0; controlIdx < c.bspline.NumControlPoints(); controlIdx += c.basisStride {
+		y := make([]float64, len(x))
+		for ii, row := range matrix {
+			y[ii] = row[controlIdx]
+		}
+		traces = append(traces, &grob.Scatter{
+			Name:       fmt.Sprintf("Basis derivative(idx=%d, order=%d, degree=%d)", controlIdx, order, c.bspline.Degree()),
+			X:          x,
+			Y:          y,
+			Mode:       grob.ScatterModeLines,
+			Showlegend: grob.True,
+			Visible:    grob.ScatterVisibleLegendonly,
+		})
+	}
+	return traces
+}
+
+// basisEnvelopeTrace returns the trace with the maximum of the basis functions (basisPlots) at each x.
+func basisEnvelopeTrace(x []float64, basisPlots [][]float64) *grob.Scatter {
+	envelope := make([]float64, len(x))
+	for _, basisPlot := range basisPlots {
+		for ii, value := range basisPlot {
+			envelope[ii] = max(envelope[ii], value)
+		}
+	}
+	return &grob.Scatter{
+		Name:       "Basis envelope (max)",
+		X:          x,
+		Y:          envelope,
+		Mode:       grob.ScatterModeLines,
+		Showlegend: grob.True,
+		Visible:    grob.ScatterVisibleLegendonly,
+	}
+}
+
+// referenceTraces returns the traces with the reference function and the error of the B-spline (bsplineY) with
+// respect to it, evaluated at x.
+func (c *Config) referenceTraces(x, bsplineY []float64) grob.Traces {
+	referenceY, errorY := make([]float64, len(x)), make([]float64, len(x))
+	for ii, xi := range x {
+		referenceY[ii] = c.reference(xi)
+		errorY[ii] = bsplineY[ii] - referenceY[ii]
+	}
+	return grob.Traces{
+		&grob.Scatter{
+			Name:       c.referenceName,
+			X:          x,
+			Y:          referenceY,
+			Mode:       grob.ScatterModeLines,
+			Showlegend: grob.True,
+			Line: &grob.ScatterLine{
+				Dash: "dash",
+			},
+		},
+		&grob.Scatter{
+			Name:       fmt.Sprintf("Error (B-spline - %s)", c.referenceName),
+			X:          x,
+			Y:          errorY,
+			Mode:       grob.ScatterModeLines,
+			Showlegend: grob.True,
+			Visible:    grob.ScatterVisibleLegendonly,
+			Line: &grob.ScatterLine{
+				Dash: "dot",
+			},
+		},
+	}
+}
+
+// scatterVisibility returns the Scatter visibility for a trace that is visible or only shown in the legend.
+func scatterVisibility(visible bool) grob.ScatterVisible {
+	if visible {
+		return grob.ScatterVisibleTrue
+	}
+	return grob.ScatterVisibleLegendonly
+}
+
+// plotX returns the x values where the B-spline is evaluated for plotting: numPlotPoints values covering the
+// B-spline domain plus the margin.
+func (c *Config) plotX() []float64 {
+	x := make([]float64, c.numPlotPoints)
+	first, last := c.bspline.Domain()
+	delta := last - first
+	first, last = first-c.marginRatio*delta, last+c.marginRatio*delta
+	for ii := range c.numPlotPoints {
+		x[ii] = first + (last-first)*float64(ii)/float64(c.numPlotPoints)
+	}
+	return x
+}
 
+// displayFig displays the figure in the notebook.
+func displayFig(fig *grob.Fig) error {
 	err := plotly.DisplayFig(fig)
 	if err != nil {
 		err = fmt.Errorf("plotly.DisplayFig failed: %v", err)
@@ -0,0 +1,166 @@
+package plotly
+
+import (
+	"bytes"
+	"encoding/json"
+	grob "github.com/MetalBlueberry/go-plotly/graph_objects"
+	"github.com/gomlx/bsplines"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestExportData(t *testing.T) {
+	b := bsplines.NewRegular(2, 4).WithControlPoints([]float64{0, 1, 0.5, 2})
+	c := New(b).WithNumPlotPoints(10)
+
+	var buf bytes.Buffer
+	require.NoError(t, c.ExportData(&buf, "json"))
+	var series []Series
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &series))
+	require.Equal(t, "Control Points", series[0].Name)
+	assert.Equal(t, b.ControlPoints(), series[0].Y)
+	require.Equal(t, "B-spline", series[2].Name)
+	assert.Len(t, series[2].X, 10)
+	assert.Equal(t, b.Evaluate(series[2].X[3]), series[2].Y[3])
+
+	buf.Reset()
+	require.NoError(t, c.ExportData(&buf, "csv"))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, "series,x,y", lines[0])
+	numPoints := 0
+	for _, s := range series {
+		numPoints += len(s.X)
+	}
+	assert.Len(t, lines, numPoints+1)
+
+	require.Error(t, c.ExportData(&buf, "xml"))
+}
+
+func TestBasisDerivatives(t *testing.T) {
+	b := bsplines.NewWithMultiplicities(3, []float64{0, 0.5, 1}, []int{1, 2, 1}).
+		WithControlPoints([]float64{0, 1, 0.5, 2, 1, 0})
+	c := New(b).WithNumPlotPoints(20)
+	numTraces := len(c.figure().Data)
+	fig := c.WithBasisDerivatives(1).figure()
+	require.Len(t, fig.Data, numTraces+b.NumControlPoints())
+
+	series := figureSeries(fig)
+	last := series[len(series)-1]
+	assert.Equal(t, "Basis derivative(idx=5, order=1, degree=3)", last.Name)
+	// The derivatives of the basis functions times the control points add up to the derivative of the B-spline.
+	derivative := b.Derivative()
+	for ii, x := range last.X {
+		var sum float64
+		for controlIdx, control := range b.ControlPoints() {
+			sum += control * series[len(series)-b.NumControlPoints()+controlIdx].Y[ii]
+		}
+		if x >= 0 && x < 1 {
+			assert.InDelta(t, derivative.Evaluate(x), sum, 1e-9, "x=%g", x)
+		}
+	}
+}
+
+func TestAnnotation(t *testing.T) {
+	b := bsplines.NewRegular(2, 4).WithControlPoints([]float64{0, 1, 0.5, 2}).WithExtrapolation(bsplines.ExtrapolateLinear)
+	c := New(b).WithNumPlotPoints(10)
+	annotations := c.figure().Layout.Annotations.([]map[string]any)
+	require.Len(t, annotations, 1)
+	assert.Equal(t, "degree=2, control points=4, knots=[0, 1], ExtrapolateLinear", annotations[0]["text"])
+
+	dataX, dataY := []float64{0.25, 0.75}, []float64{b.Evaluate(0.25) + 0.1, b.Evaluate(0.75) - 0.1}
+	annotations = c.comparisonFigure(b, dataX, dataY).Layout.Annotations.([]map[string]any)
+	assert.Contains(t, annotations[0]["text"], "fit RMSE=0.1")
+
+	assert.Nil(t, c.WithAnnotation(false).figure().Layout.Annotations)
+}
+
+func TestThemeAndSize(t *testing.T) {
+	b := bsplines.NewRegular(2, 4).WithControlPoints([]float64{0, 1, 0.5, 2})
+	layout := New(b).WithNumPlotPoints(10).figure().Layout
+	assert.Zero(t, layout.Width)
+	assert.Nil(t, layout.PaperBgcolor)
+
+	c := New(b).WithNumPlotPoints(10).WithTheme(ThemeDark).WithSize(800, 400)
+	for _, fig := range []*grob.Fig{c.figure(), c.comparisonFigure(b, nil, nil)} {
+		assert.Equal(t, 800.0, fig.Layout.Width)
+		assert.Equal(t, 400.0, fig.Layout.Height)
+		assert.Equal(t, darkBackground, fig.Layout.PaperBgcolor)
+		assert.Equal(t, darkFont, fig.Layout.Font.Color)
+	}
+}
+
+func TestReference(t *testing.T) {
+	b := bsplines.NewRegular(2, 4).WithControlPoints([]float64{0, 1, 0.5, 2})
+	numTraces := len(New(b).WithNumPlotPoints(10).figure().Data)
+	fig := New(b).WithNumPlotPoints(10).WithReference("sin", math.Sin).figure()
+	require.Len(t, fig.Data, numTraces+2)
+	reference, errorTrace := fig.Data[numTraces].(*grob.Scatter), fig.Data[numTraces+1].(*grob.Scatter)
+	assert.Equal(t, "sin", reference.Name)
+	assert.Equal(t, "Error (B-spline - sin)", errorTrace.Name)
+	for ii, x := range reference.X.([]float64) {
+		assert.InDelta(t, b.Evaluate(x)-math.Sin(x), errorTrace.Y.([]float64)[ii], 1e-12)
+	}
+}
+
+func TestBasisStrideAndEnvelope(t *testing.T) {
+	b := bsplines.NewRegular(2, 20).WithControlPoints(make([]float64, 20))
+	numTraces := len(New(b).WithNumPlotPoints(10).figure().Data)
+	fig := New(b).WithNumPlotPoints(10).WithBasisStride(5).WithBasisDerivatives(1).figure()
+	require.Len(t, fig.Data, numTraces-20+2*4)
+	assert.Equal(t, "Basis derivative(idx=15, order=1, degree=2)", fig.Data[len(fig.Data)-1].(*grob.Scatter).Name)
+
+	fig = New(b).WithNumPlotPoints(10).WithBasisStride(5).WithBasisEnvelope(true).figure()
+	require.Len(t, fig.Data, numTraces-20+4+1)
+	envelope := fig.Data[len(fig.Data)-1].(*grob.Scatter)
+	assert.Equal(t, "Basis envelope (max)", envelope.Name)
+	for ii, x := range envelope.X.([]float64) {
+		y := envelope.Y.([]float64)[ii]
+		if x >= 0 && x < 1 {
+			assert.True(t, y > 0 && y <= 1, "x=%g, envelope=%g", x, y)
+		} else {
+			assert.Zero(t, y)
+		}
+	}
+}
+
+func TestKANHeatmap(t *testing.T) {
+	// The configured B-spline has no control points, and it's not changed.
+	b := bsplines.NewRegular(1, 3)
+	c := New(b).WithNumPlotPoints(101)
+	controlPoints := [][][]float64{
+		{{0, 0, 0}, {1, 1, 1}},
+		{{0, 1, 0}, {0, 2, 0}},
+		{{-1, 0, 1}, {0, 0, 0}},
+	}
+	fig := c.kanHeatmapFigure(controlPoints, EdgeRange)
+	assert.Nil(t, b.ControlPoints())
+	heatmap := fig.Data[0].(*grob.Heatmap)
+	z := heatmap.Z.([][]float64)
+	require.Len(t, z, 2)
+	require.Len(t, z[0], 3)
+	assert.InDeltaSlice(t, []float64{0, 1, 2}, z[0], 1e-9)
+	assert.InDeltaSlice(t, []float64{0, 2, 0}, z[1], 1e-9)
+
+	edge := c.edge(controlPoints, 1, 1)
+	assert.Equal(t, []float64{0, 2, 0}, edge.ControlPoints())
+	assert.Nil(t, b.ControlPoints())
+
+	// Click-through: the curves of the edges are embedded, indexed like the heatmap.
+	curves := c.kanEdgeCurves(controlPoints)
+	require.Len(t, curves, 2)
+	require.Len(t, curves[1], 3)
+	require.Len(t, curves[1][1].Y, 101)
+	assert.InDelta(t, 2, curves[1][1].Y[50], 1e-9)
+	assert.InDelta(t, 0, curves[1][1].Y[0], 1e-9)
+	assert.InDelta(t, 0, curves[1][1].Y[100], 1e-9)
+	script, err := c.kanHeatmapScript(controlPoints, EdgeRange, "heatmap-id", "edge-id")
+	require.NoError(t, err)
+	assert.Contains(t, script, "plotly_click")
+	assert.Contains(t, script, "'heatmap-id'")
+	assert.Contains(t, script, "'edge-id'")
+	assert.Contains(t, script, `"KAN edge input=1, output=1"`)
+	assert.Nil(t, b.ControlPoints())
+}
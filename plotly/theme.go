@@ -0,0 +1,58 @@
+package plotly
+
+import (
+	grob "github.com/MetalBlueberry/go-plotly/graph_objects"
+)
+
+//go:generate stringer -type=Theme
+
+// Theme defines the colors of the figures, see [Config.WithTheme].
+type Theme int
+
+const (
+	// ThemeLight is Plotly's default theme, with a white background.
+	ThemeLight Theme = iota
+
+	// ThemeDark uses a dark background and light text, for dark dashboards and slides.
+	ThemeDark
+)
+
+// Colors used by ThemeDark, the same as Plotly's "plotly_dark" template.
+const (
+	darkBackground = "rgb(17,17,17)"
+	darkFont       = "#f2f5fa"
+	darkGrid       = "#283442"
+)
+
+// WithTheme sets the colors of the figures. Default is ThemeLight.
+func (c *Config) WithTheme(theme Theme) *Config {
+	c.theme = theme
+	return c
+}
+
+// WithSize sets the width and height of the figures, in pixels. A value of 0 (the default) lets Plotly use its
+// default for that dimension, which fills the width of the notebook cell.
+func (c *Config) WithSize(width, height int) *Config {
+	c.width, c.height = max(width, 0), max(height, 0)
+	return c
+}
+
+// applyLayout sets the theme and size configured to the figure layout.
+func (c *Config) applyLayout(fig *grob.Fig) {
+	layout := fig.Layout
+	layout.Width, layout.Height = float64(c.width), float64(c.height)
+	if c.theme != ThemeDark {
+		return
+	}
+	layout.PaperBgcolor = darkBackground
+	layout.PlotBgcolor = darkBackground
+	layout.Font = &grob.LayoutFont{Color: darkFont}
+	if layout.Xaxis == nil {
+		layout.Xaxis = &grob.LayoutXaxis{}
+	}
+	layout.Xaxis.Gridcolor = darkGrid
+	if layout.Yaxis == nil {
+		layout.Yaxis = &grob.LayoutYaxis{}
+	}
+	layout.Yaxis.Gridcolor = darkGrid
+}
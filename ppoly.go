@@ -0,0 +1,185 @@
+package bsplines
+
+import (
+	"math"
+	"sort"
+)
+
+// PPoly is a piecewise polynomial: in each segment `[Breaks[i], Breaks[i+1])` the function is the polynomial
+// `Σ_k Coefficients[i][k] * (x - Breaks[i])^k`, evaluated with Horner's method. Outside the breaks it's extrapolated
+// as a B-spline with the same Extrapolation.
+//
+// It's analogous to SciPy's `PPoly` (notice SciPy stores the coefficients from the highest power down), and it's
+// created from a B-spline with BSpline.ToPPoly. Evaluation from the polynomial coefficients is faster than from the
+// control points, and each segment can be analyzed analytically, e.g. to find its roots.
+type PPoly struct {
+	// Breaks are the strictly increasing limits of the segments: there is one more break than segments.
+	Breaks []float64
+
+	// Coefficients holds for each segment the coefficients of the polynomial in the powers of `(x - Breaks[i])`, in
+	// increasing order: Coefficients[i][0] is the value at Breaks[i].
+	Coefficients [][]float64
+
+	// Extrapolation used outside the breaks.
+	Extrapolation ExtrapolationType
+
+	// ClosedDomain defines whether the last break is evaluated with the last polynomial instead of extrapolated, as
+	// in BSpline.WithClosedDomain.
+	ClosedDomain bool
+}
+
+// ToPPoly converts the B-spline to its piecewise polynomial representation, with one segment per non-empty knot span,
+// and polynomials of degree Degree(). The conversion is exact, up to rounding errors.
+//
+// Like the B-spline, the PPoly is extrapolated at the last knot, unless it has a closed domain (see WithClosedDomain).
+//
+// The control points must be set.
+func (b *BSpline) ToPPoly() *PPoly {
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.ToPPoly() require control points to be set using BSpline.WithControlPoints()")
+	}
+	breaks, _ := b.KnotMultiplicities()
+	pp := &PPoly{
+		Breaks:        breaks,
+		Coefficients:  make([][]float64, len(breaks)-1),
+		Extrapolation: b.extrapolation,
+		ClosedDomain:  b.closedDomain,
+	}
+	plan := NewGridPlan(b, breaks[:len(breaks)-1], b.degree)
+	factorial := 1.0
+	for k := 0; k <= b.degree; k++ {
+		// The coefficient of the power k is the k-th derivative divided by k!, at the start of the segment.
+		if k > 0 {
+			factorial *= float64(k)
+		}
+		derivatives := plan.Evaluate(k)
+		for ii, derivative := range derivatives {
+			if k == 0 {
+				pp.Coefficients[ii] = make([]float64, b.degree+1)
+			}
+			pp.Coefficients[ii][k] = derivative / factorial
+		}
+	}
+	return pp
+}
+
+// Degree returns the degree of the polynomials.
+func (pp *PPoly) Degree() int {
+	return len(pp.Coefficients[0]) - 1
+}
+
+// Evaluate the piecewise polynomial at x.
+func (pp *PPoly) Evaluate(x float64) float64 {
+	first, last := pp.Breaks[0], at(pp.Breaks, -1)
+	switch {
+	case math.IsNaN(x):
+		return math.NaN()
+	case x < first:
+		return pp.extrapolate(0, first, x)
+	case x == last && pp.ClosedDomain:
+		segment := len(pp.Coefficients) - 1
+		return horner(pp.Coefficients[segment], x-pp.Breaks[segment])
+	case x >= last:
+		return pp.extrapolate(len(pp.Coefficients)-1, last, x)
+	}
+	segment := sort.Search(len(pp.Breaks), func(ii int) bool { return pp.Breaks[ii] > x }) - 1
+	return horner(pp.Coefficients[segment], x-pp.Breaks[segment])
+}
+
+// extrapolate x using the polynomial of the given segment at the end of the breaks.
+func (pp *PPoly) extrapolate(segment int, end, x float64) float64 {
+	coefficients := pp.Coefficients[segment]
+	h := end - pp.Breaks[segment]
+	switch pp.Extrapolation {
+	case ExtrapolateConstant:
+		return horner(coefficients, h)
+	case ExtrapolateLinear:
+		var slope float64
+		for k := len(coefficients) - 1; k >= 1; k-- {
+			slope = slope*h + float64(k)*coefficients[k]
+		}
+		return horner(coefficients, h) + slope*(x-end)
+	default:
+		return 0
+	}
+}
+
+// horner evaluates the polynomial `Σ_k coefficients[k] * h^k`.
+func horner(coefficients []float64, h float64) float64 {
+	var result float64
+	for k := len(coefficients) - 1; k >= 0; k-- {
+		result = result*h + coefficients[k]
+	}
+	return result
+}
+
+// ppolyContinuityTolerance is the relative tolerance used by FromPPoly to decide whether the derivatives of
+// consecutive segments match at a break.
+const ppolyContinuityTolerance = 1e-9
+
+// FromPPoly builds the B-spline equal to the piecewise polynomial pp -- e.g. imported from the polynomial form
+// of another library. It's the inverse of BSpline.ToPPoly.
+//
+// The degree is the one of the polynomials, and the breaks become the knots. The smoothness at each interior break
+// is detected by comparing the derivatives of the adjacent segments (with a relative tolerance of 1e-9): if they are
+// continuous up to order k, the knot is repeated `degree-k` times in the expanded knots, and `degree+1` times if the
+// values themselves don't match. So the conversion is exact for any piecewise polynomial, and breaks that are not
+// smooth become repeated knots, see NewWithMultiplicities.
+func FromPPoly(pp *PPoly) *BSpline {
+	if len(pp.Breaks) < 2 || len(pp.Coefficients) != len(pp.Breaks)-1 {
+		panicf(ErrInvalidArgument, "bsplines.FromPPoly requires one segment of coefficients per pair of breaks, got %d breaks and %d segments",
+			len(pp.Breaks), len(pp.Coefficients))
+	}
+	checkStrictlyIncreasing("bsplines.FromPPoly", pp.Breaks)
+	degree := pp.Degree()
+	for ii, coefficients := range pp.Coefficients {
+		if len(coefficients) != degree+1 {
+			panicf(ErrInvalidArgument, "bsplines.FromPPoly requires the same number of coefficients for all segments, segment 0 has %d, segment %d has %d",
+				degree+1, ii, len(coefficients))
+		}
+	}
+
+	expandedKnots := make([]float64, 0, (degree+1)*len(pp.Breaks))
+	for range degree + 1 {
+		expandedKnots = append(expandedKnots, pp.Breaks[0])
+	}
+	for ii := 1; ii < len(pp.Breaks)-1; ii++ {
+		multiplicity := max(degree-pp.continuityAt(ii), 1)
+		for range multiplicity {
+			expandedKnots = append(expandedKnots, pp.Breaks[ii])
+		}
+	}
+	for range degree + 1 {
+		expandedKnots = append(expandedKnots, at(pp.Breaks, -1))
+	}
+	b := newFromExpandedKnots(degree, expandedKnots).WithExtrapolation(pp.Extrapolation).WithClosedDomain(pp.ClosedDomain)
+
+	// The quasi-interpolant only samples the interior of the knot spans, and it reproduces exactly the B-splines
+	// of its space.
+	return b.WithControlPoints(b.QuasiInterpolate(pp.Evaluate))
+}
+
+// continuityAt returns the largest k such that the derivatives of order 0 to k of the segments before and after the
+// interior break ii match, or -1 if the values don't match.
+func (pp *PPoly) continuityAt(ii int) int {
+	left, right := pp.Coefficients[ii-1], pp.Coefficients[ii]
+	h := pp.Breaks[ii] - pp.Breaks[ii-1]
+
+	// Derivative k of the left polynomial at h, divided by k!: Σ_{j>=k} binomial(j, k) * left[j] * h^(j-k).
+	for k := range left {
+		var fromLeft float64
+		binomial := 1.0
+		power := 1.0
+		for j := k; j < len(left); j++ {
+			if j > k {
+				binomial = binomial * float64(j) / float64(j-k)
+				power *= h
+			}
+			fromLeft += binomial * left[j] * power
+		}
+		if math.Abs(fromLeft-right[k]) > ppolyContinuityTolerance*(1+max(math.Abs(fromLeft), math.Abs(right[k]))) {
+			return k - 1
+		}
+	}
+	return len(left) - 1
+}
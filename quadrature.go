@@ -0,0 +1,41 @@
+package bsplines
+
+// QuadratureNodes returns the Gauss-Legendre nodes and weights with order points, mapped to the knot interval of
+// the given span -- `[ExpandedKnots()[span], ExpandedKnots()[span+1]]`, see SpanIndex.
+//
+// `Σ weights[i] * g(nodes[i])` integrates exactly over the span any polynomial g of degree up to `2*order-1`: e.g.,
+// with `order = Degree()+1` it integrates exactly the product of two basis functions, or the square of the B-spline.
+// The valid spans go from Degree() to NumControlPoints()-1, and it returns empty slices for spans with zero length.
+func (b *BSpline) QuadratureNodes(span, order int) (nodes, weights []float64) {
+	if span < b.degree || span >= b.NumControlPoints() {
+		panicf(ErrOutOfDomain, "BSpline.QuadratureNodes(span=%d) requires span in the range [%d, %d]", span, b.degree, b.NumControlPoints()-1)
+	}
+	if order < 1 {
+		panicf(ErrInvalidArgument, "BSpline.QuadratureNodes(order=%d) requires order >= 1", order)
+	}
+	start, end := b.expandedKnots[span], b.expandedKnots[span+1]
+	if start == end {
+		return nil, nil
+	}
+	nodes, weights = gaussLegendre(order)
+	halfWidth, center := (end-start)/2, (end+start)/2
+	for ii := range nodes {
+		nodes[ii] = center + halfWidth*nodes[ii]
+		weights[ii] *= halfWidth
+	}
+	return
+}
+
+// Integrate returns the integral of f over the domain of the B-spline, using QuadratureNodes with order points
+// on each span. It's exact if f is a polynomial of degree up to `2*order-1` on each span -- e.g.: any
+// functional of the B-spline, like `f(x) = (b.Evaluate(x) - target(x))²` for a polynomial target.
+func (b *BSpline) Integrate(f func(x float64) float64, order int) float64 {
+	var sum float64
+	for span := b.degree; span < b.NumControlPoints(); span++ {
+		nodes, weights := b.QuadratureNodes(span, order)
+		for ii, x := range nodes {
+			sum += weights[ii] * f(x)
+		}
+	}
+	return sum
+}
@@ -0,0 +1,58 @@
+package bsplines
+
+// QuasiInterpolate returns control points for the B-spline that approximate the function f, calculated with the
+// De Boor-Fix quasi-interpolant: for each control point, f is sampled at `degree+1` points on one knot span under
+// its support, and the control point is taken from the local interpolant on that span.
+//
+// It reproduces exactly any polynomial of degree up to the B-spline degree, and the error for smooth functions is of
+// the same order as the best approximation. It doesn't solve any global linear system, so it's O(NumControlPoints())
+// and it's often good enough as an initialization of the control points, e.g. for KAN networks.
+//
+// Use it with WithControlPoints, e.g.: `b.WithControlPoints(b.QuasiInterpolate(math.Sin))`.
+func (b *BSpline) QuasiInterpolate(f func(x float64) float64) []float64 {
+	numControlPoints := b.NumControlPoints()
+	control := make([]float64, numControlPoints)
+	basis := make([]float64, b.degree+1)
+	lastSpan := -1
+	var local []float64
+	for ii := range numControlPoints {
+		// Control point ii is supported on spans [ii, ii+degree]: take the longest of the valid ones.
+		span := -1
+		for k := max(ii, b.degree); k <= min(ii+b.degree, numControlPoints-1); k++ {
+			if span < 0 || b.expandedKnots[k+1]-b.expandedKnots[k] > b.expandedKnots[span+1]-b.expandedKnots[span] {
+				span = k
+			}
+		}
+		if span != lastSpan {
+			// Interpolate f on the span with the degree+1 local basis functions.
+			start, end := b.expandedKnots[span], b.expandedKnots[span+1]
+			a := newMatrix(b.degree+1, b.degree+1)
+			values := make([]float64, b.degree+1)
+			for jj := range values {
+				x := start + (end-start)*(float64(jj)+0.5)/float64(b.degree+1)
+				b.localBasis(span, x, b.degree, basis)
+				copy(a[jj], basis)
+				values[jj] = f(x)
+			}
+			local = solveLinearSystem(a, values)
+			lastSpan = span
+		}
+		control[ii] = local[ii-(span-b.degree)]
+	}
+	return control
+}
+
+// SchoenbergControlPoints returns control points for the B-spline that approximate the function f with the
+// Schoenberg variation-diminishing operator: each control point is f evaluated at its Greville abscissa
+// (see ControlPointsX).
+//
+// It only reproduces exactly linear functions, but it preserves the shape of f: if f is positive, monotone or
+// convex, so is the B-spline. See also QuasiInterpolate for a more accurate approximation.
+func (b *BSpline) SchoenbergControlPoints(f func(x float64) float64) []float64 {
+	xs := b.ControlPointsX()
+	control := make([]float64, len(xs))
+	for ii, x := range xs {
+		control[ii] = f(x)
+	}
+	return control
+}
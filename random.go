@@ -0,0 +1,97 @@
+package bsplines
+
+import (
+	"math/rand/v2"
+	"slices"
+)
+
+// RandomOption configures RandomBSpline.
+type RandomOption func(c *randomConfig)
+
+// randomConfig holds the configuration of RandomBSpline.
+type randomConfig struct {
+	monotone      bool
+	min, max      float64
+	positive      bool
+	regularKnots  bool
+	extrapolation ExtrapolationType
+}
+
+// RandomMonotone makes RandomBSpline generate non-decreasing B-splines, by sorting the control points.
+func RandomMonotone() RandomOption {
+	return func(c *randomConfig) { c.monotone = true }
+}
+
+// RandomPositive makes RandomBSpline generate B-splines that are never negative: the lower bound of the control
+// points is raised to 0 if needed.
+func RandomPositive() RandomOption {
+	return func(c *randomConfig) { c.positive = true }
+}
+
+// RandomBounded makes RandomBSpline generate B-splines whose values are within `[min, max]` in the whole domain.
+// The default bounds are `[-1, 1]`.
+func RandomBounded(min, max float64) RandomOption {
+	if min > max {
+		panicf(ErrInvalidArgument, "bsplines.RandomBounded(min=%g, max=%g) requires min <= max", min, max)
+	}
+	return func(c *randomConfig) { c.min, c.max = min, max }
+}
+
+// RandomKnots makes RandomBSpline generate random non-uniform knots in `[0, 1]`, instead of the evenly spaced
+// knots of NewRegular.
+func RandomKnots() RandomOption {
+	return func(c *randomConfig) { c.regularKnots = false }
+}
+
+// RandomExtrapolation sets the extrapolation of the generated B-splines. Default is [ExtrapolateConstant].
+func RandomExtrapolation(extrapolation ExtrapolationType) RandomOption {
+	return func(c *randomConfig) { c.extrapolation = extrapolation }
+}
+
+// RandomBSpline creates a B-spline with random control points, for testing and benchmarking.
+// The results are reproducible for the same rng state and options.
+//
+// By default, the knots are evenly spaced in `[0, 1]` (as in NewRegular), and the control points are uniformly
+// sampled in `[-1, 1]`. Use the options RandomMonotone, RandomPositive, RandomBounded, RandomKnots and
+// RandomExtrapolation to change that.
+//
+// Since a B-spline is always within the convex hull of its control points, the bounds hold for the whole
+// domain, and sorted control points yield a monotone B-spline.
+func RandomBSpline(rng *rand.Rand, degree, numControlPoints int, opts ...RandomOption) *BSpline {
+	c := &randomConfig{min: -1, max: 1, regularKnots: true, extrapolation: ExtrapolateConstant}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.positive && c.min < 0 {
+		if c.max < 0 {
+			panicf(ErrInvalidArgument, "bsplines.RandomBSpline with RandomPositive() and RandomBounded(%g, %g) is not possible", c.min, c.max)
+		}
+		c.min = 0
+	}
+
+	var b *BSpline
+	if c.regularKnots {
+		b = NewRegular(degree, numControlPoints)
+	} else {
+		if numControlPoints < degree+1 {
+			panicf(ErrControlPointCount, "bsplines.RandomBSpline requires numControlPoints=%d >= degree+1", numControlPoints)
+		}
+		numKnots := numControlPoints - degree + 1
+		knots := make([]float64, numKnots)
+		knots[numKnots-1] = 1
+		for ii := 1; ii < numKnots-1; ii++ {
+			// Jitter the evenly spaced knots, keeping them strictly increasing.
+			knots[ii] = (float64(ii) + 0.8*(rng.Float64()-0.5)) / float64(numKnots-1)
+		}
+		b = New(degree, knots)
+	}
+
+	control := make([]float64, numControlPoints)
+	for ii := range control {
+		control[ii] = c.min + (c.max-c.min)*rng.Float64()
+	}
+	if c.monotone {
+		slices.Sort(control)
+	}
+	return b.WithExtrapolation(c.extrapolation).WithControlPoints(control)
+}
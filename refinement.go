@@ -0,0 +1,201 @@
+package bsplines
+
+import (
+	"slices"
+)
+
+// insertKnot inserts the knot u in the expanded knots of a B-spline of the given degree, using Boehm's algorithm,
+// and returns the new expanded knots and control points, that define exactly the same curve.
+// The inputs are not changed.
+func insertKnot(expandedKnots []float64, degree int, control []float64, u float64) (newKnots, newControl []float64) {
+	// Find k such that expandedKnots[k] <= u < expandedKnots[k+1], limited to the valid spans.
+	k := degree
+	for k < len(control)-1 && expandedKnots[k+1] <= u {
+		k++
+	}
+	newControl = make([]float64, len(control)+1)
+	for ii := range newControl {
+		switch {
+		case ii <= k-degree:
+			newControl[ii] = control[ii]
+		case ii <= k:
+			alpha := (u - expandedKnots[ii]) / (expandedKnots[ii+degree] - expandedKnots[ii])
+			newControl[ii] = (1-alpha)*control[ii-1] + alpha*control[ii]
+		default:
+			newControl[ii] = control[ii-1]
+		}
+	}
+	newKnots = slices.Insert(slices.Clone(expandedKnots), k+1, u)
+	return
+}
+
+// RefineControlPoints maps control points across a knot refinement: it returns the control points that represent,
+// over the knots fineKnots, the same curve defined by the given control points over the knots of b.
+// The fineKnots (not expanded) must be sorted, within the domain of b, and include all the knots of b, with at least
+// the same multiplicities, see CommonRefinement.
+//
+// The given control points are not changed. See also Refine and RefinementMatrix.
+func (b *BSpline) RefineControlPoints(fineKnots []float64, control []float64) []float64 {
+	domainMin, domainMax := b.Domain()
+	if !slices.IsSorted(fineKnots) || len(fineKnots) == 0 || !(fineKnots[0] >= domainMin && at(fineKnots, -1) <= domainMax) {
+		panicf(ErrInvalidArgument, "bsplines: refined knots %v must be sorted and within the domain [%g, %g]", fineKnots, domainMin, domainMax)
+	}
+	expandedKnots := b.expandedKnots
+	knots := b.Knots()
+	var next int // Next knot of b not yet matched in fineKnots: both are sorted.
+	for _, knot := range fineKnots {
+		if next < len(knots) && knots[next] == knot {
+			next++
+			continue
+		}
+		expandedKnots, control = insertKnot(expandedKnots, b.degree, control, knot)
+	}
+	if next != len(knots) || len(expandedKnots) != len(fineKnots)+2*b.degree {
+		panicf(ErrInvalidArgument, "bsplines: refined knots %v don't include all the knots %v", fineKnots, b.Knots())
+	}
+	return control
+}
+
+// RefinementMatrix returns the matrix P shaped `[numFineControlPoints][NumControlPoints()]` that maps control points
+// of b to the control points representing exactly the same curve over the nested fineKnots (see Refine): it's the
+// two-scale relation of the B-spline basis, with each coarse basis function written as a combination of fine ones.
+//
+// The fineKnots (not expanded) must include all the knots of b. Typically, they are the DoubledKnots.
+// It is the prolongation operator used in multigrid-style fitting, and to extend the grid of KAN networks.
+func (b *BSpline) RefinementMatrix(fineKnots []float64) [][]float64 {
+	numControlPoints := b.NumControlPoints()
+	var p [][]float64
+	unit := make([]float64, numControlPoints)
+	for jj := range numControlPoints {
+		unit[jj] = 1
+		column := b.RefineControlPoints(fineKnots, unit)
+		unit[jj] = 0
+		if p == nil {
+			p = newMatrix(len(column), numControlPoints)
+		}
+		for ii, value := range column {
+			p[ii][jj] = value
+		}
+	}
+	return p
+}
+
+// gramMatrix returns the matrix G with the integrals of the products of the basis functions,
+// `G[i][j] = ∫ B_i(x) B_j(x) dx` over the domain, calculated exactly with Gauss-Legendre quadrature on each span.
+func (b *BSpline) gramMatrix() [][]float64 {
+	numControlPoints := b.NumControlPoints()
+	g := newMatrix(numControlPoints, numControlPoints)
+	basis := make([]float64, b.degree+1)
+	for span := b.degree; span < numControlPoints; span++ {
+		nodes, weights := b.QuadratureNodes(span, b.degree+1)
+		for q, x := range nodes {
+			b.localBasis(span, x, b.degree, basis)
+			for r, br := range basis {
+				for s, bs := range basis {
+					g[span-b.degree+r][span-b.degree+s] += weights[q] * br * bs
+				}
+			}
+		}
+	}
+	return g
+}
+
+// Refine returns a new B-spline over the fineKnots that represents exactly the same curve, including the
+// extrapolation. The fineKnots must include all the knots of b, see RefinementMatrix and DoubledKnots.
+//
+// The vector-valued control points (see WithControlPointsND), if set, are also refined. At least one of the scalar
+// or vector-valued control points must be set.
+func (b *BSpline) Refine(fineKnots []float64) *BSpline {
+	if len(b.controlPoints) == 0 && len(b.controlPointsND) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.Refine() require control points to be set using BSpline.WithControlPoints()")
+	}
+	refined := New(b.degree, slices.Clone(fineKnots)).WithExtrapolation(b.extrapolation).WithClosedDomain(b.closedDomain)
+	refine := func(control []float64) []float64 { return b.RefineControlPoints(fineKnots, control) }
+	if len(b.controlPoints) > 0 {
+		refined.WithControlPoints(refine(b.controlPoints))
+	}
+	if len(b.controlPointsND) > 0 {
+		refined.WithControlPointsND(b.mapControlPointsND(refine))
+	}
+	return refined
+}
+
+// DoubledKnots returns the knots with the mid-points of every (non-empty) knot interval inserted, so the number of
+// intervals doubles. Repeated knots are kept with their multiplicity. The results can be used with Refine or
+// RefinementMatrix.
+func (b *BSpline) DoubledKnots() []float64 {
+	knots := b.Knots()
+	doubled := make([]float64, 0, 2*len(knots)-1)
+	for ii, knot := range knots {
+		if ii > 0 && knots[ii-1] != knot {
+			doubled = append(doubled, (knots[ii-1]+knot)/2)
+		}
+		doubled = append(doubled, knot)
+	}
+	return doubled
+}
+
+// SplitAt splits the B-spline at x, strictly inside the domain, into two independent B-splines: left over the domain
+// [min, x] and right over [x, max]. Together they represent exactly the same curve, e.g. for piecewise editing,
+// or to trim a curve to a sub-domain.
+//
+// It inserts x as a knot until it has multiplicity degree+1 (see insertKnot), so the control points separate into
+// the ones of each side. Both keep the degree and extrapolation of b -- so beyond x each side is extrapolated,
+// instead of following the other side.
+//
+// The control points must be set.
+func (b *BSpline) SplitAt(x float64) (left, right *BSpline) {
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.SplitAt() require control points to be set using BSpline.WithControlPoints()")
+	}
+	domainMin, domainMax := b.Domain()
+	if !(x > domainMin && x < domainMax) {
+		panicf(ErrOutOfDomain, "BSpline.SplitAt(%g) requires x strictly inside the domain [%g, %g]", x, domainMin, domainMax)
+	}
+	expandedKnots, control := b.expandedKnots, b.controlPoints
+	var multiplicity int
+	for _, knot := range expandedKnots {
+		if knot == x {
+			multiplicity++
+		}
+	}
+	for range b.degree + 1 - multiplicity {
+		expandedKnots, control = insertKnot(expandedKnots, b.degree, control, x)
+	}
+
+	// first is the index of the first repetition of x.
+	first, _ := slices.BinarySearch(expandedKnots, x)
+	leftKnots := slices.Clone(expandedKnots[:first+b.degree+1])
+	rightKnots := slices.Clone(expandedKnots[first:])
+	numLeft := len(leftKnots) - b.degree - 1
+	left = newFromExpandedKnots(b.degree, leftKnots).WithExtrapolation(b.extrapolation).
+		WithControlPoints(slices.Clone(control[:numLeft]))
+	right = newFromExpandedKnots(b.degree, rightKnots).WithExtrapolation(b.extrapolation).
+		WithClosedDomain(b.closedDomain).WithControlPoints(slices.Clone(control[numLeft:]))
+	return
+}
+
+// Subdivide returns the same curve with the knot intervals halved levels times (see DoubledKnots and Refine), so
+// there are about `2^levels` times more control points. The control polygon (the control points at ControlPointsX)
+// converges quickly to the curve as the levels increase, which makes it a cheap approximation for rendering and
+// adaptive tessellation.
+//
+// Each level is computed with knot insertion (see Refine). Away from the clamped ends of uniform knots, the result is
+// equivalent to the Lane–Riesenfeld subdivision -- doubling the control points, followed by degree rounds of
+// averaging the neighbours.
+//
+// The control points must be set.
+func (b *BSpline) Subdivide(levels int) *BSpline {
+	if levels < 0 {
+		panicf(ErrInvalidArgument, "BSpline.Subdivide(levels=%d) requires levels >= 0", levels)
+	}
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.Subdivide() require control points to be set using BSpline.WithControlPoints()")
+	}
+	subdivided := newFromExpandedKnots(b.degree, b.expandedKnots).WithExtrapolation(b.extrapolation).
+		WithClosedDomain(b.closedDomain).WithControlPoints(slices.Clone(b.controlPoints))
+	for range levels {
+		subdivided = subdivided.Refine(subdivided.DoubledKnots())
+	}
+	return subdivided
+}
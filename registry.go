@@ -0,0 +1,79 @@
+package bsplines
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Registry maps names to serialized calibration curves, e.g. for a service that ships dozens of them.
+//
+// Curves are registered in their JSON form (see Pipeline.MarshalJSON; a plain B-spline is a Pipeline with identity
+// transforms) and are only decoded on their first lookup. It is safe for concurrent use, and registering a name
+// again replaces its curve, so it can be fed by a config store as the curves are updated.
+type Registry struct {
+	mu      sync.RWMutex
+	entries map[string]*registryEntry
+}
+
+// registryEntry holds a serialized curve, and the result of decoding it, done at most once.
+type registryEntry struct {
+	data     []byte
+	once     sync.Once
+	pipeline *Pipeline
+	err      error
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{entries: make(map[string]*registryEntry)}
+}
+
+// Register the serialized curve under name, replacing any previous curve with the same name.
+// The data is not decoded until the first call to Get, and it must not be modified afterwards.
+func (r *Registry) Register(name string, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[name] = &registryEntry{data: data}
+}
+
+// Remove the curve registered under name, if any.
+func (r *Registry) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries, name)
+}
+
+// Names returns the sorted names of the registered curves.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.entries))
+	for name := range r.entries {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Get returns the curve registered under name, decoding it on the first call.
+// It returns an error wrapping ErrNotFound if there is no such curve, or the decoding error if it is invalid.
+//
+// The returned Pipeline is shared by all callers, and it must not be modified.
+func (r *Registry) Get(name string) (*Pipeline, error) {
+	r.mu.RLock()
+	entry, found := r.entries[name]
+	r.mu.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("bsplines.Registry has no curve named %q: %w", name, ErrNotFound)
+	}
+	entry.once.Do(func() {
+		pipeline := &Pipeline{}
+		if err := pipeline.UnmarshalJSON(entry.data); err != nil {
+			entry.err = fmt.Errorf("bsplines.Registry failed to decode curve %q: %w", name, err)
+			return
+		}
+		entry.pipeline = pipeline
+	})
+	return entry.pipeline, entry.err
+}
@@ -0,0 +1,47 @@
+package bsplines
+
+// EvalScratch holds preallocated buffers to evaluate a B-spline without any heap allocations, for real-time or
+// GC-sensitive code. Create it with BSpline.NewEvalScratch.
+//
+// It's not safe for concurrent use: create one per goroutine. It can be reused after the control points of the
+// B-spline are changed with BSpline.WithControlPoints.
+type EvalScratch struct {
+	bspline *BSpline
+	basis   []float64
+	span    int
+}
+
+// NewEvalScratch creates an EvalScratch to evaluate the B-spline without allocations.
+func (b *BSpline) NewEvalScratch() *EvalScratch {
+	return &EvalScratch{
+		bspline: b,
+		basis:   make([]float64, b.degree+1),
+		span:    b.degree,
+	}
+}
+
+// Evaluate is the same as BSpline.Evaluate, without any heap allocations.
+// The span of the previous call is tried first, which makes it faster for nearby consecutive values.
+func (s *EvalScratch) Evaluate(x float64) float64 {
+	b := s.bspline
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "EvalScratch.Evaluate() require control points to be set using BSpline.WithControlPoints()")
+	}
+	if !b.inDomain(x) {
+		return b.evaluateOutside(x)
+	}
+	s.span = b.spanIndexWithHint(x, s.span)
+	return b.evaluateSpan(s.span, x, s.basis)
+}
+
+// EvaluateBatchInto is the same as BSpline.EvaluateBatchInto, without any heap allocations for any degree.
+func (s *EvalScratch) EvaluateBatchInto(xs, output []float64) {
+	b := s.bspline
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "EvalScratch.EvaluateBatchInto() require control points to be set using BSpline.WithControlPoints()")
+	}
+	if len(output) != len(xs) {
+		panicf(ErrInvalidArgument, "EvalScratch.EvaluateBatchInto() requires len(output)=%d to be equal to len(xs)=%d", len(output), len(xs))
+	}
+	b.evaluateBatchInto(xs, output, s.basis)
+}
@@ -0,0 +1,99 @@
+// Package signal resamples uniformly sampled signals with B-splines: the signal is interpolated by a cubic spline,
+// which is then sampled at the new rate, for high-quality resampling at arbitrary (non-integer) rate ratios.
+//
+// Use Resample to convert between rates, or Spline to get the interpolating B-spline itself.
+package signal
+
+import (
+	"github.com/gomlx/bsplines"
+	"github.com/gomlx/exceptions"
+	"math"
+)
+
+// Option configures Spline and Resample.
+type Option func(c *config)
+
+// config holds the configuration of Spline and Resample.
+type config struct {
+	monotone bool
+}
+
+// WithMonotone uses the monotone PCHIP interpolant (see bsplines.NewPCHIP) instead of the C² cubic spline: it
+// doesn't oscillate (no ringing or overshoot) around steps and spikes of the signal, at the cost of being only C¹.
+func WithMonotone() Option {
+	return func(c *config) { c.monotone = true }
+}
+
+// Spline returns the cubic B-spline that interpolates the samples, taken at sampleRate (samples per unit of time),
+// starting at time 0: the sample i is at time `i/sampleRate`. The domain is closed (see
+// bsplines.BSpline.WithClosedDomain), so it includes the last sample.
+//
+// By default, it's the natural cubic spline (C², with zero second derivatives at the ends), which is computed in
+// linear time. See WithMonotone for the non-oscillating alternative.
+func Spline(samples []float64, sampleRate float64, opts ...Option) *bsplines.BSpline {
+	if len(samples) < 2 {
+		exceptions.Panicf("signal.Spline requires at least 2 samples, got %d", len(samples))
+	}
+	if !(sampleRate > 0) {
+		exceptions.Panicf("signal.Spline(sampleRate=%g) requires sampleRate > 0", sampleRate)
+	}
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	times := make([]float64, len(samples))
+	for ii := range times {
+		times[ii] = float64(ii) / sampleRate
+	}
+	if c.monotone {
+		return bsplines.NewPCHIP(times, samples).WithClosedDomain(true)
+	}
+	return bsplines.NewHermite(times, samples, naturalSlopes(samples, 1/sampleRate)).WithClosedDomain(true)
+}
+
+// naturalSlopes returns the slopes of the natural cubic spline through the samples, spaced by h, solving the
+// tridiagonal system of the C² conditions with the Thomas algorithm.
+func naturalSlopes(samples []float64, h float64) []float64 {
+	n := len(samples)
+	// Equations: lower[i]*m[i-1] + diag[i]*m[i] + upper[i]*m[i+1] = rhs[i].
+	diag, upper, rhs := make([]float64, n), make([]float64, n), make([]float64, n)
+	diag[0], upper[0], rhs[0] = 2, 1, 3*(samples[1]-samples[0])/h
+	for ii := 1; ii < n-1; ii++ {
+		diag[ii], upper[ii], rhs[ii] = 4, 1, 3*(samples[ii+1]-samples[ii-1])/h
+	}
+	diag[n-1], rhs[n-1] = 2, 3*(samples[n-1]-samples[n-2])/h
+
+	// Forward elimination: the lower diagonal is always 1.
+	for ii := 1; ii < n; ii++ {
+		w := 1 / diag[ii-1]
+		diag[ii] -= w * upper[ii-1]
+		rhs[ii] -= w * rhs[ii-1]
+	}
+	slopes := make([]float64, n)
+	slopes[n-1] = rhs[n-1] / diag[n-1]
+	for ii := n - 2; ii >= 0; ii-- {
+		slopes[ii] = (rhs[ii] - upper[ii]*slopes[ii+1]) / diag[ii]
+	}
+	return slopes
+}
+
+// Resample the samples, taken at sampleRate, to newRate: it returns the values of the interpolating spline (see
+// Spline) at the times `k/newRate`, for all k such that the time is within the original signal, that is
+// `floor((len(samples)-1) * newRate / sampleRate) + 1` values.
+//
+// Notice it doesn't low-pass filter the signal: when downsampling to a rate below twice the highest frequency of
+// the signal, it will alias.
+func Resample(samples []float64, sampleRate, newRate float64, opts ...Option) []float64 {
+	if !(newRate > 0) {
+		exceptions.Panicf("signal.Resample(newRate=%g) requires newRate > 0", newRate)
+	}
+	b := Spline(samples, sampleRate, opts...)
+	duration := float64(len(samples)-1) / sampleRate
+	// Small tolerance so that rates with an exact ratio don't lose the last sample due to rounding.
+	numSamples := int(math.Floor(duration*newRate*(1+1e-12))) + 1
+	times := make([]float64, numSamples)
+	for ii := range times {
+		times[ii] = min(float64(ii)/newRate, duration)
+	}
+	return b.EvaluateBatch(times)
+}
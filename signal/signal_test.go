@@ -0,0 +1,49 @@
+package signal
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+func TestResample(t *testing.T) {
+	const sampleRate, newRate = 100.0, 44.1
+	samples := make([]float64, 201)
+	for ii := range samples {
+		samples[ii] = math.Sin(2 * math.Pi * 3 * float64(ii) / sampleRate)
+	}
+	resampled := Resample(samples, sampleRate, newRate)
+	require.Len(t, resampled, 89)
+	for ii, value := range resampled {
+		assert.InDelta(t, math.Sin(2*math.Pi*3*float64(ii)/newRate), value, 1e-3, "sample #%d", ii)
+	}
+
+	// Same rate: the samples are interpolated.
+	assert.InDeltaSlice(t, samples, Resample(samples, sampleRate, sampleRate), 1e-12)
+
+	// The natural spline is C².
+	b := Spline(samples, sampleRate)
+	second := b.DerivativeN(2)
+	for ii := 1; ii < 10; ii++ {
+		knot := float64(ii) / sampleRate
+		assert.InDelta(t, second.Evaluate(knot-1e-9), second.Evaluate(knot+1e-9), 1e-3, "knot=%g", knot)
+	}
+}
+
+func TestResampleMonotone(t *testing.T) {
+	// A step: the cubic spline rings, the monotone one doesn't overshoot.
+	samples := []float64{0, 0, 0, 0, 1, 1, 1, 1}
+	assert.Less(t, minValue(Resample(samples, 1, 10)), -0.01)
+	for _, value := range Resample(samples, 1, 10, WithMonotone()) {
+		assert.True(t, value >= 0 && value <= 1, "value=%g", value)
+	}
+}
+
+func minValue(values []float64) float64 {
+	result := math.Inf(1)
+	for _, value := range values {
+		result = min(result, value)
+	}
+	return result
+}
@@ -0,0 +1,42 @@
+package bsplines
+
+// SmoothCoefficients returns a new B-spline with the same knots and extrapolation, but with its control points
+// filtered (convolved) with the given kernel: a quick way to de-noise an already fitted B-spline, without fitting
+// it again to the data.
+//
+// The kernel must have an odd length, and it's centered on each control point: e.g. `[]float64{0.25, 0.5, 0.25}`.
+// Near the ends, the kernel elements that would fall outside the control points are dropped, and the remaining
+// ones are rescaled to keep the sum of the kernel: so a kernel that sums to 1 preserves constant functions.
+//
+// The control points must be set.
+func (b *BSpline) SmoothCoefficients(kernel []float64) *BSpline {
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "BSpline.SmoothCoefficients() require control points to be set using BSpline.WithControlPoints()")
+	}
+	if len(kernel)%2 != 1 {
+		panicf(ErrInvalidArgument, "BSpline.SmoothCoefficients() requires a kernel of odd length, got %d", len(kernel))
+	}
+	var kernelSum float64
+	for _, weight := range kernel {
+		kernelSum += weight
+	}
+	half := len(kernel) / 2
+	control := make([]float64, len(b.controlPoints))
+	for ii := range control {
+		var sum, validSum float64
+		for jj, weight := range kernel {
+			idx := ii + jj - half
+			if idx < 0 || idx >= len(control) {
+				continue
+			}
+			sum += weight * b.controlPoints[idx]
+			validSum += weight
+		}
+		if validSum != 0 {
+			sum *= kernelSum / validSum
+		}
+		control[ii] = sum
+	}
+	return newFromExpandedKnots(b.degree, b.expandedKnots).WithExtrapolation(b.extrapolation).
+		WithClosedDomain(b.closedDomain).WithControlPoints(control)
+}
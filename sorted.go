@@ -0,0 +1,56 @@
+package bsplines
+
+import "math"
+
+// SortedEvaluator evaluates a B-spline on a stream of non-decreasing x values -- e.g. the timestamps of a time-series
+// being resampled -- advancing the knot span incrementally instead of searching for it on each call. Evaluating n
+// values takes O(n + number of knots) span steps in total, so the lookup is amortized O(1).
+//
+// Create it with BSpline.NewSortedEvaluator. It's not safe for concurrent use: create one per stream.
+type SortedEvaluator struct {
+	bspline *BSpline
+	basis   []float64
+	span    int
+	lastX   float64
+}
+
+// NewSortedEvaluator creates a SortedEvaluator for the B-spline. The control points of the B-spline must be set
+// before calling Next.
+func (b *BSpline) NewSortedEvaluator() *SortedEvaluator {
+	s := &SortedEvaluator{
+		bspline: b,
+		basis:   make([]float64, b.degree+1),
+	}
+	s.Reset()
+	return s
+}
+
+// Reset the SortedEvaluator to start a new stream, which can start from any x.
+func (s *SortedEvaluator) Reset() {
+	s.span = s.bspline.degree
+	s.lastX = math.Inf(-1)
+}
+
+// Next returns the B-spline evaluated at x, which must be greater or equal to the x of the previous call
+// (since the last Reset). It panics otherwise.
+func (s *SortedEvaluator) Next(x float64) float64 {
+	b := s.bspline
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "SortedEvaluator.Next() require control points to be set using BSpline.WithControlPoints()")
+	}
+	if math.IsNaN(x) {
+		return math.NaN()
+	}
+	if x < s.lastX {
+		panicf(ErrInvalidArgument, "SortedEvaluator.Next(%g) requires non-decreasing values, but the previous value was %g", x, s.lastX)
+	}
+	s.lastX = x
+	if !b.inDomain(x) {
+		return b.evaluateOutside(x)
+	}
+	lastSpan := b.NumControlPoints() - 1
+	for s.span < lastSpan && x >= b.expandedKnots[s.span+1] {
+		s.span++
+	}
+	return b.evaluateSpan(s.span, x, s.basis)
+}
@@ -0,0 +1,105 @@
+package bsplines
+
+import (
+	"math"
+	"slices"
+)
+
+// SparseControlPoints stores control points as a shared baseline plus the few exceptions that differ from it,
+// e.g. for large banks of calibrations where most curves are close to the identity: only the exceptions take memory,
+// the baseline is shared by all of them.
+//
+// Create it with NewSparseControlPoints, and evaluate it with BSpline.EvaluateSparse or BSpline.EvaluateSparseBank.
+type SparseControlPoints struct {
+	baseline []float64
+
+	// indices (sorted) and values of the control points that differ from the baseline.
+	indices []int
+	values  []float64
+}
+
+// NewSparseControlPoints returns the sparse representation of controlPoints with respect to the baseline: the
+// control points that differ from the baseline by more than tolerance are stored as exceptions, the others are
+// taken from the baseline. So with tolerance 0 the representation is exact.
+//
+// The baseline is not copied, and it must not be changed, since it's meant to be shared.
+func NewSparseControlPoints(baseline, controlPoints []float64, tolerance float64) *SparseControlPoints {
+	if len(controlPoints) != len(baseline) {
+		panicf(ErrControlPointCount, "bsplines.NewSparseControlPoints requires the same number of control points (%d) as the baseline (%d)",
+			len(controlPoints), len(baseline))
+	}
+	s := &SparseControlPoints{baseline: baseline}
+	for ii, value := range controlPoints {
+		if math.Abs(value-baseline[ii]) > tolerance {
+			s.indices = append(s.indices, ii)
+			s.values = append(s.values, value)
+		}
+	}
+	s.indices, s.values = slices.Clip(s.indices), slices.Clip(s.values)
+	return s
+}
+
+// Len returns the number of control points.
+func (s *SparseControlPoints) Len() int {
+	return len(s.baseline)
+}
+
+// NumExceptions returns the number of control points stored apart from the baseline.
+func (s *SparseControlPoints) NumExceptions() int {
+	return len(s.indices)
+}
+
+// At returns the control point at index ii. It's O(log(NumExceptions())).
+func (s *SparseControlPoints) At(ii int) float64 {
+	if pos, found := slices.BinarySearch(s.indices, ii); found {
+		return s.values[pos]
+	}
+	return s.baseline[ii]
+}
+
+// Dense returns the control points as a newly allocated slice, e.g. to use with BSpline.WithControlPoints.
+func (s *SparseControlPoints) Dense() []float64 {
+	dense := slices.Clone(s.baseline)
+	for pos, ii := range s.indices {
+		dense[ii] = s.values[pos]
+	}
+	return dense
+}
+
+// EvaluateSparse evaluates at x the B-spline with the knots, degree and extrapolation of b, and the sparse control
+// points s -- the control points of b are not used. It's the same as evaluating with the dense control points.
+func (b *BSpline) EvaluateSparse(s *SparseControlPoints, x float64) float64 {
+	return b.EvaluateSparseBank([]*SparseControlPoints{s}, x)[0]
+}
+
+// EvaluateSparseBank is like EvaluateBank, but for a bank of sparse control points: the basis functions are computed
+// only once for all of them.
+//
+// It returns a newly allocated slice with one value per B-spline. The control points of b are not used.
+func (b *BSpline) EvaluateSparseBank(bank []*SparseControlPoints, x float64) []float64 {
+	numControlPoints := b.NumControlPoints()
+	for ii, s := range bank {
+		if s.Len() != numControlPoints {
+			panicf(ErrControlPointCount, "BSpline.EvaluateSparseBank() expected %d control points for each B-spline, but bank[%d] has %d", numControlPoints, ii, s.Len())
+		}
+	}
+	output := make([]float64, len(bank))
+	if math.IsNaN(x) {
+		for ii := range output {
+			output[ii] = x
+		}
+		return output
+	}
+	// Outside the domain, the basis functions are the weights of the extrapolation.
+	first, basis := b.BasisFunctionsAt(x)
+	for ii, s := range bank {
+		var result float64
+		for r, weight := range basis {
+			if weight != 0 {
+				result += weight * s.At(first+r)
+			}
+		}
+		output[ii] = result
+	}
+	return output
+}
@@ -0,0 +1,16 @@
+package bsplines
+
+// Eval evaluates at x the B-spline defined by degree, knots and controlPoints, without the need to keep a BSpline
+// object around. It's handy for one-off computations.
+//
+// The knots and controlPoints follow the same rules as in [New] and [BSpline.WithControlPoints], and the default
+// extrapolation ([ExtrapolateConstant]) is used.
+func Eval(degree int, knots, controlPoints []float64, x float64) float64 {
+	return New(degree, knots).WithControlPoints(controlPoints).Evaluate(x)
+}
+
+// EvalWithGradient evaluates at x the B-spline defined by degree, knots and controlPoints, and its derivative with
+// respect to x. See [Eval] and [BSpline.EvaluateWithGradient].
+func EvalWithGradient(degree int, knots, controlPoints []float64, x float64) (value, dydx float64) {
+	return New(degree, knots).WithControlPoints(controlPoints).EvaluateWithGradient(x)
+}
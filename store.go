@@ -0,0 +1,139 @@
+package bsplines
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Store holds a snapshot of named calibration curves that can be swapped atomically while being read: lookups
+// never lock, and they see either the old or the new set of curves, never a mix.
+//
+// Updates are pushed with Update, or loaded from a directory of JSON files (see LoadDir and Watch). Incoming curves
+// are decoded (and so validated, see Pipeline.UnmarshalJSON) before the swap: if any of them is invalid, the update is rejected as a whole and the
+// current curves are kept.
+//
+// Like in Registry, the curves are serialized Pipelines (see Pipeline.MarshalJSON), and the returned Pipelines are
+// shared by all readers and must not be modified.
+type Store struct {
+	curves atomic.Pointer[map[string]*Pipeline]
+}
+
+// NewStore creates an empty Store.
+func NewStore() *Store {
+	s := &Store{}
+	s.curves.Store(&map[string]*Pipeline{})
+	return s
+}
+
+// Get returns the curve with the given name in the current snapshot, and whether it was found.
+func (s *Store) Get(name string) (*Pipeline, bool) {
+	p, found := (*s.curves.Load())[name]
+	return p, found
+}
+
+// Names returns the sorted names of the curves in the current snapshot.
+func (s *Store) Names() []string {
+	curves := *s.curves.Load()
+	names := make([]string, 0, len(curves))
+	for name := range curves {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Update decodes the serialized curves (see Pipeline.UnmarshalJSON), and if they are all valid, atomically replaces all the curves
+// of the Store with them. Otherwise, it returns an error and the Store is not changed.
+func (s *Store) Update(serialized map[string][]byte) error {
+	curves := make(map[string]*Pipeline, len(serialized))
+	for name, data := range serialized {
+		p := &Pipeline{}
+		if err := p.UnmarshalJSON(data); err != nil {
+			return fmt.Errorf("bsplines.Store failed to decode curve %q: %w", name, err)
+		}
+		curves[name] = p
+	}
+	s.curves.Store(&curves)
+	return nil
+}
+
+// storeFileExt is the extension of the files loaded by Store.LoadDir: the name of each curve is the file name
+// without it.
+const storeFileExt = ".json"
+
+// LoadDir reads all the "*.json" files in dir, each holding one serialized curve named after the file (without the
+// extension), and atomically replaces the curves of the Store with them, see Update.
+func (s *Store) LoadDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("bsplines.Store failed to read directory %q: %w", dir, err)
+	}
+	serialized := make(map[string][]byte)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != storeFileExt {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("bsplines.Store failed to read curve file: %w", err)
+		}
+		serialized[strings.TrimSuffix(entry.Name(), storeFileExt)] = data
+	}
+	return s.Update(serialized)
+}
+
+// Watch loads the curves in dir (see LoadDir), and then polls dir every interval, reloading it whenever a "*.json"
+// file is added, removed or modified. It blocks until ctx is done, so it's usually run in its own goroutine.
+//
+// Errors don't stop the watching -- the Store keeps the last valid curves -- and they are reported to onError,
+// if it is not nil. It panics if interval is not positive.
+func (s *Store) Watch(ctx context.Context, dir string, interval time.Duration, onError func(error)) {
+	if interval <= 0 {
+		panicf(ErrInvalidArgument, "Store.Watch() requires a positive interval, got %s", interval)
+	}
+	var lastSignature string
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		signature, err := dirSignature(dir)
+		if err == nil && signature != lastSignature {
+			// Invalid files are not retried until they change again.
+			lastSignature = signature
+			err = s.LoadDir(dir)
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dirSignature returns a string that changes whenever a "*.json" file in dir is added, removed or modified.
+func dirSignature(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("bsplines.Store failed to read directory %q: %w", dir, err)
+	}
+	var signature strings.Builder
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != storeFileExt {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return "", fmt.Errorf("bsplines.Store failed to stat curve file: %w", err)
+		}
+		fmt.Fprintf(&signature, "%s:%d:%d;", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return signature.String(), nil
+}
@@ -0,0 +1,86 @@
+package bsplines
+
+import (
+	"math"
+)
+
+// Surface is a tensor-product B-spline surface: `f(u, v) = Σ_{i,j} controlPoints[i][j] * B_i(u) * B_j(v)`, where
+// B_i are the basis functions of the B-spline for the u direction, and B_j the ones for the v direction. E.g. for
+// 2D calibration tables, or height maps.
+//
+// Outside the domain of each direction, the surface is extrapolated as configured in the B-spline of that direction.
+type Surface struct {
+	u, v          *BSpline
+	controlPoints [][]float64
+}
+
+// NewSurface creates a Surface with the knots, degree and extrapolation of the B-splines u and v (their control
+// points are not used), and the grid of control points shaped `[u.NumControlPoints()][v.NumControlPoints()]`.
+func NewSurface(u, v *BSpline, controlPoints [][]float64) *Surface {
+	if len(controlPoints) != u.NumControlPoints() {
+		panicf(ErrControlPointCount, "bsplines.NewSurface requires controlPoints shaped [%d][%d], got %d rows",
+			u.NumControlPoints(), v.NumControlPoints(), len(controlPoints))
+	}
+	for ii, row := range controlPoints {
+		if len(row) != v.NumControlPoints() {
+			panicf(ErrControlPointCount, "bsplines.NewSurface requires controlPoints shaped [%d][%d], but row %d has %d columns",
+				u.NumControlPoints(), v.NumControlPoints(), ii, len(row))
+		}
+	}
+	return &Surface{u: u, v: v, controlPoints: controlPoints}
+}
+
+// ControlPoints returns the grid of control points, shaped `[numU][numV]`.
+func (s *Surface) ControlPoints() [][]float64 {
+	return s.controlPoints
+}
+
+// Evaluate the surface at (u, v).
+func (s *Surface) Evaluate(u, v float64) float64 {
+	value, _, _ := s.EvaluateWithGradient(u, v)
+	return value
+}
+
+// EvaluateWithGradient evaluates the surface at (u, v), and its partial derivatives with respect to u and v.
+// It returns NaNs if u or v is NaN.
+func (s *Surface) EvaluateWithGradient(u, v float64) (value, du, dv float64) {
+	if math.IsNaN(u) || math.IsNaN(v) {
+		return math.NaN(), math.NaN(), math.NaN()
+	}
+	firstU, basisU, derivativesU := s.u.basisWithDerivativesAt(u)
+	firstV, basisV, derivativesV := s.v.basisWithDerivativesAt(v)
+	for r, bu := range basisU {
+		row := s.controlPoints[firstU+r][firstV:]
+		var rowValue, rowDerivative float64
+		for c, bv := range basisV {
+			rowValue += bv * row[c]
+			rowDerivative += derivativesV[c] * row[c]
+		}
+		value += bu * rowValue
+		du += derivativesU[r] * rowValue
+		dv += bu * rowDerivative
+	}
+	return
+}
+
+// basisWithDerivativesAt returns the same as BasisFunctionsAt, and also the derivatives of the basis functions --
+// outside the domain, the derivatives of the extrapolation weights.
+func (b *BSpline) basisWithDerivativesAt(x float64) (firstIndex int, values, derivatives []float64) {
+	if b.inDomain(x) {
+		span := b.SpanIndex(x)
+		ders := newMatrix(2, b.degree+1)
+		b.basisDerivatives(span, x, 1, ders, newBasisDerivativesScratch(b.degree))
+		return span - b.degree, ders[0], ders[1]
+	}
+	firstIndex, values = b.BasisFunctionsAt(x)
+	derivatives = make([]float64, b.degree+1)
+	if b.extrapolation == ExtrapolateLinear && b.degree > 0 {
+		low, high := b.LinearExtrapolationKnotRatios()
+		if domainMin, _ := b.Domain(); x < domainMin {
+			derivatives[0], derivatives[1] = -low, low
+		} else {
+			derivatives[b.degree-1], derivatives[b.degree] = -high, high
+		}
+	}
+	return
+}
@@ -0,0 +1,45 @@
+package bsplines
+
+// EvaluateTensor evaluates on CPU multiple B-splines with the same shapes and semantics as the GoMLX evaluator
+// ([github.com/gomlx/bsplines/gomlx.Evaluate]), so the batched semantics can be used (e.g. in demos and tests) without
+// an accelerator backend installed.
+//
+// The B-spline b defines the knots, degree and extrapolation of all B-splines -- its control points are not used.
+//
+//   - inputs is shaped `[batchSize][numInputs]`.
+//   - controlPoints is shaped `[numInputs][numOutputs][numControlPoints]`: each input has numOutputs B-splines.
+//
+// The result is shaped `[batchSize][numOutputs][numInputs]`. The basis functions are calculated once per input value,
+// and shared by the numOutputs B-splines, see EvaluateBank.
+func (b *BSpline) EvaluateTensor(inputs [][]float64, controlPoints [][][]float64) [][][]float64 {
+	numInputs := len(controlPoints)
+	if numInputs == 0 {
+		panicf(ErrInvalidArgument, "BSpline.EvaluateTensor() requires controlPoints shaped [numInputs][numOutputs][numControlPoints], got numInputs=0")
+	}
+	numOutputs := len(controlPoints[0])
+	for ii, bank := range controlPoints {
+		if len(bank) != numOutputs {
+			panicf(ErrInvalidArgument, "BSpline.EvaluateTensor() requires controlPoints shaped [numInputs][numOutputs][numControlPoints], "+
+				"but controlPoints[0] has %d outputs and controlPoints[%d] has %d", numOutputs, ii, len(bank))
+		}
+	}
+	outputs := make([]float64, numOutputs)
+	result := make([][][]float64, len(inputs))
+	for ee, example := range inputs {
+		if len(example) != numInputs {
+			panicf(ErrInvalidArgument, "BSpline.EvaluateTensor() requires inputs shaped [batchSize][numInputs=%d], but inputs[%d] has %d values",
+				numInputs, ee, len(example))
+		}
+		result[ee] = make([][]float64, numOutputs)
+		for oo := range numOutputs {
+			result[ee][oo] = make([]float64, numInputs)
+		}
+		for ii, x := range example {
+			b.EvaluateBankInto(controlPoints[ii], x, outputs)
+			for oo, value := range outputs {
+				result[ee][oo][ii] = value
+			}
+		}
+	}
+	return result
+}
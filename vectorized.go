@@ -0,0 +1,125 @@
+package bsplines
+
+import (
+	"math/bits"
+)
+
+// vectorChunkSize is the number of inputs processed together by VectorizedEvaluator.
+const vectorChunkSize = 8
+
+// VectorizedEvaluator evaluates a B-spline on slices of inputs, processing them in fixed-size chunks with the
+// inner loops running over the inputs of the chunk, and with the same number of iterations for every input -- the
+// span search takes a fixed number of binary search steps. Only the comparisons of the span search and the final
+// extrapolation check depend on the data. This is friendly to auto-vectorization (and future assembly
+// implementations), and it has a much better throughput than calling BSpline.Evaluate in a loop for medium and
+// large batches.
+//
+// Create it with BSpline.Vectorized. It holds scratch buffers, so it's not safe for concurrent use: create one
+// per goroutine. It uses the current control points of the B-spline.
+type VectorizedEvaluator struct {
+	bspline              *BSpline
+	numBinarySearchSteps int
+
+	// Scratch buffers for one chunk.
+	x     [vectorChunkSize]float64
+	spans [vectorChunkSize]int
+	basis [][vectorChunkSize]float64
+}
+
+// Vectorized returns a VectorizedEvaluator for the B-spline.
+func (b *BSpline) Vectorized() *VectorizedEvaluator {
+	return &VectorizedEvaluator{
+		bspline:              b,
+		numBinarySearchSteps: bits.Len(uint(b.NumControlPoints() - 1 - b.degree)),
+		basis:                make([][vectorChunkSize]float64, b.degree+1),
+	}
+}
+
+// Evaluate the B-spline at each of the xs, and returns a newly allocated slice with the results.
+func (v *VectorizedEvaluator) Evaluate(xs []float64) []float64 {
+	output := make([]float64, len(xs))
+	v.EvaluateInto(xs, output)
+	return output
+}
+
+// EvaluateInto evaluates the B-spline at each of the xs, and writes the results into output, which must have the
+// same length as xs.
+func (v *VectorizedEvaluator) EvaluateInto(xs, output []float64) {
+	b := v.bspline
+	if len(b.controlPoints) == 0 {
+		panicf(ErrControlPointsNotSet, "VectorizedEvaluator.Evaluate() require control points to be set using BSpline.WithControlPoints()")
+	}
+	if len(output) != len(xs) {
+		panicf(ErrInvalidArgument, "VectorizedEvaluator.EvaluateInto() requires len(output)=%d to be equal to len(xs)=%d", len(output), len(xs))
+	}
+	for start := 0; start < len(xs); start += vectorChunkSize {
+		end := min(start+vectorChunkSize, len(xs))
+		v.evaluateChunk(xs[start:end], output[start:end])
+	}
+}
+
+// evaluateChunk evaluates up to vectorChunkSize inputs. The last chunk may be smaller, in which case the remaining
+// lanes are filled with the first knot.
+func (v *VectorizedEvaluator) evaluateChunk(xs, output []float64) {
+	b := v.bspline
+	knots, degree := b.expandedKnots, b.degree
+	domainMin, domainMax := b.Domain()
+	for lane := range vectorChunkSize {
+		x := domainMin
+		if lane < len(xs) {
+			x = xs[lane]
+		}
+		v.x[lane] = min(max(x, domainMin), domainMax)
+		v.spans[lane] = degree
+	}
+
+	// Binary search with a fixed number of steps, see BSpline.SpanIndex.
+	high := [vectorChunkSize]int{}
+	for lane := range vectorChunkSize {
+		high[lane] = b.NumControlPoints() - 1
+	}
+	for range v.numBinarySearchSteps {
+		for lane := range vectorChunkSize {
+			low := v.spans[lane]
+			mid := (low + high[lane] + 1) / 2
+			if knots[mid] <= v.x[lane] {
+				low = mid
+			} else {
+				high[lane] = mid - 1
+			}
+			v.spans[lane] = low
+		}
+	}
+
+	// De Boor triangle, see BSpline.localBasis.
+	for lane := range vectorChunkSize {
+		v.basis[0][lane] = 1
+	}
+	for jj := 1; jj <= degree; jj++ {
+		var saved [vectorChunkSize]float64
+		for r := range jj {
+			for lane := range vectorChunkSize {
+				span, x := v.spans[lane], v.x[lane]
+				left := x - knots[span+1-jj+r]
+				right := knots[span+1+r] - x
+				temp := v.basis[r][lane] / (right + left)
+				v.basis[r][lane] = saved[lane] + right*temp
+				saved[lane] = left * temp
+			}
+		}
+		v.basis[jj] = saved
+	}
+
+	// Weighted sum of the control points, and extrapolation.
+	for lane, x := range xs {
+		var result float64
+		span := v.spans[lane]
+		for r := range degree + 1 {
+			result += v.basis[r][lane] * b.controlPoints[span-degree+r]
+		}
+		if !b.inDomain(x) {
+			result = b.evaluateOutside(x)
+		}
+		output[lane] = result
+	}
+}